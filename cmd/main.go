@@ -1,24 +1,105 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"log"
 	"net/http"
+	"net/smtp"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/jobs"
+	"forum.bbilisbe/internal/live"
+	"forum.bbilisbe/internal/mail"
+	"forum.bbilisbe/internal/media"
+	"forum.bbilisbe/internal/models"
 	delivery "forum.bbilisbe/pkg/delivery/http"
 	"forum.bbilisbe/pkg/repository"
 	"forum.bbilisbe/pkg/usecase"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+const (
+	// shutdownTimeout is how long the requests and jobs running when the
+	// server is stopped get to finish.
+	shutdownTimeout = 30 * time.Second
+	// keepDoneJobs and keepFailedJobs are how long finished jobs stay listed.
+	keepDoneJobs   = 24 * time.Hour
+	keepFailedJobs = 30 * 24 * time.Hour
+	// orphanImageAge is the age from which stored images no post shows are
+	// deleted, it leaves time to finish the posts they were uploaded with.
+	orphanImageAge = 24 * time.Hour
+	// legacyImageDir and legacyAvatarDir held the uploads before the media
+	// store, they are moved into it on start.
+	legacyImageDir  = "./ui/static/img/user_images"
+	legacyImageURL  = "/static/img/user_images/"
+	legacyAvatarDir = "./ui/static/img/avatars"
+)
+
 func main() {
 	// Parsing the runtime configuration settings for the application;
 	addr := flag.String("addr", ":7070", "HTTP Network Address")
+	holdAge := flag.Duration("hold-age", 24*time.Hour, "Posts of accounts younger than this wait for moderation")
+	holdTrust := flag.Int("hold-trust", 1, "Posts of accounts with a lower trust score wait for moderation")
+	moderators := flag.String("moderators", "", "Comma separated list of moderator usernames")
+	admins := flag.String("admins", "", "Comma separated list of admin usernames")
+	filterHold := flag.Int("filter-hold", 5, "Spam score at which content is held for moderation")
+	filterReject := flag.Int("filter-reject", 10, "Spam score at which content is rejected")
+	maxLinks := flag.Int("max-links", 2, "Links a new account may post at once")
+	duplicateWindow := flag.Duration("duplicate-window", 24*time.Hour, "Window in which duplicate content is detected")
+	postLimit := flag.Int("post-limit", 5, "Posts a user may create per hour")
+	commentLimit := flag.Int("comment-limit", 30, "Comments a user may write per hour")
+	voteLimit := flag.Int("vote-limit", 300, "Likes and dislikes a user may give per hour")
+	previewLimit := flag.Int("preview-limit", 60, "Markdown previews a user may request per hour")
+	captchaForms := flag.String("captcha", "", "Comma separated list of forms protected by a CAPTCHA: signup, login, comment, report")
+	inviteOnly := flag.Bool("invite-only", false, "Require an invite code to sign up")
+	inviteTrust := flag.Int("invite-trust", 10, "Trust score a user needs to create invite codes")
+	baseURL := flag.String("base-url", "", "Public URL of the forum, e.g. https://forum.example.com")
+	mailFrom := flag.String("mail-from", "K-Pop Forum <forum@localhost>", "Sender address of the emails")
+	mailOutbox := flag.String("mail-outbox", "outbox", "Directory the emails are written to when no SMTP server is set")
+	smtpAddr := flag.String("smtp-addr", "", "SMTP server sending the emails, host:port")
+	smtpUser := flag.String("smtp-user", "", "SMTP username")
+	smtpPassword := flag.String("smtp-password", "", "SMTP password")
+	attachmentTypes := flag.String("attachment-types", "application/pdf,text/plain,application/zip", "Comma separated list of the MIME types of the files other than images which can be attached to posts")
+	maxFileSize := flag.Int64("max-file-size", 10, "Largest attachment in MB")
+	maxPostSize := flag.Int64("max-post-size", 20, "Largest total size of the attachments of a post in MB")
+	mediaDir := flag.String("media-dir", "media", "Directory the uploaded files are stored in when no S3 bucket is set")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3 compatible storage keeping the uploaded files, e.g. https://s3.eu-west-1.amazonaws.com")
+	s3Region := flag.String("s3-region", "us-east-1", "Region of the S3 bucket")
+	s3Bucket := flag.String("s3-bucket", "", "S3 bucket name")
+	s3AccessKey := flag.String("s3-access-key", "", "S3 access key ID")
+	s3SecretKey := flag.String("s3-secret-key", "", "S3 secret access key")
 	flag.Parse()
 
+	cfg := &config.Config{
+		HoldAge:         *holdAge,
+		HoldTrust:       *holdTrust,
+		Moderators:      config.SplitList(*moderators),
+		Admins:          config.SplitList(*admins),
+		FilterHold:      *filterHold,
+		FilterReject:    *filterReject,
+		MaxLinks:        *maxLinks,
+		DuplicateWindow: *duplicateWindow,
+		PostLimit:       *postLimit,
+		CommentLimit:    *commentLimit,
+		VoteLimit:       *voteLimit,
+		PreviewLimit:    *previewLimit,
+		CaptchaForms:    config.SplitList(*captchaForms),
+		InviteOnly:      *inviteOnly,
+		InviteTrust:     *inviteTrust,
+		BaseURL:         strings.TrimSuffix(*baseURL, "/"),
+		MailFrom:        *mailFrom,
+		AttachmentTypes: config.SplitList(*attachmentTypes),
+		MaxFileSize:     *maxFileSize * 1024 * 1024,
+		MaxPostSize:     *maxPostSize * 1024 * 1024,
+	}
+
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ldate)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
@@ -29,9 +110,89 @@ func main() {
 
 	postRepo := repository.NewSqlPostsRepository(db)
 	userRepo := repository.NewSqlUsersRepository(db)
-	postUse := usecase.NewPostUsecase(postRepo, userRepo)
-	userUse := usecase.NewUserUsecase(postRepo, userRepo)
-	router := delivery.NewPostHandler(postUse, userUse, infoLog, errorLog)
+	filterRepo := repository.NewSqlFilterRepository(db)
+	webhookRepo := repository.NewSqlWebhookRepository(db)
+	notificationRepo := repository.NewSqlNotificationRepository(db)
+	digestRepo := repository.NewSqlDigestRepository(db)
+	var store models.MediaStore = &media.Local{Dir: *mediaDir}
+	if *s3Endpoint != "" {
+		store = &media.S3{
+			Endpoint:  *s3Endpoint,
+			Region:    *s3Region,
+			Bucket:    *s3Bucket,
+			AccessKey: *s3AccessKey,
+			SecretKey: *s3SecretKey,
+			Client:    &http.Client{Timeout: time.Minute},
+		}
+	}
+
+	filterUse := usecase.NewFilterUsecase(filterRepo, userRepo, cfg)
+	webhookUse := usecase.NewWebhookUsecase(webhookRepo, cfg)
+	notificationUse := usecase.NewNotificationUsecase(notificationRepo)
+	hub := live.NewHub()
+	runner := jobs.NewRunner(repository.NewSqlJobRepository(db), errorLog)
+	postUse := usecase.NewPostUsecase(postRepo, userRepo, filterUse, webhookUse, notificationUse, runner, store, hub, cfg, errorLog)
+	userUse := usecase.NewUserUsecase(postRepo, userRepo, webhookUse, store, cfg, errorLog)
+
+	// Moving the uploads of older versions into the media store;
+	if err = postUse.ImportImages(legacyImageDir, legacyImageURL); err != nil {
+		errorLog.Print(err)
+	}
+	if err = userUse.ImportAvatars(legacyAvatarDir); err != nil {
+		errorLog.Print(err)
+	}
+
+	var mailer mail.Mailer = &mail.Outbox{Dir: *mailOutbox}
+	if *smtpAddr != "" {
+		var auth smtp.Auth
+		if *smtpUser != "" {
+			host, _, _ := strings.Cut(*smtpAddr, ":")
+			auth = smtp.PlainAuth("", *smtpUser, *smtpPassword, host)
+		}
+		mailer = &mail.SMTP{Addr: *smtpAddr, Auth: auth}
+	}
+	digestUse, err := usecase.NewDigestUsecase(digestRepo, userRepo, mailer, cfg)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Registering the background jobs;
+	jobs.Handle(runner, "webhooks.deliver", jobs.Options{MaxAttempts: 1}, func(ctx context.Context, _ struct{}) error {
+		return webhookUse.Deliver(ctx)
+	})
+	jobs.Handle(runner, "digests.send", jobs.Options{MaxAttempts: 1}, func(ctx context.Context, _ struct{}) error {
+		return digestUse.SendDue(ctx)
+	})
+	jobs.Handle(runner, "sessions.cleanup", jobs.Options{}, func(context.Context, struct{}) error {
+		return userUse.RemoveExpiredTokens()
+	})
+	jobs.Handle(runner, models.JobThumbnail, jobs.Options{Concurrency: 2, MaxAttempts: 3, Timeout: time.Minute}, func(_ context.Context, post int) error {
+		return postUse.CreateThumbnail(post)
+	})
+	jobs.Handle(runner, "posts.publish", jobs.Options{MaxAttempts: 1}, func(ctx context.Context, _ struct{}) error {
+		return postUse.PublishScheduled(ctx)
+	})
+	jobs.Handle(runner, "images.cleanup", jobs.Options{}, func(context.Context, struct{}) error {
+		return postUse.CleanupImages(orphanImageAge)
+	})
+	jobs.Handle(runner, "jobs.prune", jobs.Options{}, func(context.Context, struct{}) error {
+		return runner.Prune(keepDoneJobs, keepFailedJobs)
+	})
+
+	for _, s := range []struct{ spec, kind string }{
+		{"@every 5s", "webhooks.deliver"},
+		{"@every 30s", "posts.publish"},
+		{"@every 15m", "digests.send"},
+		{"@hourly", "sessions.cleanup"},
+		{"@hourly", "jobs.prune"},
+		{"@daily", "images.cleanup"},
+	} {
+		if err = runner.Schedule(s.spec, s.kind, nil); err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
+	router := delivery.NewPostHandler(postUse, userUse, filterUse, webhookUse, notificationUse, digestUse, store, runner, hub, cfg, infoLog, errorLog)
 
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
@@ -48,8 +209,42 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// Ending the open event streams on shutdown, they would hold it up;
+	srv.RegisterOnShutdown(hub.Close)
+
+	if err = runner.Start(); err != nil {
+		errorLog.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Running the HTTP server
-	infoLog.Printf("Starting server on... http://127.0.0.1%s \n", *addr)
-	err = srv.ListenAndServe()
-	errorLog.Fatal(err)
+	serverErr := make(chan error, 1)
+	go func() {
+		infoLog.Printf("Starting server on... http://127.0.0.1%s \n", *addr)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErr:
+		errorLog.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	// Shutting down: the requests and the running jobs get some time to
+	// finish;
+	infoLog.Print("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err = srv.Shutdown(shutdownCtx); err != nil {
+		errorLog.Print(err)
+	}
+	if err = runner.Shutdown(shutdownCtx); err != nil {
+		errorLog.Print(err)
+	}
+	if err = db.Close(); err != nil {
+		errorLog.Print(err)
+	}
 }
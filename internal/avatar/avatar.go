@@ -0,0 +1,146 @@
+// Package avatar turns uploaded pictures into square avatars of fixed sizes
+// and draws the generated identicons used when a user has none.
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"math"
+
+	"forum.bbilisbe/internal/images"
+)
+
+// Sizes are the widths of the stored avatars in pixels.
+const (
+	Small = 32
+	Large = 128
+)
+
+var Sizes = []int{Small, Large}
+
+const (
+	// maxSide is the largest width or height of an uploaded picture, checked
+	// before it is decoded.
+	maxSide = 4096
+	// minSide is the smallest side of the cropped square.
+	minSide = Small
+)
+
+var (
+	ErrInvalid  = errors.New("avatar: not a PNG, JPEG or GIF image")
+	ErrTooLarge = errors.New("avatar: image too large")
+	ErrTooSmall = errors.New("avatar: image too small")
+)
+
+// Process() decodes the picture, cuts the square crop out of it and returns it
+// PNG encoded in each of the Sizes. An empty crop or one reaching outside of
+// the picture is replaced by the largest centred square. Re-encoding drops
+// any metadata of the upload.
+func Process(r io.Reader, crop image.Rectangle) (map[int][]byte, error) {
+	var buf bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &buf))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if cfg.Width > maxSide || cfg.Height > maxSide {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&buf, r))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	bounds := src.Bounds()
+
+	crop = crop.Add(bounds.Min)
+	if crop.Empty() || crop.Dx() != crop.Dy() || !crop.In(bounds) {
+		side := bounds.Dx()
+		if bounds.Dy() < side {
+			side = bounds.Dy()
+		}
+		x := bounds.Min.X + (bounds.Dx()-side)/2
+		y := bounds.Min.Y + (bounds.Dy()-side)/2
+		crop = image.Rect(x, y, x+side, y+side)
+	}
+	if crop.Dx() < minSide {
+		return nil, ErrTooSmall
+	}
+
+	square := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(square, square.Bounds(), src, crop.Min, draw.Src)
+
+	avatars := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		var out bytes.Buffer
+		if err = png.Encode(&out, images.Resize(square, size, size)); err != nil {
+			return nil, err
+		}
+		avatars[size] = out.Bytes()
+	}
+	return avatars, nil
+}
+
+// Identicon() draws a size×size PNG avatar for the seed: a symmetric 5×5
+// pattern in a colour, both derived from the SHA-256 of the seed so the same
+// seed always gets the same picture.
+func Identicon(seed string, size int) ([]byte, error) {
+	sum := sha256.Sum256([]byte(seed))
+
+	fg := hsl(float64(sum[0])/255*360, 0.55, 0.5)
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0xF0, 0xF0, 0xF0, 0xFF}}, image.Point{}, draw.Src)
+
+	cell := size / 6
+	margin := (size - 5*cell) / 2
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 3; col++ {
+			if sum[1+row*3+col]&1 == 0 {
+				continue
+			}
+			for _, c := range []int{col, 4 - col} {
+				r := image.Rect(margin+c*cell, margin+row*cell, margin+(c+1)*cell, margin+(row+1)*cell)
+				draw.Draw(img, r, &image.Uniform{fg}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// hsl() converts a colour from hue, saturation and lightness.
+func hsl(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	hp := h / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g = c, x
+	case hp < 2:
+		r, g = x, c
+	case hp < 3:
+		g, b = c, x
+	case hp < 4:
+		g, b = x, c
+	case hp < 5:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+
+	m := l - c/2
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 0xFF}
+}
@@ -0,0 +1,143 @@
+package captcha
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Challenge is an arithmetic question together with the signed token the form
+// sends back with the answer.
+type Challenge struct {
+	Question string
+	Token    string
+}
+
+// Captcha issues and verifies stateless arithmetic challenges. The answer is
+// only stored in the HMAC of the token; used tokens are remembered until they
+// expire so a solved challenge can't be replayed.
+type Captcha struct {
+	secret []byte
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func New(ttl time.Duration) (*Captcha, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &Captcha{
+		secret: secret,
+		ttl:    ttl,
+		used:   make(map[string]time.Time),
+	}, nil
+}
+
+func (c *Captcha) New() (*Challenge, error) {
+	a, err := randInt(1, 20)
+	if err != nil {
+		return nil, err
+	}
+	b, err := randInt(1, 20)
+	if err != nil {
+		return nil, err
+	}
+	op, err := randInt(0, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	var question string
+	var answer int
+	switch op {
+	case 0:
+		question, answer = fmt.Sprintf("%d + %d", a, b), a+b
+	case 1:
+		if a < b {
+			a, b = b, a
+		}
+		question, answer = fmt.Sprintf("%d - %d", a, b), a-b
+	default:
+		a, b = a%10+1, b%10+1
+		question, answer = fmt.Sprintf("%d × %d", a, b), a*b
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	expiry := make([]byte, 8)
+	binary.BigEndian.PutUint64(expiry, uint64(time.Now().Add(c.ttl).Unix()))
+
+	payload := append(expiry, nonce...)
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(c.sign(payload, answer))
+
+	return &Challenge{Question: "What is " + question + "?", Token: token}, nil
+}
+
+// Verify checks the answer against the token. Every token can be used once.
+func (c *Captcha) Verify(token, answer string) bool {
+	n, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil {
+		return false
+	}
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) != 24 {
+		return false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, c.sign(payload, n)) {
+		return false
+	}
+
+	expiry := time.Unix(int64(binary.BigEndian.Uint64(payload[:8])), 0)
+	now := time.Now()
+	if now.After(expiry) {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for t, exp := range c.used {
+		if now.After(exp) {
+			delete(c.used, t)
+		}
+	}
+	if _, seen := c.used[encoded]; seen {
+		return false
+	}
+	c.used[encoded] = expiry
+	return true
+}
+
+func (c *Captcha) sign(payload []byte, answer int) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	mac.Write([]byte(strconv.Itoa(answer)))
+	return mac.Sum(nil)
+}
+
+// randInt returns a uniform random number in [min, max].
+func randInt(min, max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min+1)))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Config holds the runtime settings parsed from the command line flags and
+// shared between the usecases and the handlers.
+type Config struct {
+	// Posts written by accounts younger than HoldAge or with a trust score
+	// below HoldTrust are held in the moderation queue.
+	HoldAge   time.Duration
+	HoldTrust int
+	// Moderators is the list of usernames allowed to review held posts,
+	// Admins additionally manage the forum settings.
+	Moderators []string
+	Admins     []string
+	// Content filter thresholds: the score at which content is held or
+	// rejected, the links a new account may post and the window in which
+	// duplicate content is looked for.
+	FilterHold      int
+	FilterReject    int
+	MaxLinks        int
+	DuplicateWindow time.Duration
+	// Requests per hour a user may send to create posts, comment, vote and
+	// preview the Markdown of a post.
+	PostLimit    int
+	CommentLimit int
+	VoteLimit    int
+	PreviewLimit int
+	// CaptchaForms lists the forms which require solving a CAPTCHA:
+	// "signup", "login", "comment" and "report".
+	CaptchaForms []string
+	// In invite only mode signing up requires an invite code. Codes can be
+	// created by admins, moderators and users with at least InviteTrust.
+	InviteOnly  bool
+	InviteTrust int
+	// BaseURL is the public address of the forum used in absolute links,
+	// e.g. in feeds. When empty it is taken from the request.
+	BaseURL string
+	// MailFrom is the sender address of the emails, e.g. the digests.
+	MailFrom string
+	// AttachmentTypes lists the MIME types of the files other than images
+	// which can be attached to posts. MaxFileSize limits each attachment and
+	// MaxPostSize all the attachments of a post, in bytes.
+	AttachmentTypes []string
+	MaxFileSize     int64
+	MaxPostSize     int64
+}
+
+// Has() reports whether the list contains the value.
+func Has(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitList() turns a comma separated flag value into a slice, skipping empty
+// entries.
+func SplitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,76 @@
+package filter
+
+// Verdict is the decision the pipeline takes on a piece of content.
+type Verdict int
+
+const (
+	Allow Verdict = iota
+	Hold
+	Reject
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// Content is a piece of user text going through the pipeline. Rules may
+// rewrite Title and Body, e.g. to censor words.
+type Content struct {
+	Author  int
+	NewUser bool
+	Title   string
+	Body    string
+}
+
+// Rule inspects the content and returns the spam score it adds together with
+// a short reason when the score is not zero.
+type Rule interface {
+	Check(c *Content) (int, string, error)
+}
+
+type Result struct {
+	Verdict Verdict
+	Score   int
+	Reasons []string
+}
+
+// Pipeline runs every rule over the content and sums their scores. Content
+// reaching RejectScore is rejected, content reaching HoldScore is held for
+// moderation.
+type Pipeline struct {
+	Rules       []Rule
+	HoldScore   int
+	RejectScore int
+}
+
+func (p *Pipeline) Run(c *Content) (Result, error) {
+	var result Result
+
+	for _, rule := range p.Rules {
+		score, reason, err := rule.Check(c)
+		if err != nil {
+			return Result{}, err
+		}
+		if score != 0 {
+			result.Score += score
+			result.Reasons = append(result.Reasons, reason)
+		}
+	}
+
+	switch {
+	case p.RejectScore > 0 && result.Score >= p.RejectScore:
+		result.Verdict = Reject
+	case p.HoldScore > 0 && result.Score >= p.HoldScore:
+		result.Verdict = Hold
+	default:
+		result.Verdict = Allow
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// scoreRule adds a fixed score.
+type scoreRule struct {
+	score  int
+	reason string
+	err    error
+}
+
+func (r scoreRule) Check(*Content) (int, string, error) {
+	return r.score, r.reason, r.err
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		hold    int
+		reject  int
+		verdict Verdict
+		score   int
+		reasons []string
+	}{
+		{"no rules", nil, 3, 6, Allow, 0, nil},
+		{"below hold", []Rule{scoreRule{2, "a", nil}}, 3, 6, Allow, 2, []string{"a"}},
+		{"at hold", []Rule{scoreRule{2, "a", nil}, scoreRule{1, "b", nil}}, 3, 6, Hold, 3, []string{"a", "b"}},
+		{"at reject", []Rule{scoreRule{4, "a", nil}, scoreRule{2, "b", nil}}, 3, 6, Reject, 6, []string{"a", "b"}},
+		{"zero scores skipped", []Rule{scoreRule{0, "", nil}, scoreRule{3, "b", nil}}, 3, 6, Hold, 3, []string{"b"}},
+		{"hold disabled", []Rule{scoreRule{5, "a", nil}}, 0, 6, Allow, 5, []string{"a"}},
+		{"reject disabled", []Rule{scoreRule{50, "a", nil}}, 3, 0, Hold, 50, []string{"a"}},
+	}
+	for _, tt := range tests {
+		p := &Pipeline{Rules: tt.rules, HoldScore: tt.hold, RejectScore: tt.reject}
+		result, err := p.Run(&Content{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Verdict != tt.verdict || result.Score != tt.score || !reflect.DeepEqual(result.Reasons, tt.reasons) {
+			t.Errorf("%s: got %v %d %v, want %v %d %v", tt.name, result.Verdict, result.Score, result.Reasons, tt.verdict, tt.score, tt.reasons)
+		}
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	p := &Pipeline{Rules: []Rule{scoreRule{1, "a", nil}, scoreRule{0, "", errors.New("broken")}}, HoldScore: 1}
+	if _, err := p.Run(&Content{}); err == nil {
+		t.Error("the error of the rule was lost")
+	}
+}
+
+func TestPipelineRewrites(t *testing.T) {
+	words, err := NewWordRule([]Word{{Pattern: "darn", Replacement: "d**n", Score: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Pipeline{Rules: []Rule{words}, HoldScore: 1, RejectScore: 10}
+	c := &Content{Body: "darn it"}
+	result, err := p.Run(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Hold || c.Body != "d**n it" {
+		t.Errorf("got %v %q", result.Verdict, c.Body)
+	}
+}
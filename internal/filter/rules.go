@@ -0,0 +1,152 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Word is an entry of the blocked word list. A matching word adds Score and,
+// when Replacement is set, is replaced in the content.
+type Word struct {
+	Pattern     string
+	Regex       bool
+	Replacement string
+	Score       int
+}
+
+type compiledWord struct {
+	Word
+	rx *regexp.Regexp
+}
+
+type WordRule struct {
+	words []compiledWord
+}
+
+// CompileWord() builds the case insensitive expression for the word. Plain
+// words only match on word boundaries.
+func CompileWord(w Word) (*regexp.Regexp, error) {
+	pattern := w.Pattern
+	if !w.Regex {
+		pattern = `\b` + regexp.QuoteMeta(pattern) + `\b`
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+func NewWordRule(words []Word) (*WordRule, error) {
+	rule := &WordRule{}
+	for _, w := range words {
+		rx, err := CompileWord(w)
+		if err != nil {
+			return nil, fmt.Errorf("filter: word %q: %w", w.Pattern, err)
+		}
+		rule.words = append(rule.words, compiledWord{w, rx})
+	}
+	return rule, nil
+}
+
+func (r *WordRule) Check(c *Content) (int, string, error) {
+	var score int
+	var matched []string
+
+	for _, w := range r.words {
+		if !w.rx.MatchString(c.Title) && !w.rx.MatchString(c.Body) {
+			continue
+		}
+		score += w.Score
+		matched = append(matched, w.Pattern)
+		if w.Replacement != "" {
+			c.Title = w.rx.ReplaceAllLiteralString(c.Title, w.Replacement)
+			c.Body = w.rx.ReplaceAllLiteralString(c.Body, w.Replacement)
+		}
+	}
+	return score, "blocked words: " + strings.Join(matched, ", "), nil
+}
+
+var linkRX = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkRule limits the number of links new users may post.
+type LinkRule struct {
+	Max   int
+	Score int
+}
+
+func (r *LinkRule) Check(c *Content) (int, string, error) {
+	if !c.NewUser {
+		return 0, "", nil
+	}
+	links := len(linkRX.FindAllString(c.Title, -1)) + len(linkRX.FindAllString(c.Body, -1))
+	if links <= r.Max {
+		return 0, "", nil
+	}
+	return r.Score, fmt.Sprintf("%d links from a new account", links), nil
+}
+
+// History provides the texts recently posted on the forum.
+type History interface {
+	Recent() ([]string, error)
+}
+
+// DuplicateRule catches content which is the same or nearly the same as
+// something posted recently. Similarity is the Jaccard index of the word
+// shingles of both texts; texts shorter than MinWords are not compared.
+type DuplicateRule struct {
+	History    History
+	Similarity float64
+	MinWords   int
+	Score      int
+}
+
+func (r *DuplicateRule) Check(c *Content) (int, string, error) {
+	text := normalize(c.Body)
+	if text == "" || len(strings.Fields(text)) < r.MinWords {
+		return 0, "", nil
+	}
+	recent, err := r.History.Recent()
+	if err != nil {
+		return 0, "", err
+	}
+
+	shingles := shingle(text)
+	for _, other := range recent {
+		if jaccard(shingles, shingle(normalize(other))) >= r.Similarity {
+			return r.Score, "duplicate of recent content", nil
+		}
+	}
+	return 0, "", nil
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+const shingleSize = 3
+
+func shingle(text string) map[string]struct{} {
+	set := make(map[string]struct{})
+	words := strings.Fields(text)
+	if len(words) < shingleSize {
+		if len(words) > 0 {
+			set[text] = struct{}{}
+		}
+		return set
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		set[strings.Join(words[i:i+shingleSize], " ")] = struct{}{}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var common int
+	for s := range a {
+		if _, ok := b[s]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWordRule(t *testing.T) {
+	rule, err := NewWordRule([]Word{
+		{Pattern: "spam", Score: 2, Replacement: "****"},
+		{Pattern: `fr[e3]{2}\s*money`, Regex: true, Score: 5},
+		{Pattern: "v.i.p", Score: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		title     string
+		body      string
+		score     int
+		wantTitle string
+		wantBody  string
+	}{
+		{"clean", "Hello", "A good post", 0, "Hello", "A good post"},
+		{"plain word", "SPAM here", "more spam.", 2, "**** here", "more ****."},
+		{"inside a word", "", "spammer and antispam", 0, "", "spammer and antispam"},
+		{"regex", "", "Get FR33 money now", 5, "", "Get FR33 money now"},
+		{"regex without space", "", "freemoney", 5, "", "freemoney"},
+		{"quoted plain word", "", "a V.I.P offer", 1, "", "a V.I.P offer"},
+		{"not a regex", "", "a vxixp offer", 0, "", "a vxixp offer"},
+		{"several", "spam", "free money", 7, "****", "free money"},
+	}
+	for _, tt := range tests {
+		c := &Content{Title: tt.title, Body: tt.body}
+		score, _, err := rule.Check(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score != tt.score || c.Title != tt.wantTitle || c.Body != tt.wantBody {
+			t.Errorf("%s: got %d %q %q, want %d %q %q", tt.name, score, c.Title, c.Body, tt.score, tt.wantTitle, tt.wantBody)
+		}
+	}
+}
+
+func TestNewWordRuleInvalid(t *testing.T) {
+	if _, err := NewWordRule([]Word{{Pattern: "(", Regex: true}}); err == nil {
+		t.Error("an invalid expression was accepted")
+	}
+}
+
+func TestLinkRule(t *testing.T) {
+	rule := &LinkRule{Max: 2, Score: 3}
+
+	tests := []struct {
+		name    string
+		newUser bool
+		title   string
+		body    string
+		score   int
+	}{
+		{"no links", true, "", "just text", 0},
+		{"at the limit", true, "", "https://a.example and www.b.example", 0},
+		{"title counts", true, "http://t.example", "https://a.example www.b.example", 3},
+		{"over the limit", true, "", "https://a.example https://b.example HTTPS://c.example", 3},
+		{"old account", false, "", "https://a.example https://b.example https://c.example", 0},
+	}
+	for _, tt := range tests {
+		score, _, err := rule.Check(&Content{NewUser: tt.newUser, Title: tt.title, Body: tt.body})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score != tt.score {
+			t.Errorf("%s: score %d, want %d", tt.name, score, tt.score)
+		}
+	}
+}
+
+type history []string
+
+func (h history) Recent() ([]string, error) {
+	return h, nil
+}
+
+type failingHistory struct{}
+
+func (failingHistory) Recent() ([]string, error) {
+	return nil, errors.New("no history")
+}
+
+func TestDuplicateRule(t *testing.T) {
+	// Ten words make eight shingles. Changing the last word keeps seven of
+	// them, similarity 7/9; changing the last two keeps six, 6/10.
+	original := "one two three four five six seven eight nine ten"
+	oneChanged := "one two three four five six seven eight nine eleven"
+	twoChanged := "one two three four five six seven eight twelve eleven"
+
+	tests := []struct {
+		name       string
+		similarity float64
+		body       string
+		score      int
+	}{
+		{"same", 0.7, original, 4},
+		{"case and spaces", 0.7, "ONE two  three\nfour five six seven eight nine TEN", 4},
+		{"above the threshold", 0.7, oneChanged, 4},
+		{"at the threshold", 7.0 / 9, oneChanged, 4},
+		{"just below the threshold", 7.0/9 + 0.01, oneChanged, 0},
+		{"below the threshold", 0.7, twoChanged, 0},
+		{"too short", 0.7, "one two three", 0},
+		{"empty", 0.7, "   ", 0},
+	}
+	for _, tt := range tests {
+		rule := &DuplicateRule{History: history{"unrelated text of the forum", original}, Similarity: tt.similarity, MinWords: 5, Score: 4}
+		score, _, err := rule.Check(&Content{Body: tt.body})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score != tt.score {
+			t.Errorf("%s: score %d, want %d", tt.name, score, tt.score)
+		}
+	}
+}
+
+func TestDuplicateRuleHistoryError(t *testing.T) {
+	rule := &DuplicateRule{History: failingHistory{}, Similarity: 0.7, MinWords: 1, Score: 4}
+	if _, _, err := rule.Check(&Content{Body: strings.Repeat("word ", 10)}); err == nil {
+		t.Error("the error of the history was lost")
+	}
+}
@@ -0,0 +1,379 @@
+// Package highlight splits source code into tokens for syntax highlighting.
+//
+// The lexers are simple: they know the comments, strings, numbers and
+// keywords of each language, which is enough to colour the snippets shared
+// in posts. The tokens carry their kind only, the caller decides how to show
+// them.
+package highlight
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The kinds of tokens.
+const (
+	Plain     = ""
+	Keyword   = "kw"
+	Type      = "type"
+	Literal   = "lit"
+	String    = "str"
+	Comment   = "com"
+	Number    = "num"
+	Function  = "fn"
+	Variable  = "var"
+	Tag       = "tag"
+	Attribute = "attr"
+)
+
+// Token is a piece of the code. Joined, the tokens give back the code.
+type Token struct {
+	Kind string
+	Text string
+}
+
+// Tokens splits the code written in the language, named by the tag of a
+// fenced code block such as "go" or "js". It reports false for the languages
+// it doesn't know.
+func Tokens(lang, code string) ([]Token, bool) {
+	l, ok := languages[strings.ToLower(lang)]
+	if !ok {
+		return nil, false
+	}
+	if l.markup {
+		return markupTokens(code), true
+	}
+	return l.tokens(code), true
+}
+
+// language describes the lexical syntax of a programming language.
+type language struct {
+	keywords, types, literals map[string]bool
+	// lineComments and blockComments start comments, the latter are
+	// closed by the second string of the pair.
+	lineComments  []string
+	blockComments [][2]string
+	// quotes are the characters starting strings; those in multiline may
+	// span lines and raw ones don't have escapes. long are delimiters of
+	// multiline strings like Python's """.
+	quotes, multiline, raw string
+	long                   []string
+	// sigils start variables, like $ in shell scripts, or keywords for
+	// keywordSigils, like @ of CSS at-rules.
+	sigils, keywordSigils string
+	// keys marks names and strings followed by a colon as attributes, as
+	// in JSON and YAML.
+	keys bool
+	// caseless languages match keywords in any case.
+	caseless bool
+	markup   bool
+}
+
+type lexer struct {
+	code   string
+	tokens []Token
+	// start is where the plain text not yet added begins.
+	start int
+}
+
+// emit adds the token code[from:to] after the plain text before it.
+func (lx *lexer) emit(kind string, from, to int) {
+	if from > lx.start {
+		lx.add(Plain, lx.code[lx.start:from])
+	}
+	lx.add(kind, lx.code[from:to])
+	lx.start = to
+}
+
+func (lx *lexer) add(kind, text string) {
+	if n := len(lx.tokens); n > 0 && lx.tokens[n-1].Kind == kind {
+		lx.tokens[n-1].Text += text
+		return
+	}
+	lx.tokens = append(lx.tokens, Token{Kind: kind, Text: text})
+}
+
+func (lx *lexer) finish() []Token {
+	if lx.start < len(lx.code) {
+		lx.add(Plain, lx.code[lx.start:])
+	}
+	return lx.tokens
+}
+
+func (l *language) tokens(code string) []Token {
+	lx := &lexer{code: code}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		if prefix := hasPrefix(rest, l.lineComments); prefix != "" {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			lx.emit(Comment, i, i+end)
+			i += end
+			continue
+		}
+		if pair, ok := blockPrefix(rest, l.blockComments); ok {
+			end := strings.Index(rest[len(pair[0]):], pair[1])
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(pair[0]) + len(pair[1])
+			}
+			lx.emit(Comment, i, i+end)
+			i += end
+			continue
+		}
+		if delim := hasPrefix(rest, l.long); delim != "" {
+			end := strings.Index(rest[len(delim):], delim)
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 2 * len(delim)
+			}
+			lx.emit(String, i, i+end)
+			i += end
+			continue
+		}
+
+		c := code[i]
+		switch {
+		case strings.IndexByte(l.quotes, c) >= 0:
+			end := i + l.stringEnd(rest)
+			kind := String
+			if l.keys && followedBy(code, end, ':') {
+				kind = Attribute
+			}
+			lx.emit(kind, i, end)
+			i = end
+
+		case isDigit(c) || (c == '.' && i+1 < len(code) && isDigit(code[i+1])):
+			if i > 0 && isWord(code[i-1]) {
+				i++
+				continue
+			}
+			end := i + 1
+			for end < len(code) && (isWord(code[end]) || code[end] == '.' ||
+				((code[end] == '+' || code[end] == '-') && (code[end-1] == 'e' || code[end-1] == 'E') && !strings.HasPrefix(code[i:], "0x"))) {
+				end++
+			}
+			lx.emit(Number, i, end)
+			i = end
+
+		case strings.IndexByte(l.sigils, c) >= 0 || strings.IndexByte(l.keywordSigils, c) >= 0:
+			end := i + 1
+			if end < len(code) && code[end] == '{' {
+				if close := strings.IndexByte(code[end:], '}'); close >= 0 {
+					end += close + 1
+				}
+			} else {
+				end = wordEnd(code, end)
+			}
+			if end == i+1 {
+				i++
+				continue
+			}
+			kind := Variable
+			if strings.IndexByte(l.keywordSigils, c) >= 0 {
+				kind = Keyword
+			}
+			lx.emit(kind, i, end)
+			i = end
+
+		case isWordStart(code, i):
+			end := wordEnd(code, i)
+			if kind := l.word(code, i, end); kind != Plain {
+				lx.emit(kind, i, end)
+			}
+			i = end
+
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			i += size
+		}
+	}
+	return lx.finish()
+}
+
+// word returns the kind of the name code[from:to].
+func (l *language) word(code string, from, to int) string {
+	name := code[from:to]
+	if l.caseless {
+		name = strings.ToLower(name)
+	}
+	switch {
+	case l.keys && followedBy(code, to, ':') && !strings.HasPrefix(code[to:], "::"):
+		return Attribute
+	case l.keywords[name]:
+		return Keyword
+	case l.literals[name]:
+		return Literal
+	case l.types[name]:
+		return Type
+	case followedBy(code, to, '('):
+		return Function
+	}
+	return Plain
+}
+
+// stringEnd returns the length of the string starting at s[0].
+func (l *language) stringEnd(s string) int {
+	quote := s[0]
+	multiline := strings.IndexByte(l.multiline, quote) >= 0
+	raw := strings.IndexByte(l.raw, quote) >= 0
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if !raw {
+				i++
+			}
+		case '\n':
+			if !multiline {
+				return i
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// markupTokens splits HTML and XML: tags with their attributes, comments and
+// entities.
+func markupTokens(code string) []Token {
+	lx := &lexer{code: code}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 3
+			}
+			lx.emit(Comment, i, i+end)
+			i += end
+
+		case rest[0] == '<' && len(rest) > 1 && (rest[1] == '/' || rest[1] == '!' || rest[1] == '?' || unicode.IsLetter(rune(rest[1]))):
+			end := i + 1
+			for end < len(code) && strings.IndexByte("/!?", code[end]) >= 0 {
+				end++
+			}
+			end = nameEnd(code, end)
+			lx.emit(Tag, i, end)
+			i = tagEnd(lx, end)
+
+		case rest[0] == '&':
+			end := strings.IndexByte(rest, ';')
+			if end > 0 && end < 10 && !strings.ContainsAny(rest[:end], " \n<&") {
+				lx.emit(Literal, i, i+end+1)
+				i += end + 1
+				continue
+			}
+			i++
+
+		default:
+			i++
+		}
+	}
+	return lx.finish()
+}
+
+// tagEnd adds the attributes of a tag whose name ends at i, up to the end of
+// the tag, and returns the position after it.
+func tagEnd(lx *lexer, i int) int {
+	code := lx.code
+	for i < len(code) {
+		switch c := code[i]; {
+		case c == '>':
+			lx.emit(Tag, i, i+1)
+			return i + 1
+		case c == '/' || c == '?':
+			if i+1 < len(code) && code[i+1] == '>' {
+				lx.emit(Tag, i, i+2)
+				return i + 2
+			}
+			i++
+		case c == '"' || c == '\'':
+			end := len(code)
+			if close := strings.IndexByte(code[i+1:], c); close >= 0 {
+				end = i + close + 2
+			}
+			lx.emit(String, i, end)
+			i = end
+		case c == '<':
+			return i
+		case unicode.IsLetter(rune(c)) || c == '_' || c == ':' || c == '@':
+			end := nameEnd(code, i+1)
+			lx.emit(Attribute, i, end)
+			i = end
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+func nameEnd(code string, i int) int {
+	for i < len(code) && (isWord(code[i]) || strings.IndexByte("-:.", code[i]) >= 0) {
+		i++
+	}
+	return i
+}
+
+func hasPrefix(s string, prefixes []string) string {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+func blockPrefix(s string, pairs [][2]string) ([2]string, bool) {
+	for _, pair := range pairs {
+		if strings.HasPrefix(s, pair[0]) {
+			return pair, true
+		}
+	}
+	return [2]string{}, false
+}
+
+// followedBy reports whether the first character after code[:i] other than
+// spaces and tabs is c.
+func followedBy(code string, i int, c byte) bool {
+	for i < len(code) && (code[i] == ' ' || code[i] == '\t') {
+		i++
+	}
+	return i < len(code) && code[i] == c
+}
+
+func isWordStart(code string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(code[i:])
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// wordEnd returns the end of the name starting at code[i].
+func wordEnd(code string, i int) int {
+	for i < len(code) {
+		r, size := utf8.DecodeRuneInString(code[i:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWord(c byte) bool {
+	return c == '_' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
@@ -0,0 +1,258 @@
+package highlight
+
+import "strings"
+
+// words returns the set of the space separated words.
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		set[w] = true
+	}
+	return set
+}
+
+var (
+	golang = &language{
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var`),
+		types: words(`any bool byte comparable complex64 complex128 error float32 float64 int int8 int16 int32 int64
+			rune string uint uint8 uint16 uint32 uint64 uintptr append cap clear close complex copy delete imag
+			len make max min new panic print println real recover`),
+		literals:      words(`true false nil iota`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+		multiline:     "`",
+		raw:           "`",
+	}
+
+	c = &language{
+		keywords: words(`auto break case const continue default do else enum extern for goto if inline register
+			restrict return sizeof static struct switch typedef union volatile while`),
+		types:         words(`char double float int long short signed unsigned void bool size_t int8_t int16_t int32_t int64_t uint8_t uint16_t uint32_t uint64_t FILE`),
+		literals:      words(`true false NULL`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		keywordSigils: "#",
+	}
+
+	cpp = &language{
+		keywords: words(`alignas alignof auto break case catch class const constexpr const_cast continue decltype
+			default delete do dynamic_cast else enum explicit export extern for friend goto if inline mutable
+			namespace new noexcept operator private protected public reinterpret_cast return sizeof static
+			static_assert static_cast struct switch template this throw try typedef typeid typename union using
+			virtual volatile while override final`),
+		types:         words(`bool char char16_t char32_t double float int long short signed unsigned void wchar_t size_t string vector map set std`),
+		literals:      words(`true false nullptr NULL`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		keywordSigils: "#",
+	}
+
+	csharp = &language{
+		keywords: words(`abstract as async await base break case catch checked class const continue default
+			delegate do else enum event explicit extern finally fixed for foreach goto if implicit in interface
+			internal is lock namespace new operator out override params private protected public readonly ref
+			return sealed sizeof stackalloc static struct switch this throw try typeof unchecked unsafe using
+			var virtual void volatile while yield record`),
+		types:         words(`bool byte char decimal double float int long object sbyte short string uint ulong ushort dynamic`),
+		literals:      words(`true false null`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+	}
+
+	java = &language{
+		keywords: words(`abstract assert break case catch class const continue default do else enum extends
+			final finally for goto if implements import instanceof interface native new package private
+			protected public return static strictfp super switch synchronized this throw throws transient try
+			var void volatile while record yield`),
+		types:         words(`boolean byte char double float int long short String Object Integer Long Double List Map`),
+		literals:      words(`true false null`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+	}
+
+	kotlin = &language{
+		keywords: words(`as break class continue do else for fun if in interface is object package return super
+			this throw try typealias typeof val var when while by catch constructor data enum finally get import
+			init internal lateinit open out override private protected public sealed set suspend companion`),
+		types:         words(`Any Boolean Byte Char Double Float Int Long Nothing Short String Unit List Map Set`),
+		literals:      words(`true false null`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		long:          []string{`"""`},
+	}
+
+	javascript = &language{
+		keywords: words(`async await break case catch class const continue debugger default delete do else
+			export extends finally for from function if import in instanceof let new of return static super
+			switch this throw try typeof var void while with yield`),
+		types:         words(`Array Boolean Date Error JSON Map Math Number Object Promise RegExp Set String Symbol console document window`),
+		literals:      words(`true false null undefined NaN Infinity`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+		multiline:     "`",
+	}
+
+	typescript = &language{
+		keywords: words(`abstract as async await break case catch class const continue debugger declare default
+			delete do else enum export extends finally for from function if implements import in infer
+			instanceof interface is keyof let namespace new of private protected public readonly return static
+			super switch this throw try type typeof var void while with yield`),
+		types:         words(`any boolean never number object string symbol unknown void Array Date Error Map Promise Record Set`),
+		literals:      words(`true false null undefined NaN Infinity`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+		multiline:     "`",
+	}
+
+	python = &language{
+		keywords: words(`and as assert async await break class continue def del elif else except finally for
+			from global if import in is lambda nonlocal not or pass raise return try while with yield match case`),
+		types:        words(`bool bytes dict float int list object set str tuple len print range open super self cls isinstance enumerate zip`),
+		literals:     words(`True False None`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		long:         []string{`"""`, `'''`},
+		sigils:       "@",
+	}
+
+	ruby = &language{
+		keywords: words(`alias and begin break case class def defined do else elsif end ensure for if in module
+			next not or redo rescue retry return self super then undef unless until when while yield require
+			attr_accessor attr_reader attr_writer puts`),
+		types:        words(`Array Hash Integer Float String Symbol Object Kernel`),
+		literals:     words(`true false nil`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		multiline:    `"'`,
+		sigils:       "@$",
+	}
+
+	rust = &language{
+		keywords: words(`as async await break const continue crate dyn else enum extern fn for if impl in let
+			loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while`),
+		types: words(`bool char f32 f64 i8 i16 i32 i64 i128 isize str u8 u16 u32 u64 u128 usize String Vec Option
+			Result Box Rc Arc HashMap Some None Ok Err`),
+		literals:      words(`true false`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"`,
+		multiline:     `"`,
+	}
+
+	php = &language{
+		keywords: words(`abstract and array as break callable case catch class clone const continue declare
+			default do echo else elseif empty enddeclare endfor endforeach endif endswitch endwhile extends final
+			finally fn for foreach function global goto if implements include include_once instanceof insteadof
+			interface isset list match namespace new or print private protected public readonly require
+			require_once return static switch throw trait try unset use var while xor yield`),
+		types:         words(`bool int float string void mixed object iterable self parent`),
+		literals:      words(`true false null TRUE FALSE NULL`),
+		lineComments:  []string{"//", "#"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		multiline:     `"'`,
+		sigils:        "$",
+	}
+
+	sql = &language{
+		keywords: words(`add all alter and as asc autoincrement begin between by case check column commit
+			constraint create cross default delete desc distinct drop else end exists foreign from full group
+			having if in index inner insert into is join key left like limit not null offset on or order outer
+			primary references replace returning right rollback select set table then transaction union unique
+			update values view when where with`),
+		types:         words(`bigint blob boolean char date datetime decimal double float int integer numeric real text timestamp varchar count sum avg min max coalesce`),
+		literals:      words(`true false`),
+		lineComments:  []string{"--"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		multiline:     `'`,
+		caseless:      true,
+	}
+
+	shell = &language{
+		keywords: words(`case do done elif else esac fi for function if in local return select then until while
+			export readonly source alias cd echo exit set shift trap unset`),
+		types:        words(`awk cat chmod chown cp curl find git go grep ls make mkdir mv rm sed sudo tar`),
+		literals:     words(`true false`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		multiline:    `"'`,
+		raw:          `'`,
+		sigils:       "$",
+	}
+
+	json = &language{
+		literals: words(`true false null`),
+		quotes:   `"`,
+		keys:     true,
+	}
+
+	yaml = &language{
+		literals:     words(`true false null yes no on off`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		keys:         true,
+	}
+
+	css = &language{
+		keywords:      words(`important inherit initial unset auto none`),
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		keywordSigils: "@",
+		keys:          true,
+	}
+
+	markup = &language{markup: true}
+)
+
+// languages are keyed by the tags of fenced code blocks.
+var languages = map[string]*language{
+	"go":         golang,
+	"golang":     golang,
+	"c":          c,
+	"h":          c,
+	"cpp":        cpp,
+	"c++":        cpp,
+	"cc":         cpp,
+	"hpp":        cpp,
+	"cs":         csharp,
+	"csharp":     csharp,
+	"java":       java,
+	"kotlin":     kotlin,
+	"kt":         kotlin,
+	"js":         javascript,
+	"javascript": javascript,
+	"jsx":        javascript,
+	"mjs":        javascript,
+	"ts":         typescript,
+	"typescript": typescript,
+	"tsx":        typescript,
+	"py":         python,
+	"python":     python,
+	"rb":         ruby,
+	"ruby":       ruby,
+	"rs":         rust,
+	"rust":       rust,
+	"php":        php,
+	"sql":        sql,
+	"sh":         shell,
+	"bash":       shell,
+	"shell":      shell,
+	"zsh":        shell,
+	"json":       json,
+	"yaml":       yaml,
+	"yml":        yaml,
+	"css":        css,
+	"html":       markup,
+	"xml":        markup,
+	"svg":        markup,
+}
@@ -0,0 +1,358 @@
+// Package images checks uploaded pictures and prepares them for the forum:
+// the real type is sniffed from the content, the picture is decoded and
+// encoded again, which drops its metadata such as the EXIF GPS position, and
+// thumbnails are scaled down from it.
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxSide is the largest width or height of an accepted picture in
+	// pixels.
+	MaxSide = 6000
+	// MaxFrames is the largest number of frames of an animated GIF.
+	MaxFrames = 500
+	// maxGIFPixels bounds the pixels of all the frames of a GIF together,
+	// a byte each once decoded.
+	maxGIFPixels = 100 << 20
+	// jpegQuality is the quality pictures are encoded again with.
+	jpegQuality = 90
+)
+
+var (
+	ErrUnsupported = errors.New("images: not a PNG, JPEG or GIF image")
+	ErrInvalid     = errors.New("images: the image can't be decoded")
+	ErrTooLarge    = errors.New("images: the image is too large")
+	ErrTooLong     = errors.New("images: the animation has too many frames")
+)
+
+// Extensions of the supported types.
+var extensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+// Image is a processed picture.
+type Image struct {
+	Data   []byte
+	Type   string
+	Ext    string
+	Width  int
+	Height int
+}
+
+// Process() checks that data holds a PNG, JPEG or GIF picture of at most
+// MaxSide pixels each way and encodes it again without its metadata. JPEG
+// pictures are turned upright following their EXIF orientation first since
+// it is lost; animated GIFs keep their frames, of which there are at most
+// MaxFrames and no more pixels than decoding should allocate.
+func Process(data []byte) (*Image, error) {
+	typ := http.DetectContentType(data)
+	ext, ok := extensions[typ]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if cfg.Width > MaxSide || cfg.Height > MaxSide {
+		return nil, ErrTooLarge
+	}
+
+	var out bytes.Buffer
+	var bounds image.Rectangle
+
+	switch typ {
+	case "image/gif":
+		frames, pixels, err := gifFrames(data)
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		if frames > MaxFrames || pixels > maxGIFPixels {
+			return nil, ErrTooLong
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		// Only the frames, their timing and the loop count are kept.
+		g = &gif.GIF{Image: g.Image, Delay: g.Delay, Disposal: g.Disposal, LoopCount: g.LoopCount, Config: g.Config, BackgroundIndex: g.BackgroundIndex}
+		if err = gif.EncodeAll(&out, g); err != nil {
+			return nil, err
+		}
+		bounds = image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		img = orient(img, orientation(data))
+		if err = jpeg.Encode(&out, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		bounds = img.Bounds()
+	default:
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		if err = png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+		bounds = img.Bounds()
+	}
+
+	return &Image{Data: out.Bytes(), Type: typ, Ext: ext, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// Thumbnail() scales a processed picture down to fit in a size×size square,
+// the first frame of an animated GIF. JPEG pictures stay JPEG, the others
+// become PNG.
+func Thumbnail(data []byte, size int) (*Image, error) {
+	src, typ, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, h*size/w
+		} else {
+			w, h = w*size/h, size
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := Resize(src, w, h)
+
+	var out bytes.Buffer
+	thumb := &Image{Width: w, Height: h}
+	if typ == "jpeg" {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: jpegQuality})
+		thumb.Type, thumb.Ext = "image/jpeg", ".jpg"
+	} else {
+		err = png.Encode(&out, dst)
+		thumb.Type, thumb.Ext = "image/png", ".png"
+	}
+	if err != nil {
+		return nil, err
+	}
+	thumb.Data = out.Bytes()
+	return thumb, nil
+}
+
+// Resize() scales the image to w×h pixels, each pixel being the average of
+// the source pixels it covers.
+func Resize(img image.Image, w, h int) *image.RGBA {
+	src, ok := img.(*image.RGBA)
+	if !ok || src.Bounds().Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < h; y++ {
+		y0, y1 := span(y, h, sh)
+		for x := 0; x < w; x++ {
+			x0, x1 := span(x, w, sw)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for i := range sum {
+						sum[i] += int(row[sx*4+i])
+					}
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			for i := range sum {
+				dst.Pix[y*dst.Stride+x*4+i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// span() returns the source pixels covered by the destination pixel i, at
+// least one.
+func span(i, size, side int) (int, int) {
+	lo, hi := i*side/size, (i+1)*side/size
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+// orientation() returns the EXIF orientation of a JPEG file, 1 when it has
+// none. It reads the first IFD of the APP1 Exif segment.
+func orientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		i += 2 + length
+
+		if marker != 0xE1 || !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			continue
+		}
+		tiff := segment[6:]
+		if len(tiff) < 8 {
+			return 1
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return 1
+		}
+
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return 1
+		}
+		entries := int(order.Uint16(tiff[ifd:]))
+		for e := 0; e < entries; e++ {
+			entry := ifd + 2 + e*12
+			if entry+12 > len(tiff) {
+				return 1
+			}
+			if order.Uint16(tiff[entry:]) == 0x0112 {
+				return int(order.Uint16(tiff[entry+8:]))
+			}
+		}
+		return 1
+	}
+	return 1
+}
+
+// orient() turns the image upright according to the EXIF orientation.
+func orient(img image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// gifFrames() counts the frames of a GIF and the pixels they cover by walking
+// its blocks, without decoding them.
+func gifFrames(data []byte) (frames int, pixels int64, err error) {
+	// The header and the logical screen descriptor.
+	if len(data) < 13 {
+		return 0, 0, ErrInvalid
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&7 + 1)
+	}
+
+	// skip() passes the data sub-blocks ending with an empty one.
+	skip := func() bool {
+		for pos < len(data) {
+			n := int(data[pos])
+			pos += 1 + n
+			if n == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // an extension: its label and sub-blocks
+			pos += 2
+			if !skip() {
+				return 0, 0, ErrInvalid
+			}
+		case 0x2c: // an image descriptor, its color table and LZW data
+			if pos+10 > len(data) {
+				return 0, 0, ErrInvalid
+			}
+			w := binary.LittleEndian.Uint16(data[pos+5:])
+			h := binary.LittleEndian.Uint16(data[pos+7:])
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&7 + 1)
+			}
+			pos++ // the LZW minimum code size
+			if !skip() {
+				return 0, 0, ErrInvalid
+			}
+			frames++
+			pixels += int64(w) * int64(h)
+		case 0x3b: // the trailer
+			return frames, pixels, nil
+		default:
+			return 0, 0, ErrInvalid
+		}
+	}
+	// Like the decoder, a missing trailer is accepted.
+	return frames, pixels, nil
+}
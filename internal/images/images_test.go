@@ -0,0 +1,102 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// animation encodes a GIF of n frames of the size.
+func animation(t *testing.T, n, w, h int) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	g := &gif.GIF{}
+	for i := 0; i < n; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, w, h), palette)
+		frame.SetColorIndex(i%w, 0, 1)
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// frames writes a GIF whose frames claim the size without the data to fill
+// it, which is enough to be counted.
+func frames(n, w, h int) []byte {
+	b := []byte("GIF89a")
+	b = binary.LittleEndian.AppendUint16(b, uint16(w))
+	b = binary.LittleEndian.AppendUint16(b, uint16(h))
+	b = append(b, 0x80, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff)
+	for i := 0; i < n; i++ {
+		b = append(b, 0x21, 0xf9, 4, 0, 10, 0, 0, 0)
+		b = append(b, 0x2c, 0, 0, 0, 0)
+		b = binary.LittleEndian.AppendUint16(b, uint16(w))
+		b = binary.LittleEndian.AppendUint16(b, uint16(h))
+		b = append(b, 0, 2, 2, 0x4c, 0x01, 0)
+	}
+	return append(b, 0x3b)
+}
+
+func TestGIFFrames(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		frames int
+		pixels int64
+	}{
+		{"encoded", animation(t, 3, 20, 10), 3, 600},
+		{"written", frames(4, 6000, 6000), 4, 4 * 36000000},
+		{"no trailer", frames(2, 10, 10)[:len(frames(2, 10, 10))-1], 2, 200},
+	}
+	for _, tt := range tests {
+		n, pixels, err := gifFrames(tt.data)
+		if err != nil || n != tt.frames || pixels != tt.pixels {
+			t.Errorf("%s: got %d frames of %d pixels, %v", tt.name, n, pixels, err)
+		}
+	}
+
+	for _, data := range [][]byte{
+		[]byte("GIF89a"),
+		frames(1, 10, 10)[:30],
+		append(frames(1, 10, 10)[:len(frames(1, 10, 10))-1], 0x99),
+	} {
+		if _, _, err := gifFrames(data); err == nil {
+			t.Errorf("%q was accepted", data)
+		}
+	}
+}
+
+func TestProcessGIF(t *testing.T) {
+	img, err := Process(animation(t, 3, 20, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Type != "image/gif" || img.Width != 20 || img.Height != 10 {
+		t.Errorf("got %s %dx%d", img.Type, img.Width, img.Height)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(img.Data))
+	if err != nil || len(g.Image) != 3 {
+		t.Errorf("the frames were lost: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too many frames", animation(t, MaxFrames+1, 2, 2)},
+		{"too many pixels", frames(3, MaxSide, MaxSide)},
+	}
+	for _, tt := range tests {
+		if _, err := Process(tt.data); !errors.Is(err, ErrTooLong) {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
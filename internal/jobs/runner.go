@@ -0,0 +1,406 @@
+// Package jobs runs background work from a persistent queue. Jobs of each
+// kind are handled by a registered function with a limit on the jobs running
+// at once, failed jobs are retried with a growing delay and recurring jobs are
+// queued on a schedule.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+const (
+	// pollInterval is how often the queue is checked for due jobs.
+	pollInterval = time.Second
+	// retryDelay is the wait before the first retry, it doubles with every
+	// further attempt up to maxRetryDelay.
+	retryDelay    = 10 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// Handler runs a job with its payload. The context is cancelled when the job
+// times out or the runner stops before the job finished.
+type Handler func(ctx context.Context, payload []byte) error
+
+// Options of the jobs of a kind. The zero value runs one job at a time, tries
+// each job up to 5 times and doesn't time out.
+type Options struct {
+	Concurrency int
+	MaxAttempts int
+	Timeout     time.Duration
+}
+
+type worker struct {
+	handler Handler
+	opts    Options
+	running int
+}
+
+type schedule struct {
+	spec     string
+	kind     string
+	schedule Schedule
+	payload  []byte
+	next     time.Time
+}
+
+type Runner struct {
+	store    models.JobRepository
+	errorLog *log.Logger
+
+	mu        sync.Mutex
+	workers   map[string]*worker
+	schedules []*schedule
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewRunner(store models.JobRepository, errorLog *log.Logger) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		store:    store,
+		errorLog: errorLog,
+		workers:  make(map[string]*worker),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Register() sets the handler of the jobs of the kind. It panics when the kind
+// already has one.
+func (r *Runner) Register(kind string, opts Options, handler Handler) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 5
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.workers[kind]; ok {
+		panic(fmt.Sprintf("jobs: kind %q registered twice", kind))
+	}
+	r.workers[kind] = &worker{handler: handler, opts: opts}
+}
+
+// Handle() registers fn as the handler of the kind. The payloads of the jobs
+// are decoded from JSON into its argument.
+func Handle[T any](r *Runner, kind string, opts Options, fn func(context.Context, T) error) {
+	r.Register(kind, opts, func(ctx context.Context, payload []byte) error {
+		var arg T
+		if err := json.Unmarshal(payload, &arg); err != nil {
+			return fmt.Errorf("jobs: decoding the payload: %w", err)
+		}
+		return fn(ctx, arg)
+	})
+}
+
+// Enqueue() queues a job of the kind with the JSON encoding of the payload.
+func (r *Runner) Enqueue(kind string, payload any) error {
+	return r.EnqueueIn(kind, payload, 0)
+}
+
+// EnqueueIn() queues a job of the kind to run after the delay.
+func (r *Runner) EnqueueIn(kind string, payload any, delay time.Duration) error {
+	r.mu.Lock()
+	w, ok := r.workers[kind]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("jobs: no handler for kind %q", kind)
+	}
+
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err = r.store.Insert(kind, js, delay, w.opts.MaxAttempts); err != nil {
+		return err
+	}
+	if delay <= 0 {
+		r.notify()
+	}
+	return nil
+}
+
+// Schedule() queues a job of the kind at the times of the spec, see
+// ParseSchedule(). No job is queued while one of the kind is still pending or
+// running, so a slow job doesn't pile up behind itself.
+func (r *Runner) Schedule(spec, kind string, payload any) error {
+	s, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.workers[kind]; !ok {
+		return fmt.Errorf("jobs: no handler for kind %q", kind)
+	}
+	r.schedules = append(r.schedules, &schedule{
+		spec:     spec,
+		kind:     kind,
+		schedule: s,
+		payload:  js,
+		next:     s.Next(time.Now()),
+	})
+	return nil
+}
+
+// Start() queues again the jobs interrupted by a previous process and starts
+// running the jobs until Shutdown(). Every job left running counts as
+// interrupted, so only a single process may run the jobs of a database.
+func (r *Runner) Start() error {
+	if err := r.store.Requeue(); err != nil {
+		return err
+	}
+	r.done = make(chan struct{})
+	go r.loop()
+	return nil
+}
+
+// Shutdown() stops taking new jobs and waits for the running ones. When the
+// context ends first their contexts are cancelled, the jobs are queued again
+// and the context's error is returned.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	if r.done != nil {
+		<-r.done
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		r.cancel()
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// notify() wakes the loop up without waiting for the next poll.
+func (r *Runner) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Runner) loop() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.queueScheduled(time.Now())
+		r.claim()
+
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// queueScheduled() queues the jobs of the schedules due at now.
+func (r *Runner) queueScheduled(now time.Time) {
+	var due []*schedule
+	maxAttempts := make(map[string]int)
+
+	r.mu.Lock()
+	for _, s := range r.schedules {
+		if s.next.IsZero() || now.Before(s.next) {
+			continue
+		}
+		// Counting from the due time keeps intervals from drifting by the
+		// poll delay, runs missed meanwhile are skipped.
+		if s.next = s.schedule.Next(s.next); !s.next.After(now) {
+			s.next = s.schedule.Next(now)
+		}
+		due = append(due, s)
+		maxAttempts[s.kind] = r.workers[s.kind].opts.MaxAttempts
+	}
+	r.mu.Unlock()
+
+	// The queue is only used without the lock, the finishing jobs and
+	// Enqueue() don't wait for the database. The kind and the payload of a
+	// schedule don't change.
+	for _, s := range due {
+		active, err := r.store.Active(s.kind)
+		if err != nil {
+			r.errorLog.Print(err)
+			continue
+		}
+		if active {
+			continue
+		}
+		if _, err = r.store.Insert(s.kind, s.payload, 0, maxAttempts[s.kind]); err != nil {
+			r.errorLog.Print(err)
+		}
+	}
+}
+
+// claim() starts as many due jobs of each kind as it has free workers.
+func (r *Runner) claim() {
+	r.mu.Lock()
+	free := make(map[string]int, len(r.workers))
+	for kind, w := range r.workers {
+		free[kind] = w.opts.Concurrency - w.running
+	}
+	r.mu.Unlock()
+
+	for kind, n := range free {
+		if n <= 0 {
+			continue
+		}
+		jobs, err := r.store.Claim(kind, n)
+		if err != nil {
+			r.errorLog.Print(err)
+			continue
+		}
+
+		r.mu.Lock()
+		w := r.workers[kind]
+		w.running += len(jobs)
+		r.mu.Unlock()
+
+		for _, job := range jobs {
+			r.wg.Add(1)
+			go r.run(w, job)
+		}
+	}
+}
+
+// run() runs the job and records the result: a failed job is tried again
+// later until it used all its attempts.
+func (r *Runner) run(w *worker, job *models.Job) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		w.running--
+		r.mu.Unlock()
+		r.notify()
+	}()
+
+	ctx := r.ctx
+	if w.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.opts.Timeout)
+		defer cancel()
+	}
+
+	err := call(ctx, w.handler, job.Payload)
+	switch {
+	case err == nil:
+		err = r.store.Done(job.ID)
+	case r.ctx.Err() != nil:
+		// Interrupted by the shutdown, it runs again after the next start.
+		err = r.store.Retry(job.ID, err.Error(), 0)
+	case job.Attempts >= job.MaxAttempts:
+		r.errorLog.Printf("job %d (%s) failed: %v", job.ID, job.Kind, err)
+		err = r.store.Failed(job.ID, err.Error())
+	default:
+		r.errorLog.Printf("job %d (%s) attempt %d: %v", job.ID, job.Kind, job.Attempts, err)
+		err = r.store.Retry(job.ID, err.Error(), backoff(job.Attempts))
+	}
+	if err != nil {
+		r.errorLog.Print(err)
+	}
+}
+
+// call() runs the handler turning a panic into an error.
+func call(ctx context.Context, handler Handler, payload []byte) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v\n%s", v, debug.Stack())
+		}
+	}()
+	return handler(ctx, payload)
+}
+
+// backoff() returns the wait after the given failed attempt.
+func backoff(attempt int) time.Duration {
+	d := retryDelay
+	for i := 1; i < attempt && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
+
+// Jobs() returns the latest jobs with the status, of any status when it is
+// empty.
+func (r *Runner) Jobs(status string, limit int) ([]*models.Job, error) {
+	return r.store.List(status, limit)
+}
+
+// Counts() returns the number of jobs of each status.
+func (r *Runner) Counts() (map[string]int, error) {
+	return r.store.Counts()
+}
+
+// Schedules() returns the recurring jobs ordered by kind.
+func (r *Runner) Schedules() []models.JobSchedule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	schedules := make([]models.JobSchedule, 0, len(r.schedules))
+	for _, s := range r.schedules {
+		schedules = append(schedules, models.JobSchedule{Spec: s.spec, Kind: s.kind, Next: s.next})
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Kind < schedules[j].Kind })
+	return schedules
+}
+
+// RunAgain() queues a failed job again with all its attempts.
+func (r *Runner) RunAgain(id int) error {
+	if err := r.store.Reset(id); err != nil {
+		return err
+	}
+	r.notify()
+	return nil
+}
+
+// Prune() deletes the jobs done longer than done ago and the ones failed
+// longer than failed ago.
+func (r *Runner) Prune(done, failed time.Duration) error {
+	return errors.Join(
+		r.store.Prune(models.JobDone, done),
+		r.store.Prune(models.JobFailed, failed),
+	)
+}
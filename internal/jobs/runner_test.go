@@ -0,0 +1,408 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// memStore is a JobRepository in memory. The waits before retries are
+// recorded instead of kept, retried jobs are due at once.
+type memStore struct {
+	mu    sync.Mutex
+	jobs  []*models.Job
+	waits []time.Duration
+}
+
+func (s *memStore) Insert(kind string, payload []byte, delay time.Duration, maxAttempts int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := &models.Job{
+		ID:          len(s.jobs) + 1,
+		Kind:        kind,
+		Payload:     payload,
+		Status:      models.JobPending,
+		MaxAttempts: maxAttempts,
+	}
+	s.jobs = append(s.jobs, job)
+	return job.ID, nil
+}
+
+func (s *memStore) Claim(kind string, limit int) ([]*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []*models.Job
+	for _, job := range s.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if job.Kind == kind && job.Status == models.JobPending {
+			job.Status = models.JobRunning
+			job.Attempts++
+			c := *job
+			claimed = append(claimed, &c)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memStore) Active(kind string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.Kind == kind && (job.Status == models.JobPending || job.Status == models.JobRunning) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memStore) set(id int, status, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id < 1 || id > len(s.jobs) {
+		return models.ErrNoRecord
+	}
+	s.jobs[id-1].Status = status
+	s.jobs[id-1].Error = message
+	return nil
+}
+
+func (s *memStore) Done(id int) error {
+	return s.set(id, models.JobDone, "")
+}
+
+func (s *memStore) Retry(id int, message string, wait time.Duration) error {
+	s.mu.Lock()
+	s.waits = append(s.waits, wait)
+	s.mu.Unlock()
+	return s.set(id, models.JobPending, message)
+}
+
+func (s *memStore) Failed(id int, message string) error {
+	return s.set(id, models.JobFailed, message)
+}
+
+func (s *memStore) Requeue() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.Status == models.JobRunning {
+			job.Status = models.JobPending
+		}
+	}
+	return nil
+}
+
+func (s *memStore) Reset(id int) error {
+	return s.set(id, models.JobPending, "")
+}
+
+func (s *memStore) List(status string, limit int) ([]*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []*models.Job
+	for i := len(s.jobs) - 1; i >= 0 && len(jobs) < limit; i-- {
+		if status == "" || s.jobs[i].Status == status {
+			c := *s.jobs[i]
+			jobs = append(jobs, &c)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *memStore) Counts() (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, job := range s.jobs {
+		counts[job.Status]++
+	}
+	return counts, nil
+}
+
+func (s *memStore) Prune(string, time.Duration) error {
+	return nil
+}
+
+// job returns a copy of the job with the id.
+func (s *memStore) job(id int) models.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.jobs[id-1]
+}
+
+func (s *memStore) retryWaits() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Duration(nil), s.waits...)
+}
+
+func newTestRunner() (*Runner, *memStore) {
+	store := &memStore{}
+	return NewRunner(store, log.New(io.Discard, "", 0)), store
+}
+
+// waitFor fails the test when cond doesn't hold within a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func shutdown(t *testing.T, r *Runner) {
+	t.Helper()
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	r, store := newTestRunner()
+
+	var running, most atomic.Int32
+	release := make(chan struct{})
+	Handle(r, "test", Options{Concurrency: 2}, func(ctx context.Context, n int) error {
+		now := running.Add(1)
+		for {
+			old := most.Load()
+			if now <= old || most.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+		return nil
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := r.Enqueue("test", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, "two running jobs", func() bool { return running.Load() == 2 })
+	// The other jobs must wait for a free worker.
+	time.Sleep(50 * time.Millisecond)
+	if n := running.Load(); n != 2 {
+		t.Fatalf("%d jobs running, want 2", n)
+	}
+	close(release)
+
+	waitFor(t, "the jobs", func() bool {
+		counts, _ := store.Counts()
+		return counts[models.JobDone] == 5
+	})
+	shutdown(t, r)
+	if n := most.Load(); n != 2 {
+		t.Errorf("at most %d jobs ran at once, want 2", n)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	r, store := newTestRunner()
+
+	var calls atomic.Int32
+	Handle(r, "test", Options{MaxAttempts: 5}, func(ctx context.Context, _ struct{}) error {
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Enqueue("test", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the job", func() bool { return store.job(1).Status == models.JobDone })
+	shutdown(t, r)
+
+	if job := store.job(1); job.Attempts != 3 || job.Error != "" {
+		t.Errorf("attempts = %d, error = %q, want 3 and none", job.Attempts, job.Error)
+	}
+	if got, want := store.retryWaits(), []time.Duration{retryDelay, 2 * retryDelay}; !reflect.DeepEqual(got, want) {
+		t.Errorf("waits = %v, want %v", got, want)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	r, store := newTestRunner()
+
+	Handle(r, "test", Options{MaxAttempts: 2}, func(ctx context.Context, _ struct{}) error {
+		return errors.New("broken")
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Enqueue("test", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the job", func() bool { return store.job(1).Status == models.JobFailed })
+	shutdown(t, r)
+
+	if job := store.job(1); job.Attempts != 2 || job.Error != "broken" {
+		t.Errorf("attempts = %d, error = %q, want 2 and broken", job.Attempts, job.Error)
+	}
+	if got, want := store.retryWaits(), []time.Duration{retryDelay}; !reflect.DeepEqual(got, want) {
+		t.Errorf("waits = %v, want %v", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{8, 1280 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestPanic(t *testing.T) {
+	r, store := newTestRunner()
+
+	var calls atomic.Int32
+	Handle(r, "test", Options{MaxAttempts: 1}, func(ctx context.Context, _ struct{}) error {
+		calls.Add(1)
+		panic("boom")
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Enqueue("test", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the job", func() bool { return store.job(1).Status == models.JobFailed })
+
+	// The runner goes on with the next jobs.
+	if err := r.Enqueue("test", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the second job", func() bool { return store.job(2).Status == models.JobFailed })
+	shutdown(t, r)
+
+	if job := store.job(1); !strings.HasPrefix(job.Error, "panic: boom\n") {
+		t.Errorf("error = %q, want the panic", job.Error)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%d calls, want 2", n)
+	}
+}
+
+func TestShutdownRequeues(t *testing.T) {
+	r, store := newTestRunner()
+
+	started := make(chan struct{})
+	Handle(r, "test", Options{}, func(ctx context.Context, _ struct{}) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Enqueue("test", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() = %v, want the deadline", err)
+	}
+
+	// The interrupted job is queued again to run after the next start.
+	if job := store.job(1); job.Status != models.JobPending {
+		t.Errorf("status = %s, want pending", job.Status)
+	}
+	if got, want := store.retryWaits(), []time.Duration{0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("waits = %v, want %v", got, want)
+	}
+}
+
+func TestStartRequeues(t *testing.T) {
+	r, store := newTestRunner()
+
+	// A job left running by a previous process.
+	if _, err := store.Insert("test", []byte(`{}`), 0, 5); err != nil {
+		t.Fatal(err)
+	}
+	store.set(1, models.JobRunning, "")
+
+	Handle(r, "test", Options{}, func(ctx context.Context, _ struct{}) error {
+		return nil
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the job", func() bool { return store.job(1).Status == models.JobDone })
+	shutdown(t, r)
+}
+
+func TestQueueScheduled(t *testing.T) {
+	r, store := newTestRunner()
+
+	Handle(r, "test", Options{}, func(ctx context.Context, _ struct{}) error {
+		return nil
+	})
+	if err := r.Schedule("@every 1m", "test", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	next := r.Schedules()[0].Next
+
+	r.queueScheduled(next.Add(-time.Second))
+	if counts, _ := store.Counts(); counts[models.JobPending] != 0 {
+		t.Fatalf("queued before the schedule was due")
+	}
+	r.queueScheduled(next)
+	if counts, _ := store.Counts(); counts[models.JobPending] != 1 {
+		t.Fatalf("%d jobs queued, want 1", counts[models.JobPending])
+	}
+	if got := r.Schedules()[0].Next; !got.Equal(next.Add(time.Minute)) {
+		t.Errorf("next = %v, want %v", got, next.Add(time.Minute))
+	}
+
+	// No job is queued while the last one is pending.
+	r.queueScheduled(next.Add(time.Minute))
+	if counts, _ := store.Counts(); counts[models.JobPending] != 1 {
+		t.Errorf("%d jobs queued, want 1", counts[models.JobPending])
+	}
+}
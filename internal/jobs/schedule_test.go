@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"1,,2 * * * *",
+		"@yearly",
+		"@every",
+		"@every soon",
+		"@every 500ms",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q was accepted", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Monday, January 1st 2024.
+	monday := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"@every 90s", monday, monday.Add(90 * time.Second)},
+		{"* * * * *", monday, at(1, 1, 10, 8)},
+		{"*/15 * * * *", monday, at(1, 1, 10, 15)},
+		{"*/15 * * * *", at(1, 1, 10, 15), at(1, 1, 10, 30)},
+		{"5/20 * * * *", monday, at(1, 1, 10, 25)},
+		{"10-30/10 */6 * * *", monday, at(1, 1, 12, 10)},
+		{"0 9,17 * * *", monday, at(1, 1, 17, 0)},
+		{"@hourly", monday, at(1, 1, 11, 0)},
+		{"@daily", monday, at(1, 2, 0, 0)},
+		{"@weekly", monday, at(1, 7, 0, 0)},
+		{"@monthly", monday, at(2, 1, 0, 0)},
+		{"0 0 1 1 *", at(6, 1, 0, 0), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * 1-5", at(1, 5, 10, 0), at(1, 8, 2, 30)},
+		{"0 12 * * 0,6", monday, at(1, 6, 12, 0)},
+		// Both day fields restricted: the 13th or a Friday.
+		{"0 0 13 * 5", monday, at(1, 5, 0, 0)},
+		{"0 0 13 * 5", at(1, 6, 0, 0), at(1, 12, 0, 0)},
+		{"0 0 13 * 5", at(1, 12, 0, 0), at(1, 13, 0, 0)},
+		{"0 0 13 * 5", at(1, 13, 0, 0), at(1, 19, 0, 0)},
+		// Only one restricted: the other one doesn't count.
+		{"0 0 13 * *", monday, at(1, 13, 0, 0)},
+		{"0 0 */2 * *", monday, at(1, 3, 0, 0)},
+		{"0 0 29 2 *", at(3, 1, 0, 0), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", at(2, 1, 0, 0), at(3, 31, 0, 0)},
+		{"0 0 30 2 *", monday, time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q after %v: got %v, want %v", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Package live is an in-process publish/subscribe hub fanning out forum
+// events to the clients connected with Server-Sent Events.
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// FeedTopic receives the events shown on the home page.
+const FeedTopic = "feed"
+
+// PostTopic() returns the topic of the events of a post.
+func PostTopic(id int) string {
+	return fmt.Sprintf("post:%d", id)
+}
+
+// Event is a message published to a topic. Data holds its JSON encoding.
+type Event struct {
+	Name string
+	Data []byte
+}
+
+// subscriberBuffer is the number of events a subscriber may lag behind before
+// further events are dropped for it.
+const subscriberBuffer = 16
+
+type Hub struct {
+	mu     sync.Mutex
+	topics map[string]map[chan Event]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{topics: make(map[string]map[chan Event]struct{})}
+}
+
+// Subscribe() returns the channel receiving the events of the topic and the
+// function ending the subscription.
+func (h *Hub) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[chan Event]struct{})
+	}
+	h.topics[topic][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.topics[topic], ch)
+			if len(h.topics[topic]) == 0 {
+				delete(h.topics, topic)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Publish() sends the event to the subscribers of the topic without waiting:
+// subscribers which are too slow miss it.
+func (h *Hub) Publish(topic, name string, data any) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	event := Event{Name: name, Data: js}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.topics[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close() ends all subscriptions by closing their channels, e.g. to let the
+// open event streams finish when the server shuts down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic, subscribers := range h.topics {
+		for ch := range subscribers {
+			close(ch)
+		}
+		delete(h.topics, topic)
+	}
+	h.closed = true
+}
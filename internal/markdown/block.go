@@ -0,0 +1,439 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"forum.bbilisbe/internal/highlight"
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	rulePattern    = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fencePattern   = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+	quotePattern   = regexp.MustCompile(`^ {0,3}> ?`)
+	itemPattern    = regexp.MustCompile(`^( {0,3})([-+*]|\d{1,9}[.)])( +|$)`)
+	setextPattern  = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	delimPattern   = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	langPattern    = regexp.MustCompile(`^[\w#+.-]+$`)
+)
+
+// blocks writes the block structure of the lines. The paragraphs of tight
+// list items are written without <p>.
+func (r *renderer) blocks(lines []string, depth int, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case blank(line):
+			i++
+		case isFence(line):
+			i = r.fenced(lines, i)
+		case indent(line) >= 4:
+			i = r.indented(lines, i)
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			r.heading(len(m[1]), m[2])
+			i++
+		case rulePattern.MatchString(line):
+			r.open("hr")
+			r.b.WriteString("\n")
+			i++
+		case quotePattern.MatchString(line) && depth < maxDepth:
+			i = r.quote(lines, i, depth)
+		case itemPattern.MatchString(line) && depth < maxDepth:
+			i = r.list(lines, i, depth)
+		case isTable(lines, i):
+			i = r.table(lines, i)
+		default:
+			i = r.paragraph(lines, i, tight)
+		}
+	}
+}
+
+// interrupts reports whether the line starts a block which ends a paragraph
+// without a blank line.
+func interrupts(line string) bool {
+	if isFence(line) || headingPattern.MatchString(line) || rulePattern.MatchString(line) || quotePattern.MatchString(line) {
+		return true
+	}
+	// Only lists starting at 1 with some content interrupt a paragraph, so
+	// that numbers at the start of a line don't turn into lists.
+	if m := itemPattern.FindStringSubmatch(line); m != nil {
+		if strings.TrimSpace(line[len(m[0]):]) == "" {
+			return false
+		}
+		return !isOrdered(m[2]) || strings.TrimLeft(m[2][:len(m[2])-1], "0") == "1"
+	}
+	return false
+}
+
+func (r *renderer) paragraph(lines []string, i int, tight bool) int {
+	start := i
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if blank(line) {
+			break
+		}
+		if i > start {
+			if m := setextPattern.FindStringSubmatch(line); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				r.heading(level, strings.Join(text, "\n"))
+				return i + 1
+			}
+			if interrupts(line) || isTable(lines, i) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+
+	content := strings.TrimRight(strings.Join(text, "\n"), " \t")
+	if tight {
+		r.inline(content)
+		return i
+	}
+	r.open("p")
+	r.inline(content)
+	r.close("p")
+	r.b.WriteString("\n")
+	return i
+}
+
+func (r *renderer) heading(level int, text string) {
+	tag := "h" + strconv.Itoa(level)
+	r.open(tag)
+	r.inline(strings.TrimSpace(text))
+	r.close(tag)
+	r.b.WriteString("\n")
+}
+
+func isFence(line string) bool {
+	m := fencePattern.FindStringSubmatch(line)
+	return m != nil && !(m[2][0] == '`' && strings.Contains(m[3], "`"))
+}
+
+// fenced writes the code block opened by the fence at lines[i]. The first
+// word after the fence is the language of the code.
+func (r *renderer) fenced(lines []string, i int) int {
+	m := fencePattern.FindStringSubmatch(lines[i])
+	width, fence := len(m[1]), m[2]
+	lang := ""
+	if fields := strings.Fields(m[3]); len(fields) > 0 {
+		lang = fields[0]
+	}
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if indent(line) < 4 {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				i++
+				break
+			}
+		}
+		code = append(code, trimIndent(line, width))
+	}
+	r.code(lang, code)
+	return i
+}
+
+func (r *renderer) indented(lines []string, i int) int {
+	var code []string
+	for ; i < len(lines) && (blank(lines[i]) || indent(lines[i]) >= 4); i++ {
+		code = append(code, trimIndent(lines[i], 4))
+	}
+	for len(code) > 0 && blank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+	r.code("", code)
+	return i
+}
+
+// code writes a code block, highlighted when the language is known. Tokens
+// spanning lines, like block comments, are split at the ends of the lines.
+func (r *renderer) code(lang string, lines []string) {
+	r.open("pre")
+	if langPattern.MatchString(lang) {
+		r.open("code", "class", "language-"+strings.ToLower(lang))
+	} else {
+		r.open("code")
+	}
+
+	source := strings.Join(lines, "\n")
+	tokens, ok := highlight.Tokens(lang, source)
+	if !ok {
+		tokens = []highlight.Token{{Text: source}}
+	}
+	if len(lines) > 0 {
+		r.open("span", "class", "line")
+		for _, token := range tokens {
+			for i, text := range strings.Split(token.Text, "\n") {
+				if i > 0 {
+					r.close("span")
+					r.b.WriteString("\n")
+					r.open("span", "class", "line")
+				}
+				if text == "" {
+					continue
+				}
+				if token.Kind != highlight.Plain {
+					r.open("span", "class", "hl-"+token.Kind)
+					r.b.WriteString(html.EscapeString(text))
+					r.close("span")
+				} else {
+					r.b.WriteString(html.EscapeString(text))
+				}
+			}
+		}
+		r.close("span")
+		r.b.WriteString("\n")
+	}
+	r.close("code")
+	r.close("pre")
+	r.b.WriteString("\n")
+}
+
+// quote writes the block quote starting at lines[i]. Lines without the >
+// marker continue a paragraph of the quote.
+func (r *renderer) quote(lines []string, i int, depth int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := quotePattern.FindString(line); m != "" {
+			inner = append(inner, line[len(m):])
+			continue
+		}
+		if blank(line) || blank(inner[len(inner)-1]) || interrupts(line) {
+			break
+		}
+		inner = append(inner, line)
+	}
+
+	r.open("blockquote")
+	r.b.WriteString("\n")
+	r.blocks(inner, depth+1, false)
+	r.close("blockquote")
+	r.b.WriteString("\n")
+	return i
+}
+
+// marker is the start of a list item.
+type marker struct {
+	// kind is the bullet or the delimiter after the number.
+	kind   byte
+	number string
+	// width is the indentation of the content of the item.
+	width   int
+	content string
+}
+
+func parseMarker(line string) (marker, bool) {
+	m := itemPattern.FindStringSubmatch(line)
+	if m == nil {
+		return marker{}, false
+	}
+	mk := marker{kind: m[2][len(m[2])-1]}
+	if isOrdered(m[2]) {
+		mk.number = m[2][:len(m[2])-1]
+	}
+	mk.width = len(m[1]) + len(m[2]) + len(m[3])
+	// An item starting with a blank line or an indented code block has its
+	// content one space after the marker.
+	if len(m[3]) == 0 || len(m[3]) > 4 {
+		mk.width = len(m[1]) + len(m[2]) + 1
+	}
+	if mk.width < len(line) {
+		mk.content = line[mk.width:]
+	}
+	return mk, true
+}
+
+func isOrdered(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+// list writes the list starting at lines[i]. It is loose, its items'
+// paragraphs wrapped in <p>, when blank lines separate its items or the
+// blocks of an item.
+func (r *renderer) list(lines []string, i int, depth int) int {
+	first, _ := parseMarker(lines[i])
+	width := first.width
+	items := [][]string{{first.content}}
+	loose := false
+
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		item := items[len(items)-1]
+		if blank(line) {
+			items[len(items)-1] = append(item, "")
+			continue
+		}
+		if indent(line) >= width {
+			if blank(item[len(item)-1]) && len(strings.TrimSpace(strings.Join(item, ""))) > 0 {
+				loose = true
+			}
+			items[len(items)-1] = append(item, line[width:])
+			continue
+		}
+		if mk, ok := parseMarker(line); ok && mk.kind == first.kind && (mk.number == "") == (first.number == "") && !rulePattern.MatchString(line) {
+			if blank(item[len(item)-1]) {
+				loose = true
+			}
+			items = append(items, []string{mk.content})
+			width = mk.width
+			continue
+		}
+		if blank(item[len(item)-1]) || interrupts(line) {
+			break
+		}
+		items[len(items)-1] = append(item, strings.TrimLeft(line, " "))
+	}
+
+	tag := "ul"
+	if first.number != "" {
+		tag = "ol"
+		if n, _ := strconv.Atoi(first.number); n != 1 {
+			r.open(tag, "start", strconv.Itoa(n))
+		} else {
+			r.open(tag)
+		}
+	} else {
+		r.open(tag)
+	}
+	r.b.WriteString("\n")
+	for _, item := range items {
+		for len(item) > 0 && blank(item[len(item)-1]) {
+			item = item[:len(item)-1]
+		}
+		r.open("li")
+		r.blocks(item, depth+1, !loose)
+		r.close("li")
+		r.b.WriteString("\n")
+	}
+	r.close(tag)
+	r.b.WriteString("\n")
+
+	// The blank lines after the last item end the list, they don't belong
+	// to the item.
+	return i
+}
+
+// isTable reports whether a table starts at lines[i]: a row of cells
+// followed by the delimiter row, with as many cells.
+func isTable(lines []string, i int) bool {
+	if i+1 >= len(lines) || !strings.Contains(lines[i], "|") || !strings.Contains(lines[i+1], "|") {
+		return false
+	}
+	if !delimPattern.MatchString(lines[i+1]) {
+		return false
+	}
+	return len(cells(lines[i])) == len(cells(lines[i+1]))
+}
+
+// cells splits a table row at the pipes which aren't escaped.
+func cells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+
+	var cells []string
+	start := 0
+	for i := 0; i < len(row); i++ {
+		switch row[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, strings.TrimSpace(row[start:i]))
+			start = i + 1
+		}
+	}
+	return append(cells, strings.TrimSpace(row[start:]))
+}
+
+// table writes the table starting at lines[i]. Its rows end at a blank line
+// or the start of another block.
+func (r *renderer) table(lines []string, i int) int {
+	header := cells(lines[i])
+	var align []string
+	for _, cell := range cells(lines[i+1]) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			align = append(align, "align-center")
+		case right:
+			align = append(align, "align-right")
+		case left:
+			align = append(align, "align-left")
+		default:
+			align = append(align, "")
+		}
+	}
+
+	r.open("table")
+	r.b.WriteString("\n")
+	r.open("thead")
+	r.b.WriteString("\n")
+	r.row("th", header, align)
+	r.close("thead")
+	r.b.WriteString("\n")
+
+	i += 2
+	if i < len(lines) && !blank(lines[i]) && !interrupts(lines[i]) {
+		r.open("tbody")
+		r.b.WriteString("\n")
+		for ; i < len(lines) && !blank(lines[i]) && !interrupts(lines[i]); i++ {
+			row := cells(lines[i])
+			for len(row) < len(header) {
+				row = append(row, "")
+			}
+			r.row("td", row[:len(header)], align)
+		}
+		r.close("tbody")
+		r.b.WriteString("\n")
+	}
+	r.close("table")
+	r.b.WriteString("\n")
+	return i
+}
+
+func (r *renderer) row(tag string, cells []string, align []string) {
+	r.open("tr")
+	r.b.WriteString("\n")
+	for j, cell := range cells {
+		if align[j] != "" {
+			r.open(tag, "class", align[j])
+		} else {
+			r.open(tag)
+		}
+		r.inline(cell)
+		r.close(tag)
+		r.b.WriteString("\n")
+	}
+	r.close("tr")
+	r.b.WriteString("\n")
+}
+
+func blank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// trimIndent removes up to n spaces of indentation.
+func trimIndent(line string, n int) string {
+	if i := indent(line); i < n {
+		n = i
+	}
+	return line[n:]
+}
@@ -0,0 +1,522 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	autolinkPattern = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*)>`)
+	emailPattern    = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	urlPattern      = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<]+`)
+	escapePattern   = regexp.MustCompile("\\\\([!-/:-@\\[-`{-~])")
+)
+
+// node is a piece of inline content: text, HTML made by the renderer or a
+// run of emphasis delimiters.
+type node struct {
+	text string
+	html bool
+
+	// delim is the character of a delimiter run, n the number of its
+	// characters left after matching.
+	delim      byte
+	n, length  int
+	open, shut bool
+	// starts are the tags opened after the run, ends the tags closed before
+	// it, both from the innermost.
+	starts, ends []string
+}
+
+// inline writes the inline content of a block.
+func (r *renderer) inline(s string) {
+	nodes := r.parse(s)
+	emphasis(nodes)
+
+	var text strings.Builder
+	flush := func() {
+		r.plain(text.String())
+		text.Reset()
+	}
+	for _, n := range nodes {
+		switch {
+		case n.delim != 0:
+			if len(n.ends) > 0 {
+				flush()
+				for _, tag := range n.ends {
+					r.close(tag)
+				}
+			}
+			text.WriteString(strings.Repeat(string(n.delim), n.n))
+			if len(n.starts) > 0 {
+				flush()
+				for i := len(n.starts) - 1; i >= 0; i-- {
+					r.open(n.starts[i])
+				}
+			}
+		case n.html:
+			flush()
+			r.b.WriteString(n.text)
+		default:
+			text.WriteString(n.text)
+		}
+	}
+	flush()
+}
+
+// parse splits the inline content into nodes, rendering code spans, links,
+// images and autolinks as it goes.
+func (r *renderer) parse(s string) []*node {
+	var nodes []*node
+	var text strings.Builder
+	add := func(n *node) {
+		if text.Len() > 0 {
+			nodes = append(nodes, &node{text: text.String()})
+			text.Reset()
+		}
+		nodes = append(nodes, n)
+	}
+
+	brackets := matchBrackets(s)
+	ticks := backtickRuns(s)
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			// A backslash at the end of a line is a line break, like any
+			// other end of a line.
+			i++
+
+		case c == '`':
+			n := runLength(s, i)
+			end := ticks.next(n, i+n)
+			if end < 0 {
+				text.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			add(&node{text: codeSpan(s[i+n : end]), html: true})
+			i = end + n
+
+		case c == '*' || c == '_' || c == '~':
+			n := runLength(s, i)
+			if c == '~' && n != 2 {
+				text.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			add(delimiter(s, i, n))
+			i += n
+
+		case c == '[' && !r.nolinks, c == '!' && !r.nolinks && i+1 < len(s) && s[i+1] == '[':
+			image := c == '!'
+			open := i
+			if image {
+				open++
+			}
+			html, n, ok := r.link(s, open, brackets, image)
+			if !ok {
+				text.WriteString(s[i : open+1])
+				i = open + 1
+				continue
+			}
+			add(&node{text: html, html: true})
+			i = open + n
+
+		case c == '<' && !r.nolinks:
+			html, n, ok := autolink(s[i:])
+			if !ok {
+				text.WriteByte(c)
+				i++
+				continue
+			}
+			add(&node{text: html, html: true})
+			i += n
+
+		default:
+			text.WriteByte(c)
+			i++
+		}
+	}
+	if text.Len() > 0 {
+		nodes = append(nodes, &node{text: text.String()})
+	}
+	return nodes
+}
+
+// delimiter returns the node of the run of n delimiters at s[i], which can
+// open and close emphasis depending on the characters around it.
+func delimiter(s string, i, n int) *node {
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(s[:i])
+	}
+	if i+n < len(s) {
+		after, _ = utf8.DecodeRuneInString(s[i+n:])
+	}
+
+	left := !unicode.IsSpace(after) && (!isPunctRune(after) || unicode.IsSpace(before) || isPunctRune(before))
+	right := !unicode.IsSpace(before) && (!isPunctRune(before) || unicode.IsSpace(after) || isPunctRune(after))
+
+	d := &node{delim: s[i], n: n, length: n, open: left, shut: right}
+	// Underscores inside words, as in snake_case, aren't emphasis.
+	if s[i] == '_' {
+		d.open = left && (!right || isPunctRune(before))
+		d.shut = right && (!left || isPunctRune(after))
+	}
+	return d
+}
+
+// emphasis matches the delimiter runs following the CommonMark rules: each
+// closer is matched with the nearest opener of the same character before it.
+func emphasis(nodes []*node) {
+	type key struct {
+		delim byte
+		open  bool
+		mod   int
+	}
+	// bottom is where the search for an opener stops, after an earlier
+	// search of the same kind failed.
+	bottom := map[key]int{}
+
+	for c, closer := range nodes {
+		if closer.delim == 0 || !closer.shut {
+			continue
+		}
+		for closer.n > 0 {
+			k := key{closer.delim, closer.open, closer.length % 3}
+			stop, ok := bottom[k]
+			if !ok {
+				stop = -1
+			}
+
+			found := -1
+			for o := c - 1; o > stop; o-- {
+				opener := nodes[o]
+				if opener.delim != closer.delim || !opener.open || opener.n == 0 {
+					continue
+				}
+				if (opener.shut || closer.open) && (opener.length+closer.length)%3 == 0 &&
+					!(opener.length%3 == 0 && closer.length%3 == 0) {
+					continue
+				}
+				found = o
+				break
+			}
+			if found < 0 {
+				bottom[k] = c - 1
+				if !closer.open {
+					closer.shut = false
+				}
+				break
+			}
+
+			opener := nodes[found]
+			n, tag := 1, "em"
+			if opener.n >= 2 && closer.n >= 2 {
+				n, tag = 2, "strong"
+			}
+			if closer.delim == '~' {
+				tag = "del"
+			}
+			opener.n -= n
+			closer.n -= n
+			opener.starts = append(opener.starts, tag)
+			closer.ends = append(closer.ends, tag)
+
+			// The runs between can't match anymore, their emphasis would
+			// overlap this one.
+			for _, between := range nodes[found+1 : c] {
+				between.open, between.shut = false, false
+			}
+		}
+	}
+}
+
+// plain writes text without markup: the ends of lines become line breaks
+// and web addresses links.
+func (r *renderer) plain(s string) {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			r.open("br")
+			r.b.WriteString("\n")
+		}
+		if i < len(lines)-1 {
+			line = strings.TrimRight(line, " ")
+		}
+		if r.nolinks {
+			r.b.WriteString(r.text(line))
+			continue
+		}
+
+		last := 0
+		for _, m := range urlPattern.FindAllStringIndex(line, -1) {
+			addr := trimURL(line[m[0]:m[1]])
+			dest := addr
+			if !strings.Contains(addr, "://") {
+				dest = "http://" + addr
+			}
+			href, ok := link(dest)
+			if !ok {
+				continue
+			}
+			r.b.WriteString(r.text(line[last:m[0]]))
+			r.open("a", "href", href, "rel", "nofollow ugc")
+			r.b.WriteString(html.EscapeString(addr))
+			r.close("a")
+			last = m[0] + len(addr)
+		}
+		r.b.WriteString(r.text(line[last:]))
+	}
+}
+
+// trimURL removes the punctuation ending a sentence after an address, and
+// the closing parentheses which don't belong to it.
+func trimURL(addr string) string {
+	for len(addr) > 0 {
+		last := addr[len(addr)-1]
+		switch {
+		case strings.IndexByte(`?!.,:;*_~'"`, last) >= 0:
+			addr = addr[:len(addr)-1]
+		case last == ')' && strings.Count(addr, "(") < strings.Count(addr, ")"):
+			addr = addr[:len(addr)-1]
+		default:
+			return addr
+		}
+	}
+	return addr
+}
+
+// link renders the link or image whose text starts with the bracket at
+// s[open] and returns the number of bytes it takes from there.
+func (r *renderer) link(s string, open int, brackets map[int]int, image bool) (string, int, bool) {
+	shut, ok := brackets[open]
+	if !ok || shut+1 >= len(s) || s[shut+1] != '(' {
+		return "", 0, false
+	}
+	dest, title, n, ok := destination(s[shut+1:])
+	if !ok {
+		return "", 0, false
+	}
+	label := s[open+1 : shut]
+	length := shut + 1 + n - open
+
+	// The text of a link can't contain other links.
+	sub := &renderer{text: html.EscapeString, nolinks: true}
+	href, allowed := link(dest)
+
+	switch {
+	case image && allowed && local(href):
+		attrs := []string{"src", href, "alt", unescape(label)}
+		if title != "" {
+			attrs = append(attrs, "title", title)
+		}
+		sub.open("img", attrs...)
+	case !allowed:
+		// The text of links to forbidden addresses stays.
+		sub.inline(label)
+	default:
+		attrs := []string{"href", href}
+		if title != "" {
+			attrs = append(attrs, "title", title)
+		}
+		if external(href) {
+			attrs = append(attrs, "rel", "nofollow ugc")
+		}
+		sub.open("a", attrs...)
+		if image {
+			// Only images of the forum can be shown, others are linked.
+			sub.b.WriteString(html.EscapeString(unescape(label)))
+		} else {
+			sub.inline(label)
+		}
+		sub.close("a")
+	}
+	return sub.b.String(), length, true
+}
+
+// maxParens is the nesting of the parentheses allowed in the address of a
+// link. Deeper ones end the scan, which keeps a run of unclosed "[x](" from
+// being scanned to the end of the text once for every link.
+const maxParens = 32
+
+// destination parses the "(url "title")" after the text of a link and
+// returns the number of bytes it takes.
+func destination(s string) (dest, title string, n int, ok bool) {
+	i := skipSpace(s, 1)
+	if i < len(s) && s[i] == '<' {
+		end := strings.IndexAny(s[i+1:], "<>\n")
+		if end < 0 || s[i+1+end] != '>' {
+			return "", "", 0, false
+		}
+		dest = s[i+1 : i+1+end]
+		i += end + 2
+	} else {
+		start, depth := i, 0
+	loop:
+		for i < len(s) {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+				i++
+			case c == '(':
+				depth++
+				if depth > maxParens {
+					return "", "", 0, false
+				}
+			case c == ')':
+				if depth == 0 {
+					break loop
+				}
+				depth--
+			case c <= ' ':
+				break loop
+			}
+			i++
+		}
+		dest = s[start:i]
+	}
+
+	j := skipSpace(s, i)
+	if j > i && j < len(s) && strings.IndexByte(`"'(`, s[j]) >= 0 {
+		end := s[j]
+		if end == '(' {
+			end = ')'
+		}
+		// A title in parentheses can't contain an unescaped one.
+		k := j + 1
+		for ; k < len(s) && s[k] != end && !(end == ')' && s[k] == '('); k++ {
+			if s[k] == '\\' {
+				k++
+			}
+		}
+		if k >= len(s) || s[k] != end {
+			return "", "", 0, false
+		}
+		title = s[j+1 : k]
+		j = skipSpace(s, k+1)
+	}
+	if j >= len(s) || s[j] != ')' {
+		return "", "", 0, false
+	}
+	return unescape(dest), unescape(title), j + 1, true
+}
+
+// autolink renders the address in angle brackets at the start of s.
+func autolink(s string) (string, int, bool) {
+	var r renderer
+	if m := autolinkPattern.FindStringSubmatch(s); m != nil {
+		href, ok := link(m[1])
+		if !ok {
+			return "", 0, false
+		}
+		r.open("a", "href", href, "rel", "nofollow ugc")
+		r.b.WriteString(html.EscapeString(m[1]))
+		r.close("a")
+		return r.b.String(), len(m[0]), true
+	}
+	if m := emailPattern.FindStringSubmatch(s); m != nil {
+		r.open("a", "href", "mailto:"+m[1])
+		r.b.WriteString(html.EscapeString(m[1]))
+		r.close("a")
+		return r.b.String(), len(m[0]), true
+	}
+	return "", 0, false
+}
+
+func codeSpan(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+		code = code[1 : len(code)-1]
+	}
+	var r renderer
+	r.open("code")
+	r.b.WriteString(html.EscapeString(code))
+	r.close("code")
+	return r.b.String()
+}
+
+// matchBrackets pairs the square brackets of s which aren't escaped, keyed
+// by the position of the opening one.
+func matchBrackets(s string) map[int]int {
+	pairs := map[int]int{}
+	var open []int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			open = append(open, i)
+		case ']':
+			if len(open) > 0 {
+				pairs[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return pairs
+}
+
+// runs are the positions of the backtick runs of s by their length.
+type runs map[int][]int
+
+func backtickRuns(s string) runs {
+	ticks := runs{}
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		n := runLength(s, i)
+		ticks[n] = append(ticks[n], i)
+		i += n
+	}
+	return ticks
+}
+
+// next returns the position of the first run of n backticks at or after
+// from, or -1.
+func (ticks runs) next(n, from int) int {
+	positions := ticks[n]
+	i := sort.SearchInts(positions, from)
+	if i == len(positions) {
+		return -1
+	}
+	return positions[i]
+}
+
+func runLength(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func unescape(s string) string {
+	return escapePattern.ReplaceAllString(s, "$1")
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isPunctRune(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
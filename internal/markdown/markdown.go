@@ -0,0 +1,190 @@
+// Package markdown renders the Markdown of posts and comments as HTML.
+//
+// The renderer supports headings, paragraphs, emphasis, block quotes, lists,
+// code spans and blocks, links, tables and horizontal rules. Code blocks
+// tagged with a language known to package highlight are highlighted with
+// classes, every line of a code block is in a span of class "line" for the
+// line numbers.
+//
+// It is safe for text written by anyone: HTML in the source is shown as text,
+// the output only contains the elements and attributes of the allow-list and
+// links only the URLs with an allowed scheme. The plain text is written by
+// the caller's text function, which has to keep to the allow-list as well.
+// Addresses in the text are linked too; like the other links written by users
+// leaving the forum they are marked rel="nofollow ugc".
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// Version changes whenever the HTML made from the same source changes, so
+// rendered HTML stored by an older version can be told apart and redone.
+const Version = 3
+
+// maxDepth is the deepest nesting of block quotes and lists. Deeper markers
+// are shown as text.
+const maxDepth = 16
+
+// allowed lists the elements the renderer writes with their attributes.
+var allowed = map[string][]string{
+	"a":          {"href", "title", "rel", "class"},
+	"blockquote": nil,
+	"br":         nil,
+	"code":       {"class"},
+	"del":        nil,
+	"em":         nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"hr":         nil,
+	"img":        {"src", "alt", "title"},
+	"li":         nil,
+	"ol":         {"start"},
+	"p":          nil,
+	"pre":        nil,
+	"span":       {"class"},
+	"strong":     nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"class"},
+	"th":         {"class"},
+	"thead":      nil,
+	"tr":         nil,
+	"ul":         nil,
+}
+
+// schemes are the URL schemes links may have. URLs without a scheme are
+// relative to the forum.
+var schemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// Render returns the HTML of the Markdown source. The plain text between the
+// markup is written by text, which has to escape it; html.EscapeString() is
+// used when text is nil.
+func Render(source string, text func(string) string) string {
+	if text == nil {
+		text = html.EscapeString
+	}
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "�")
+
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	r := &renderer{text: text}
+	r.blocks(lines, 0, false)
+	return strings.TrimSuffix(r.b.String(), "\n")
+}
+
+type renderer struct {
+	b    strings.Builder
+	text func(string) string
+	// nolinks is set inside the text of links, which can't contain others.
+	nolinks bool
+}
+
+// open writes the start tag of the element with the attributes given as name
+// and value pairs. Writing anything not allowed is a bug of the renderer.
+func (r *renderer) open(name string, attrs ...string) {
+	names, ok := allowed[name]
+	if !ok {
+		panic("markdown: element not allowed: " + name)
+	}
+	r.b.WriteString("<" + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if !contains(names, attrs[i]) {
+			panic("markdown: attribute not allowed: " + name + " " + attrs[i])
+		}
+		r.b.WriteString(" " + attrs[i] + `="` + html.EscapeString(attrs[i+1]) + `"`)
+	}
+	r.b.WriteString(">")
+}
+
+func (r *renderer) close(name string) {
+	r.b.WriteString("</" + name + ">")
+}
+
+// link checks the destination of a link and returns it ready for an href,
+// or false when it may not be linked.
+func link(dest string) (string, bool) {
+	if strings.ContainsAny(dest, "\x00\n") {
+		return "", false
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !schemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return strings.ReplaceAll(dest, " ", "%20"), true
+}
+
+// asBrowsed returns the address the way browsers read it in an href: they
+// drop tabs and newlines and take backslashes for slashes, so "/\host" is
+// the address of another host like "//host".
+func asBrowsed(href string) string {
+	return strings.NewReplacer("\t", "", "\n", "", "\r", "", `\`, "/").Replace(href)
+}
+
+// external reports whether the link leaves the forum.
+func external(href string) bool {
+	if strings.HasPrefix(asBrowsed(href), "//") {
+		return true
+	}
+	u, err := url.Parse(href)
+	return err == nil && (u.Scheme != "" || u.Host != "")
+}
+
+// local reports whether the address is a path on the forum, the only images
+// the Content-Security-Policy lets the pages show.
+func local(href string) bool {
+	href = asBrowsed(href)
+	return strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//")
+}
+
+// expandTabs replaces the tabs of the indentation with spaces up to the next
+// multiple of 4 columns.
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			b.WriteByte(' ')
+			col++
+		case '\t':
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		default:
+			b.WriteString(line[i:])
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
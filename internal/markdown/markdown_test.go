@@ -0,0 +1,105 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		// Links to forbidden schemes keep their text only.
+		{"javascript", "[x](javascript:alert(1))", `<p>x</p>`},
+		{"javascript upper case", "[x](JavaScript:alert(1))", `<p>x</p>`},
+		{"data", "[x](data:text/html,<script>)", `<p>x</p>`},
+		{"tab in the scheme", "[x](java\tscript:alert(1))", "<p>[x](java\tscript:alert(1))</p>"},
+		// Entities aren't decoded in addresses, they stay a relative path.
+		{"entity in the scheme", "[x](java&#x09;script:alert(1))", `<p><a href="java&amp;#x09;script:alert(1)">x</a></p>`},
+		{"entity for a letter", "[x](&#106;avascript:alert(1))", `<p><a href="&amp;#106;avascript:alert(1)">x</a></p>`},
+
+		{"local", "[x](/path)", `<p><a href="/path">x</a></p>`},
+		{"space", "[x](</a b>)", `<p><a href="/a%20b">x</a></p>`},
+		{"other host", "[x](//evil.com)", `<p><a href="//evil.com" rel="nofollow ugc">x</a></p>`},
+		{"backslash host", `[x](/\evil.com)`, `<p><a href="/\evil.com" rel="nofollow ugc">x</a></p>`},
+		{"mailto", "[m](mailto:x@y.z)", `<p><a href="mailto:x@y.z" rel="nofollow ugc">m</a></p>`},
+		{"address", "www.example.com/a?b.", `<p><a href="http://www.example.com/a?b" rel="nofollow ugc">www.example.com/a?b</a>.</p>`},
+		{"link in a link", "[a [b](/x)](/y)", `<p><a href="/y">a [b](/x)</a></p>`},
+
+		// Quotes can't leave the attributes.
+		{"title", `[x](https://example.com "a\" onmouseover=\"x")`, `<p><a href="https://example.com" title="a&#34; onmouseover=&#34;x" rel="nofollow ugc">x</a></p>`},
+		{"alt and title", `![a" onerror="x](/media/a.png "t\"")`, `<p><img src="/media/a.png" alt="a&#34; onerror=&#34;x" title="t&#34;"></p>`},
+		{"image", "![x](/a.png)", `<p><img src="/a.png" alt="x"></p>`},
+		{"image elsewhere", "![a](https://evil.com/a.png)", `<p><a href="https://evil.com/a.png" rel="nofollow ugc">a</a></p>`},
+		{"image backslash host", `![a](/\evil.com/a.png)`, `<p><a href="/\evil.com/a.png" rel="nofollow ugc">a</a></p>`},
+
+		{"script", "<script>alert(1)</script>", `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`},
+		{"inline html", `a <b onclick="x">b</b>`, `<p>a &lt;b onclick=&#34;x&#34;&gt;b&lt;/b&gt;</p>`},
+		{"code span", "`<code>`", `<p><code>&lt;code&gt;</code></p>`},
+		{"emphasis", "**bold** _em_ ~~del~~", `<p><strong>bold</strong> <em>em</em> <del>del</del></p>`},
+		{"heading and rule", "# h\n\n---", "<h1>h</h1>\n<hr>"},
+
+		{"code block", "```\n<b>x</b>\n```", "<pre><code><span class=\"line\">&lt;b&gt;x&lt;/b&gt;</span>\n</code></pre>"},
+		{"highlighted", "```go\nfmt.Println(\"<1>\")\n```",
+			"<pre><code class=\"language-go\"><span class=\"line\">fmt.<span class=\"hl-fn\">Println</span>(<span class=\"hl-str\">&#34;&lt;1&gt;&#34;</span>)</span>\n</code></pre>"},
+		{"quote in the language", "```\" onclick=\"x\nplain\n```", "<pre><code><span class=\"line\">plain</span>\n</code></pre>"},
+
+		{"table", "| a | b |\n|:--|--:|\n| 1 | <i>2</i> |",
+			"<table>\n<thead>\n<tr>\n<th class=\"align-left\">a</th>\n<th class=\"align-right\">b</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td class=\"align-left\">1</td>\n<td class=\"align-right\">&lt;i&gt;2&lt;/i&gt;</td>\n</tr>\n</tbody>\n</table>"},
+		{"ordered list", "3. a\n4. b", "<ol start=\"3\">\n<li>a</li>\n<li>b</li>\n</ol>"},
+		{"nested list", "- a\n  - b", "<ul>\n<li>a<ul>\n<li>b</li>\n</ul>\n</li>\n</ul>"},
+
+		// The deepest quote is maxDepth, further markers are text.
+		{"deepest quote", strings.Repeat("> ", maxDepth) + "x",
+			strings.Repeat("<blockquote>\n", maxDepth) + "<p>x</p>\n" + strings.TrimSuffix(strings.Repeat("</blockquote>\n", maxDepth), "\n")},
+		{"too deep", strings.Repeat("> ", maxDepth+1) + "x",
+			strings.Repeat("<blockquote>\n", maxDepth) + "<p>&gt; x</p>\n" + strings.TrimSuffix(strings.Repeat("</blockquote>\n", maxDepth), "\n")},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.source, nil); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExternal(t *testing.T) {
+	tests := []struct {
+		href     string
+		external bool
+		local    bool
+	}{
+		{"/media/a.png", false, true},
+		{"page", false, false},
+		{"#top", false, false},
+		{"//evil.com", true, false},
+		{`/\evil.com`, true, false},
+		{`\\evil.com`, true, false},
+		{"/\t/evil.com", true, false},
+		{"https://example.com", true, false},
+		{"mailto:x@y.z", true, false},
+	}
+	for _, tt := range tests {
+		if got := external(tt.href); got != tt.external {
+			t.Errorf("external(%q) = %v", tt.href, got)
+		}
+		if got := local(tt.href); got != tt.local {
+			t.Errorf("local(%q) = %v", tt.href, got)
+		}
+	}
+}
+
+// The renderer panics when it writes anything outside of the allow-list,
+// which no source may lead to.
+func TestRenderAllowed(t *testing.T) {
+	parts := []string{"# ", "> ", "- ", "1. ", "```js\n", "`", "**", "_", "~~", "[a](", "![b](", ")", "/x", "http://h.io", "<i>", "|", "\n", "|--|\n", "&amp;", `"`, "\\"}
+	for _, a := range parts {
+		for _, b := range parts {
+			for _, c := range parts {
+				Render(a+b+c+a, nil)
+			}
+		}
+	}
+}
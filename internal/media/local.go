@@ -0,0 +1,193 @@
+// Package media stores the files uploaded by the users, on the local disk or
+// in an S3 compatible bucket shared by several instances of the forum.
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// DefaultURL is the address the files are served at by default, through the
+// /media/ handler.
+const DefaultURL = "/media/"
+
+var ErrInvalidKey = errors.New("media: invalid key")
+
+// checkKey() rejects the keys which could reach outside of the store: they
+// are relative slash separated paths without empty, "." or ".." elements.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || path.Clean(key) != key {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
+
+// contentType() guesses the type of the file from the extension of the key.
+func contentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Local keeps the files under Dir, which must not be served by the static file
+// server. The type of a file is known by its extension. BaseURL defaults to
+// DefaultURL.
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+func (l *Local) file(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the content into a temporary file first, so the file at the key
+// is never seen half written.
+func (l *Local) Put(key, _ string, content io.Reader) error {
+	name, err := l.file(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (l *Local) Get(key string) (*models.MediaFile, error) {
+	name, err := l.file(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, models.ErrNoRecord
+	}
+
+	return &models.MediaFile{
+		MediaInfo: models.MediaInfo{
+			Key:         key,
+			ContentType: contentType(key),
+			Size:        info.Size(),
+			Modified:    info.ModTime(),
+		},
+		ReadSeekCloser: f,
+	}, nil
+}
+
+func (l *Local) Delete(key string) error {
+	name, err := l.file(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List walks the directories under Dir the prefix reaches into, skipping the
+// temporary files of unfinished uploads.
+func (l *Local) List(prefix string) ([]models.MediaInfo, error) {
+	root := l.Dir
+	if dir := path.Dir(prefix); dir != "." {
+		if err := checkKey(dir); err != nil {
+			return nil, err
+		}
+		root = filepath.Join(l.Dir, filepath.FromSlash(dir))
+	}
+
+	var files []models.MediaInfo
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.Dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, models.MediaInfo{
+			Key:         key,
+			ContentType: contentType(key),
+			Size:        info.Size(),
+			Modified:    info.ModTime(),
+		})
+		return nil
+	})
+	return files, err
+}
+
+func (l *Local) URL(key string) string {
+	return baseURL(l.BaseURL) + key
+}
+
+func baseURL(url string) string {
+	if url == "" {
+		return DefaultURL
+	}
+	return url
+}
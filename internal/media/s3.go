@@ -0,0 +1,389 @@
+package media
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// S3 keeps the files in a bucket of an S3 compatible object storage, e.g.
+// Amazon S3 or MinIO, reached at Endpoint (scheme://host[:port]) with path
+// style addresses. Requests are signed with AWS Signature Version 4. The
+// files are read through the forum, the bucket doesn't need to be public.
+// Region defaults to us-east-1, BaseURL to DefaultURL and Client to
+// http.DefaultClient.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	BaseURL   string
+	Client    *http.Client
+}
+
+// emptyHash is the SHA-256 of an empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Error is the error document of a failed request.
+type s3Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("media: s3: %d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("media: s3: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+func (s *S3) Put(key, contentType string, content io.Reader) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	resp, err := s.do(http.MethodPut, key, nil, header, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get streams the object from the response. Seeking only moves the offset, the
+// next read after a seek asks for the rest of the object from there with a
+// Range request, so ranged requests to the forum don't load the whole file.
+func (s *S3) Get(key string) (*models.MediaFile, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.get(key, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength < 0 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("media: s3: %s: no content length", key)
+	}
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return &models.MediaFile{
+		MediaInfo: models.MediaInfo{
+			Key:         key,
+			ContentType: resp.Header.Get("Content-Type"),
+			Size:        resp.ContentLength,
+			Modified:    modified,
+		},
+		ReadSeekCloser: &s3Object{s: s, key: key, size: resp.ContentLength, body: resp.Body},
+	}, nil
+}
+
+// get() requests the object from the offset on.
+func (s *S3) get(key string, offset int64) (*http.Response, error) {
+	var header http.Header
+	if offset > 0 {
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}}
+	}
+	resp, err := s.do(http.MethodGet, key, nil, header, nil)
+	if err != nil {
+		if e, ok := err.(*s3Error); ok && e.Status == http.StatusNotFound {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// s3Object reads an object of the bucket. body is read from bodyOffset while
+// offset is where the next read starts.
+type s3Object struct {
+	s          *S3
+	key        string
+	size       int64
+	offset     int64
+	body       io.ReadCloser
+	bodyOffset int64
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil || o.bodyOffset != o.offset {
+		if o.body != nil {
+			o.body.Close()
+			o.body = nil
+		}
+		resp, err := o.s.get(o.key, o.offset)
+		if err != nil {
+			return 0, err
+		}
+		o.body, o.bodyOffset = resp.Body, o.offset
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	o.bodyOffset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, errors.New("media: s3: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("media: s3: negative position")
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	return o.body.Close()
+}
+
+func (s *S3) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		if e, ok := err.(*s3Error); ok && e.Status == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is the response of ListObjectsV2.
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List follows the pages of ListObjectsV2. The listing doesn't have the types
+// of the objects, they are guessed from the keys.
+func (s *S3) List(prefix string) ([]models.MediaInfo, error) {
+	var files []models.MediaInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			files = append(files, models.MediaInfo{
+				Key:         c.Key,
+				ContentType: contentType(c.Key),
+				Size:        c.Size,
+				Modified:    c.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3) URL(key string) string {
+	return baseURL(s.BaseURL) + key
+}
+
+// do() sends a signed request for the object at the key, for the bucket when
+// the key is empty. Responses other than 2xx are returned as *s3Error.
+func (s *S3) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/" + s.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, body, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	e := &s3Error{Status: resp.StatusCode}
+	if b, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024)); err == nil {
+		xml.Unmarshal(b, e)
+	}
+	return nil, e
+}
+
+// sign() adds the AWS Signature Version 4 headers to the request. The host
+// and the x-amz-* headers are signed along with the payload hash.
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	payloadHash := emptyHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery() encodes the query sorted by name as the signature expects
+// it, which the request uses as well.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode() percent-encodes everything but the unreserved characters, and
+// the slashes unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+// Package mention finds @username mentions in text and renders the text with
+// the mentions linked.
+package mention
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// pattern matches a mention at the start of the text or after a character
+// which can't be part of a word or an email address. Dots and dashes are
+// allowed inside the name but not at its end, so "@alice." mentions alice.
+var pattern = regexp.MustCompile(`(^|[^\p{L}\p{N}_.@])@([\p{L}\p{N}_]+(?:[.-][\p{L}\p{N}_]+)*)`)
+
+// MaxNames is the number of names mentioned in a text which are linked and
+// notified, the further ones stay plain text.
+const MaxNames = 20
+
+// Names returns the mentioned names in the order of their first mention, at
+// most MaxNames of them.
+func Names(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+
+	for _, m := range pattern.FindAllStringSubmatch(text, -1) {
+		if !seen[m[2]] {
+			seen[m[2]] = true
+			names = append(names, m[2])
+		}
+		if len(names) == MaxNames {
+			break
+		}
+	}
+	return names
+}
+
+// HTML escapes the text and links the mentions of the known names to the
+// address returned by link. Mentions of other names stay plain text.
+func HTML(text string, known []string, link func(string) string) string {
+	var b strings.Builder
+	last := 0
+
+	for _, m := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		name := text[m[4]:m[5]]
+		if !contains(known, name) {
+			continue
+		}
+		// m[3] is the end of the character before the @.
+		b.WriteString(html.EscapeString(text[last:m[3]]))
+		b.WriteString(`<a class="mention" href="`)
+		b.WriteString(html.EscapeString(link(name)))
+		b.WriteString(`">@`)
+		b.WriteString(html.EscapeString(name))
+		b.WriteString(`</a>`)
+		last = m[5]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
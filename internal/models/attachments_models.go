@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Attachment is a file uploaded with a post. Images are shown in the gallery
+// of the post, the other files are listed for download. Width and Height are
+// only known for images, Thumbnail once the thumbnail job made it.
+type Attachment struct {
+	ID          int       `json:"id"`
+	PostID      int       `json:"-"`
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	Thumbnail   string    `json:"thumbnail,omitempty"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	Downloads   int       `json:"downloads"`
+	Created     time.Time `json:"created"`
+}
+
+// IsImage reports whether the attachment belongs to the gallery.
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// Images returns the attachments of the post shown in its gallery.
+func (p *Post) Images() []*Attachment {
+	var images []*Attachment
+	for _, a := range p.Attachments {
+		if a.IsImage() {
+			images = append(images, a)
+		}
+	}
+	return images
+}
+
+// Files returns the attachments of the post listed for download.
+func (p *Post) Files() []*Attachment {
+	var files []*Attachment
+	for _, a := range p.Attachments {
+		if !a.IsImage() {
+			files = append(files, a)
+		}
+	}
+	return files
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// Avatar is the picture of a user. Image names the files of the uploaded
+// avatar, it is empty when the user has the generated one.
+type Avatar struct {
+	UserID  int
+	Name    string
+	Image   string
+	Updated time.Time
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type DigestUsecases interface {
+	Settings(int) (*DigestSettings, error)
+	SetSettings(int, DigestSettings) error
+	History(int) ([]*DigestRecord, error)
+	SendDue(context.Context) error
+}
+
+type DigestRepository interface {
+	Settings(int) (*DigestSettings, error)
+	SetSettings(int, DigestSettings) error
+	Due(string, time.Duration) ([]*DigestSettings, error)
+	NewPosts([]string, time.Duration, int) ([]*Post, error)
+	Replies(int, time.Duration, int) ([]*DigestReply, error)
+	TopPosts(time.Duration, int) ([]*Post, error)
+	Record(DigestRecord) error
+	History(int, int) ([]*DigestRecord, error)
+}
+
+// Digest frequencies.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestPeriod returns the time covered by a digest of the frequency.
+func DigestPeriod(frequency string) time.Duration {
+	if frequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// DigestSettings is the digest subscription of a user. New posts are taken
+// from the categories, all of them when the list is empty.
+type DigestSettings struct {
+	UserID     int
+	Name       string
+	Email      string
+	Frequency  string
+	Categories []string
+}
+
+func (s DigestSettings) HasCategory(slug string) bool {
+	for _, c := range s.Categories {
+		if c == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// DigestReply is a comment written on a post of the digest recipient.
+type DigestReply struct {
+	PostID    int
+	PostTitle string
+	Author    string
+	Comment   string
+	Created   time.Time
+}
+
+// Digest is the content of a digest email.
+type Digest struct {
+	Name      string
+	Frequency string
+	BaseURL   string
+	Posts     []*Post
+	Replies   []*DigestReply
+	TopPosts  []*Post
+}
+
+func (d *Digest) Empty() bool {
+	return len(d.Posts) == 0 && len(d.Replies) == 0 && len(d.TopPosts) == 0
+}
+
+// States of a digest record.
+const (
+	DigestSent  = "sent"
+	DigestEmpty = "empty"
+)
+
+// DigestRecord records a digest built for a user. Digests without any content
+// are recorded as empty and not sent.
+type DigestRecord struct {
+	ID        int
+	UserID    int
+	Frequency string
+	Posts     int
+	Replies   int
+	TopPosts  int
+	Status    string
+	Created   time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// MaxDrafts is the number of drafts a user can keep.
+const MaxDrafts = 50
+
+// Draft is a post being written, kept until it is published or deleted.
+type Draft struct {
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Categories []string  `json:"categories"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
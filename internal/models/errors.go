@@ -7,4 +7,13 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
 	ErrDuplicateUsername  = errors.New("models: duplicate username")
+	ErrRejectedContent    = errors.New("models: content rejected by the filter")
+	ErrInviteRequired     = errors.New("models: registration requires an invite")
+	ErrInvalidInvite      = errors.New("models: invalid invite code")
+	ErrInvalidToken       = errors.New("models: invalid api token")
+	ErrAttachmentType     = errors.New("models: file type not allowed")
+	ErrAttachmentTooLarge = errors.New("models: attachment too large")
+	ErrTooManyDrafts      = errors.New("models: too many drafts")
+	ErrPollClosed         = errors.New("models: poll closed")
+	ErrInvalidVote        = errors.New("models: invalid poll vote")
 )
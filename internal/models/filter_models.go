@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"forum.bbilisbe/internal/filter"
+)
+
+type FilterUsecases interface {
+	Check(*filter.Content) (filter.Result, error)
+	Words() ([]*FilterWord, error)
+	WordInsert(FilterWord) error
+	WordDelete(int) error
+}
+
+type FilterRepository interface {
+	Words() ([]*FilterWord, error)
+	WordInsert(FilterWord) error
+	WordDelete(int) error
+	Recent(time.Duration) ([]string, error)
+}
+
+type FilterWord struct {
+	ID          int
+	Pattern     string
+	Regex       bool
+	Replacement string
+	Score       int
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+type JobRepository interface {
+	Insert(string, []byte, time.Duration, int) (int, error)
+	Claim(string, int) ([]*Job, error)
+	Active(string) (bool, error)
+	Done(int) error
+	Retry(int, string, time.Duration) error
+	Failed(int, string) error
+	Requeue() error
+	Reset(int) error
+	List(string, int) ([]*Job, error)
+	Counts() (map[string]int, error)
+	Prune(string, time.Duration) error
+}
+
+// JobQueue queues background jobs, the payload is passed JSON encoded to the
+// handler of the kind.
+type JobQueue interface {
+	Enqueue(string, any) error
+}
+
+// Kinds of the jobs queued by the usecases.
+const (
+	JobThumbnail = "images.thumbnail"
+)
+
+// States of a job.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+var JobStatuses = []string{JobPending, JobRunning, JobDone, JobFailed}
+
+// Job is a unit of background work. Payload is the JSON encoding of the
+// argument of the handler registered for the kind.
+type Job struct {
+	ID          int
+	Kind        string
+	Payload     []byte
+	Status      string
+	Attempts    int
+	MaxAttempts int
+	Error       string
+	RunAt       time.Time
+	Created     time.Time
+	Started     *time.Time
+	Finished    *time.Time
+}
+
+// JobSchedule is a recurring job of the runner.
+type JobSchedule struct {
+	Spec string
+	Kind string
+	Next time.Time
+}
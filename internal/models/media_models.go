@@ -0,0 +1,35 @@
+package models
+
+import (
+	"io"
+	"time"
+)
+
+// MediaStore keeps the files uploaded by the users, e.g. the post images and
+// the avatars, under slash separated keys such as "posts/4f1c….jpg".
+// Implementations must be safe for concurrent use.
+type MediaStore interface {
+	// Put stores the content under the key, replacing the previous one.
+	Put(key, contentType string, content io.Reader) error
+	// Get opens the file at the key, ErrNoRecord when there is none.
+	Get(key string) (*MediaFile, error)
+	// Delete removes the file at the key, missing files are no error.
+	Delete(key string) error
+	// List returns the files whose keys start with the prefix.
+	List(prefix string) ([]MediaInfo, error)
+	// URL returns the address the file at the key is served at.
+	URL(key string) string
+}
+
+type MediaInfo struct {
+	Key         string
+	ContentType string
+	Size        int64
+	Modified    time.Time
+}
+
+// MediaFile is an opened file of a MediaStore, the caller closes it.
+type MediaFile struct {
+	MediaInfo
+	io.ReadSeekCloser
+}
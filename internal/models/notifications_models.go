@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type NotificationUsecases interface {
+	Notify(Notification) error
+	Notifications(int, int) ([]*Notification, error)
+	Unread(int) (int, error)
+	MarkRead(int, int) error
+	MarkAllRead(int) error
+	Preferences(int) (map[string]bool, error)
+	SetPreferences(int, map[string]bool) error
+}
+
+type NotificationRepository interface {
+	Insert(Notification) error
+	List(int, int) ([]*Notification, error)
+	Unread(int) (int, error)
+	MarkRead(int, int) error
+	MarkAllRead(int) error
+	Disabled(int) ([]string, error)
+	SetDisabled(int, []string) error
+}
+
+// Types of notifications.
+const (
+	NotifyComment = "comment"
+	NotifyReply   = "reply"
+	NotifyMention = "mention"
+	NotifyVote    = "vote"
+)
+
+type NotificationType struct {
+	Type        string
+	Description string
+}
+
+// NotificationTypes lists the types a user can turn off, in the order of the
+// preferences form.
+var NotificationTypes = []NotificationType{
+	{NotifyComment, "Comments on my posts"},
+	{NotifyReply, "Replies on posts I commented on"},
+	{NotifyMention, "Mentions of my username"},
+	{NotifyVote, "Likes of my posts and comments"},
+}
+
+// Notification tells UserID that ActorID did something to a post or to one of
+// its comments. CommentID is 0 for notifications about the post itself.
+type Notification struct {
+	ID        int
+	UserID    int
+	Type      string
+	ActorID   int
+	Actor     string
+	PostID    int
+	PostTitle string
+	CommentID int
+	Read      bool
+	Created   time.Time
+}
+
+// Link returns the address of the post or comment the notification is about.
+func (n *Notification) Link() string {
+	if n.CommentID != 0 {
+		return fmt.Sprintf("/post/view/%d#comment-%d", n.PostID, n.CommentID)
+	}
+	return fmt.Sprintf("/post/view/%d", n.PostID)
+}
+
+type NotificationPrefsForm struct {
+	Enabled map[string]bool
+}
+
+func (f NotificationPrefsForm) HasType(typ string) bool {
+	return f.Enabled[typ]
+}
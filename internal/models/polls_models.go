@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+const (
+	MinPollOptions = 2
+	MaxPollOptions = 10
+)
+
+// Poll is attached to a post by its author. Every user has one vote, of a
+// single option or, when Multiple is set, of any number of them, which can be
+// changed until the poll closes.
+type Poll struct {
+	PostID      int           `json:"postID"`
+	Multiple    bool          `json:"multiple"`
+	Closes      *time.Time    `json:"closes,omitempty"`
+	HideResults bool          `json:"hideResults"`
+	Options     []*PollOption `json:"options"`
+	// Voters is the number of users who voted.
+	Voters int `json:"voters"`
+	// Voted are the options chosen by the user the poll is loaded for.
+	Voted []int `json:"voted"`
+	// Results reports whether the counts are given. With HideResults they
+	// are left out until the user voted or the poll closed.
+	Results bool `json:"results"`
+}
+
+type PollOption struct {
+	ID    int    `json:"id"`
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+// Closed reports whether the poll no longer takes votes.
+func (p *Poll) Closed() bool {
+	return p.Closes != nil && !p.Closes.After(time.Now())
+}
+
+// HasVoted reports whether the user the poll is loaded for chose the option.
+func (p *Poll) HasVoted(option int) bool {
+	for _, id := range p.Voted {
+		if id == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Percent returns the share of the voters who chose the option.
+func (p *Poll) Percent(o *PollOption) int {
+	if p.Voters == 0 {
+		return 0
+	}
+	return o.Votes * 100 / p.Voters
+}
+
+// PollForm is the poll of a post being created.
+type PollForm struct {
+	Options     []string   `json:"options"`
+	Multiple    bool       `json:"multiple"`
+	Closes      *time.Time `json:"closes,omitempty"`
+	HideResults bool       `json:"hideResults"`
+}
+
+// PollOptions returns the options entered in the create form followed by the
+// empty inputs left for more.
+func (f PostCreateForm) PollOptions() []string {
+	var options []string
+	if f.Poll != nil {
+		options = append(options, f.Poll.Options...)
+	}
+	for len(options) < MaxPollOptions {
+		options = append(options, "")
+	}
+	return options
+}
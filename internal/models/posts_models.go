@@ -1,7 +1,10 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"html/template"
+	"io"
 	"net/http"
 	"time"
 )
@@ -16,17 +19,51 @@ type PostUsecases interface {
 	DislikeInsert(UserDislikeData, int) error
 	IsLikedByUser(int, int) bool
 	IsDislikedByUser(int, int) bool
-	CommentInsert(string, int, int) error
+	CommentInsert(string, int, int) (int, error)
 	GetComments(int, int) ([]*PostComments, error)
 	CommentLikeInsert(CommentLikeData, int) error
 	IsCommentLikedByUser(int, int) bool
 	CommentDislikeInsert(CommentDislikeData, int) error
 	IsCommentDislikedByUser(int, int) bool
 	CategoryInsert(int64, []string) error
+	Pending() (map[int]*Post, error)
+	Approve(int) error
+	Reject(int) error
+	PendingComments() ([]*PostComments, error)
+	ApproveComment(int) error
+	RejectComment(int) error
+	List(PostFilter) ([]*Post, int, error)
+	Render([]*Post) error
+	GetComment(int) (*PostComments, error)
+	Report(int, int, string) error
+	Reports() ([]*Report, error)
+	DismissReports(int) error
+	SetLike(int, int, bool) (*UserLikeData, error)
+	SetDislike(int, int, bool) (*UserDislikeData, error)
+	SetCommentLike(int, int, bool) (*CommentLikeData, error)
+	SetCommentDislike(int, int, bool) (*CommentDislikeData, error)
+	SaveAttachment(string, io.Reader) (*Attachment, error)
+	DeleteAttachments([]*Attachment) error
+	GetAttachment(int) (*Attachment, error)
+	OpenAttachment(*Attachment) (*MediaFile, error)
+	CreateThumbnail(int) error
+	CleanupImages(time.Duration) error
+	ImportImages(string, string) error
+	Preview(string) (template.HTML, error)
+	SaveDraft(PostCreateForm, int) (int, error)
+	GetDraft(int, int) (*Draft, error)
+	Drafts(int) ([]*Draft, error)
+	DeleteDraft(int, int) error
+	Scheduled(int) ([]*Post, error)
+	Reschedule(int, int, time.Time) error
+	PublishNow(int, int) error
+	PublishScheduled(context.Context) error
+	Poll(int, int) (*Poll, error)
+	Vote(int, int, []int) (*Poll, error)
 }
 
 type PostRepository interface {
-	Insert(PostCreateForm, int) (int, error)
+	Insert(PostCreateForm, int, bool) (int, error)
 	Get(int) (*Post, error)
 	Latest() (map[int]*Post, error)
 	GetPostId()
@@ -35,35 +72,136 @@ type PostRepository interface {
 	DislikeInsert(UserDislikeData, int) error
 	IsLikedByUser(int, int) bool
 	IsDislikedByUser(int, int) bool
-	CommentInsert(string, int, int) error
+	SetLike(int, int, bool) (bool, error)
+	SetDislike(int, int, bool) (bool, error)
+	SetCommentLike(int, int, bool) (bool, error)
+	SetCommentDislike(int, int, bool) (bool, error)
+	CommentInsert(string, int, int) (int, error)
 	GetComments(int, int) ([]*PostComments, error)
 	CommentLikeInsert(CommentLikeData, int) error
 	IsCommentLikedByUser(int, int) bool
 	CommentDislikeInsert(CommentDislikeData, int) error
 	IsCommentDislikedByUser(int, int) bool
 	CategoryInsert(int64, []string) error
+	Approve(int) error
+	Delete(int) error
+	Pending() (map[int]*Post, error)
+	HoldComment(int) error
+	ApproveComment(int) error
+	DeleteComment(int) error
+	PendingComments() ([]*PostComments, error)
+	List(PostFilter) ([]*Post, error)
+	Count(PostFilter) (int, error)
+	GetComment(int) (*PostComments, error)
+	ReportInsert(int, int, string) (bool, error)
+	Reports() ([]*Report, error)
+	DeleteReports(int) error
+	Commenters(int) ([]int, error)
+	MentionInsert(int, int, []string) error
+	Mentions(int) (map[int][]string, error)
+	Mentioned(int, int) ([]int, error)
+	RenderedInsert(int, int, int, string) error
+	Rendered(int, int) (map[int]string, error)
+	ThumbnailInsert(int, string) error
+	MediaUsed(string) (bool, error)
+	ReplaceImage(string, string) error
+	Attachments(int) ([]*Attachment, error)
+	GetAttachment(int) (*Attachment, error)
+	AttachmentThumbnail(int, string) error
+	AttachmentDownloaded(int) error
+	DraftInsert(PostCreateForm, int) (int, error)
+	DraftUpdate(PostCreateForm, int) error
+	GetDraft(int, int) (*Draft, error)
+	Drafts(int) ([]*Draft, error)
+	DraftCount(int) (int, error)
+	DeleteDraft(int, int) error
+	Schedule(int, time.Time) error
+	Unschedule(int) error
+	DueScheduled() ([]int, error)
+	Scheduled(int) ([]*Post, error)
+	GetPoll(int) (*Poll, error)
+	PollVotes(int, int) ([]int, error)
+	PollVote(int, int, []int) error
 }
 
 type Post struct {
-	ID       int `json:"postID"`
-	Title    string
-	Content  string
-	Created  time.Time
-	Author   string
-	Likes    int `json:"likeCount"`
-	Dislikes int `json:"dislikeCount"`
-	Tags     string
-	Image    string
+	ID       int       `json:"postID"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Created  time.Time `json:"created"`
+	Author   string    `json:"author"`
+	Likes    int       `json:"likeCount"`
+	Dislikes int       `json:"dislikeCount"`
+	Tags     string    `json:"tags"`
+	Image    string    `json:"image,omitempty"`
+	// Thumbnail is the scaled down Image, once it has been made.
+	Thumbnail string   `json:"thumbnail,omitempty"`
+	Pending   bool     `json:"pending"`
+	Mentions  []string `json:"mentions,omitempty"`
+	// HTML is the Content rendered from Markdown, only loaded for a single
+	// post.
+	HTML template.HTML `json:"html,omitempty"`
+	// Attachments are only loaded for a single post.
+	Attachments []*Attachment `json:"attachments,omitempty"`
+	// PublishAt is set while the post is scheduled to be published later.
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+	// Poll is only loaded for a single post, for the user viewing it.
+	Poll *Poll `json:"poll,omitempty"`
+}
+
+// Hidden reports whether the post is left out of the feeds, being held for
+// moderation or scheduled. Only its author and the moderators see it.
+func (p *Post) Hidden() bool {
+	return p.Pending || p.PublishAt != nil
 }
 
 type PostComments struct {
-	Id         int
-	Comment    string
-	Author     string
-	Likes      int
-	Dislikes   int
-	IsLiked    bool
-	IsDisliked bool
+	Id         int    `json:"commentID"`
+	PostID     int    `json:"postID"`
+	Comment    string `json:"comment"`
+	Author     string `json:"author"`
+	Likes      int    `json:"commentLikeCount"`
+	Dislikes   int    `json:"commentDislikeCount"`
+	IsLiked    bool   `json:"isCommentLiked"`
+	IsDisliked bool   `json:"isCommentDisliked"`
+	Pending    bool   `json:"pending"`
+	// Created is unknown for the comments written before it was recorded.
+	Created  *time.Time `json:"created,omitempty"`
+	Mentions []string   `json:"mentions,omitempty"`
+	// HTML is the Comment rendered from Markdown.
+	HTML template.HTML `json:"html,omitempty"`
+}
+
+// PostFilter selects the published posts having all the categories and, when
+// Author is set, written by the author.
+type PostFilter struct {
+	Categories []string
+	Author     int
+	Limit      int
+	Offset     int
+}
+
+type Category struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+var Categories = []Category{
+	{"music", "K-Music"},
+	{"dramas", "K-Dramas"},
+	{"movies", "K-Movies"},
+	{"actors", "Actors"},
+	{"idols", "Idols"},
+}
+
+// IsCategory reports whether the slug names a known category.
+func IsCategory(slug string) bool {
+	for _, c := range Categories {
+		if c.Slug == slug {
+			return true
+		}
+	}
+	return false
 }
 
 type PostModel struct {
@@ -71,10 +209,29 @@ type PostModel struct {
 }
 
 type PostCreateForm struct {
-	Title      string
-	Content    string
-	Categories []string
-	ImageURL   string
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Categories []string `json:"categories"`
+	ImageURL   string   `json:"-"`
+	// Attachments are the uploaded files, already stored. ImageURL is the
+	// first of the images when it isn't set.
+	Attachments []*Attachment `json:"-"`
+	// Draft is the draft the post is written from, deleted once the post is
+	// created, or updated when the form is saved as a draft again.
+	Draft int `json:"-"`
+	// PublishAt schedules the post, it is hidden until then.
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+	// Poll is attached to the post when set. Drafts don't keep it.
+	Poll *PollForm `json:"poll,omitempty"`
+}
+
+func (f PostCreateForm) HasCategory(slug string) bool {
+	for _, c := range f.Categories {
+		if c == slug {
+			return true
+		}
+	}
+	return false
 }
 
 type CommentLikeData struct {
@@ -90,3 +247,11 @@ type CommentDislikeData struct {
 	Dislikes   int  `json:"commentDislikeCount"`
 	IsDisliked bool `json:"isCommentDisliked"`
 }
+
+// Report is a post reported by users together with their reasons.
+type Report struct {
+	PostID  int
+	Title   string
+	Count   int
+	Reasons string
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// Tabs of a profile page.
+const (
+	ProfilePosts    = "posts"
+	ProfileComments = "comments"
+	ProfileLikes    = "likes"
+)
+
+// Profile is the public page of a user. Posts, Comments and Likes count the
+// published posts and comments of the user and the likes they received.
+type Profile struct {
+	ID           int
+	Name         string
+	Created      time.Time
+	Bio          string
+	ShowComments bool
+	ShowLikes    bool
+	Posts        int
+	Comments     int
+	Likes        int
+}
+
+// ProfileComment is a comment listed on the profile of its author.
+type ProfileComment struct {
+	ID        int
+	PostID    int
+	PostTitle string
+	Comment   string
+	Likes     int
+	Created   *time.Time
+}
+
+// ProfileForm holds the bio and the privacy settings of the profile.
+type ProfileForm struct {
+	Bio          string
+	ShowComments bool
+	ShowLikes    bool
+}
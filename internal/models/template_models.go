@@ -1,15 +1,47 @@
 package models
 
-import "forum.bbilisbe/internal/validator"
+import (
+	"forum.bbilisbe/internal/captcha"
+	"forum.bbilisbe/internal/validator"
+)
 
 type TemplateData struct {
-	CurrentYear int
-	Post        *Post
-	Posts       map[int]*Post
-	Form        any
-	Logged      bool
-	IsLiked     bool
-	IsDisliked  bool
-	Comments    []*PostComments
+	CurrentYear     int
+	Post            *Post
+	Posts           map[int]*Post
+	Form            any
+	Logged          bool
+	IsModerator     bool
+	IsAdmin         bool
+	IsLiked         bool
+	IsDisliked      bool
+	IsAuthor        bool
+	Comments        []*PostComments
+	FilterWords     []*FilterWord
+	Captcha         *captcha.Challenge
+	ReportCaptcha   *captcha.Challenge
+	InviteOnly      bool
+	CanInvite       bool
+	Invites         []*Invite
+	InviteTree      []*InviteNode
+	APITokens       []*APIToken
+	NewAPIToken     string
+	Reports         []*Report
+	Webhooks        []*Webhook
+	Deliveries      []*WebhookDelivery
+	Unread          int
+	Notifications   []*Notification
+	Digests         []*DigestRecord
+	Jobs            []*Job
+	JobCounts       map[string]int
+	JobStatus       string
+	JobSchedules    []JobSchedule
+	Profile         *Profile
+	ProfileTab      string
+	ProfilePosts    []*Post
+	ProfileComments []*ProfileComment
+	Draft           *Draft
+	Drafts          []*Draft
+	Scheduled       []*Post
 	validator.Validator
 }
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Scopes a personal API token can be granted.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeVote  = "vote"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeVote}
+
+type contextKey string
+
+// APITokenContextKey holds the *APIToken of requests authenticated with a
+// bearer token.
+const APITokenContextKey = contextKey("apiToken")
+
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Prefix   string
+	Scopes   []string
+	Created  time.Time
+	LastUsed *time.Time
+}
+
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *APIToken) ScopeList() string {
+	return strings.Join(t.Scopes, ", ")
+}
+
+type APITokenForm struct {
+	Name   string
+	Scopes []string
+}
+
+func (f APITokenForm) HasScope(scope string) bool {
+	for _, s := range f.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
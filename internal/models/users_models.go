@@ -2,6 +2,8 @@ package models
 
 import (
 	"database/sql"
+	"image"
+	"io"
 	"net/http"
 	"time"
 )
@@ -17,9 +19,36 @@ type UserUsecases interface {
 	GetToken(string) (string, error)
 	IsExpired(string) bool
 	RemoveToken(string) error
+	RemoveExpiredTokens() error
 	IsLogged(*http.Request) bool
 	GetUserLikes(int) (map[int]*Post, error)
 	GetUserPosts(int) (map[int]*Post, error)
+	IsModerator(int) bool
+	IsAdmin(int) bool
+	InsertWithInvite(UserSignupForm) error
+	CanInvite(int) bool
+	CreateInvite(int, int, time.Duration) (*Invite, error)
+	GetInvite(int) (*Invite, error)
+	GetUserInvites(int) ([]*Invite, error)
+	GetInvites() ([]*Invite, error)
+	RevokeInvite(int) error
+	GetInviteTree() ([]*InviteNode, error)
+	GetUser(int) (*User, error)
+	GetUserIdByName(string) (int, error)
+	SearchUsernames(string, int) ([]string, error)
+	GetProfile(string) (*Profile, error)
+	GetProfilePosts(int, int) ([]*Post, error)
+	GetProfileComments(int, int) ([]*ProfileComment, error)
+	GetProfileLikes(int, int) ([]*Post, error)
+	UpdateProfile(int, ProfileForm) error
+	GetAvatar(string, int) ([]byte, time.Time, error)
+	SetAvatar(int, io.Reader, image.Rectangle) error
+	RemoveAvatar(int) error
+	ImportAvatars(string) error
+	CreateAPIToken(int, APITokenForm) (string, error)
+	GetAPITokens(int) ([]*APIToken, error)
+	RevokeAPIToken(int, int) error
+	AuthenticateAPIToken(string) (*APIToken, error)
 }
 
 type UserRepository interface {
@@ -33,19 +62,45 @@ type UserRepository interface {
 	GetToken(string) (string, error)
 	IsExpired(string) (*time.Time, error)
 	RemoveToken(string) error
+	RemoveExpiredTokens() error
 	IsLogged()
 	GetUserLikes(int) (map[int]*Post, error)
 	GetUserPosts(int) (map[int]*Post, error)
+	GetUserCreated(int) (time.Time, error)
+	GetTrustScore(int) (int, error)
+	InviteInsert(string, int, int, time.Duration) (int, error)
+	GetInvite(int) (*Invite, error)
+	GetUserInvites(int) ([]*Invite, error)
+	GetInvites() ([]*Invite, error)
+	RevokeInvite(int) error
+	InviteSignup(string, string, string, string) (int, error)
+	GetInvitedUsers() ([]*InvitedUser, error)
+	GetUser(int) (*User, error)
+	GetUserIdByName(string) (int, error)
+	SearchUsernames(string, int) ([]string, error)
+	GetProfile(string) (*Profile, error)
+	GetProfilePosts(int, int) ([]*Post, error)
+	GetProfileComments(int, int) ([]*ProfileComment, error)
+	GetProfileLikes(int, int) ([]*Post, error)
+	UpdateProfile(int, ProfileForm) error
+	GetAvatar(string) (*Avatar, error)
+	SetAvatar(int, string) (string, error)
+	RemoveAvatar(int) (string, error)
+	APITokenInsert(int, string, string, string, []string) error
+	GetAPITokens(int) ([]*APIToken, error)
+	DeleteAPIToken(int, int) error
+	GetAPITokenByHash(string) (*APIToken, error)
+	TouchAPIToken(int) error
 }
 
 type User struct {
-	ID             int
-	Name           string
-	Email          string
-	HashedPassword []byte
-	Token          *string
-	Expiry         *time.Time
-	Created        time.Time
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	Email          string     `json:"email"`
+	HashedPassword []byte     `json:"-"`
+	Token          *string    `json:"-"`
+	Expiry         *time.Time `json:"-"`
+	Created        time.Time  `json:"created"`
 }
 
 type UserModel struct {
@@ -56,6 +111,45 @@ type UserSignupForm struct {
 	Name     string
 	Email    string
 	Password string
+	Invite   string
+}
+
+type Invite struct {
+	ID        int
+	Code      string
+	CreatedBy int
+	Creator   string
+	MaxUses   int
+	Uses      int
+	Expiry    time.Time
+	Revoked   bool
+	Created   time.Time
+}
+
+// Usable reports whether the invite can still be used to sign up.
+func (i *Invite) Usable() bool {
+	return !i.Revoked && i.Uses < i.MaxUses && time.Now().Before(i.Expiry)
+}
+
+type InvitedUser struct {
+	UserID    int
+	Name      string
+	InvitedBy int
+	Code      string
+}
+
+// InviteNode is a user in the invite tree together with the users who signed
+// up with their codes.
+type InviteNode struct {
+	UserID   int
+	Name     string
+	Code     string
+	Children []*InviteNode
+}
+
+type InviteCreateForm struct {
+	MaxUses int
+	Days    int
 }
 
 type UserLoginForm struct {
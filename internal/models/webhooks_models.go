@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+type WebhookUsecases interface {
+	Webhooks() ([]*Webhook, error)
+	WebhookInsert(WebhookForm) error
+	WebhookDelete(int) error
+	Deliveries(int) ([]*WebhookDelivery, error)
+	Emit(string, any) error
+	Deliver(context.Context) error
+}
+
+type WebhookRepository interface {
+	Insert(string, string, []string) error
+	Delete(int) error
+	List() ([]*Webhook, error)
+	Enqueue(string, []byte) error
+	Due(int) ([]*WebhookDelivery, error)
+	Delivered(int, int) error
+	Retry(int, int, string, time.Duration) error
+	Failed(int, int, string) error
+	Deliveries(int) ([]*WebhookDelivery, error)
+}
+
+type Webhook struct {
+	ID      int
+	URL     string
+	Secret  string
+	Events  []string
+	Created time.Time
+}
+
+func (w *Webhook) EventList() string {
+	return strings.Join(w.Events, ", ")
+}
+
+// States of a webhook delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookDelivery struct {
+	ID           int
+	WebhookID    int
+	URL          string
+	Secret       string
+	Event        string
+	Payload      []byte
+	Status       string
+	Attempts     int
+	ResponseCode int
+	Error        string
+	Created      time.Time
+	NextAttempt  *time.Time
+	Delivered    *time.Time
+}
+
+type WebhookForm struct {
+	URL    string
+	Secret string
+	Events []string
+}
+
+func (f WebhookForm) HasEvent(event string) bool {
+	for _, e := range f.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Policy allows Limit requests per Per, refilled continuously.
+type Policy struct {
+	Limit int
+	Per   time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket rate limiter keeping one bucket per key.
+type Limiter struct {
+	mu      sync.Mutex
+	policy  Policy
+	buckets map[string]*bucket
+	swept   time.Time
+	// now is the clock of the limiter, replaced by the tests.
+	now func() time.Time
+}
+
+func New(policy Policy) *Limiter {
+	return &Limiter{
+		policy:  policy,
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+		now:     time.Now,
+	}
+}
+
+// rate returns the number of tokens refilled per second.
+func (l *Limiter) rate() float64 {
+	return float64(l.policy.Limit) / l.policy.Per.Seconds()
+}
+
+// Allow takes a token from the bucket of the key. When the bucket is empty it
+// reports how long to wait until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.policy.Limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.policy.Limit), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.policy.Limit), b.tokens+now.Sub(b.last).Seconds()*l.rate())
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate() * float64(time.Second))
+	return false, wait
+}
+
+// RetryAfter formats the wait returned by Allow as the value of a Retry-After
+// header, in whole seconds rounded up.
+func RetryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+// sweep drops the buckets which have been refilled completely, so the map
+// does not grow with every client ever seen.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.policy.Per {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate() >= float64(l.policy.Limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// clock is the time of a limiter in the tests, moved on by hand.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(policy Policy) (*Limiter, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(policy)
+	l.now = func() time.Time { return c.now }
+	l.swept = c.now
+	return l, c
+}
+
+func TestAllow(t *testing.T) {
+	type step struct {
+		advance time.Duration
+		key     string
+		ok      bool
+		wait    time.Duration
+	}
+	tests := []struct {
+		name   string
+		policy Policy
+		steps  []step
+	}{
+		{
+			name:   "burst up to the limit",
+			policy: Policy{Limit: 3, Per: 3 * time.Second},
+			steps: []step{
+				{0, "a", true, 0},
+				{0, "a", true, 0},
+				{0, "a", true, 0},
+				{0, "a", false, time.Second},
+				{0, "b", true, 0},
+			},
+		},
+		{
+			name:   "refill",
+			policy: Policy{Limit: 3, Per: 3 * time.Second},
+			steps: []step{
+				{0, "a", true, 0},
+				{0, "a", true, 0},
+				{0, "a", true, 0},
+				{500 * time.Millisecond, "a", false, 500 * time.Millisecond},
+				{500 * time.Millisecond, "a", true, 0},
+				{0, "a", false, time.Second},
+				{2 * time.Second, "a", true, 0},
+				{0, "a", true, 0},
+				{0, "a", false, time.Second},
+			},
+		},
+		{
+			name:   "refill stops at the limit",
+			policy: Policy{Limit: 2, Per: time.Minute},
+			steps: []step{
+				{0, "a", true, 0},
+				{time.Hour, "a", true, 0},
+				{0, "a", true, 0},
+				{0, "a", false, 30 * time.Second},
+			},
+		},
+		{
+			name:   "failed requests take no token",
+			policy: Policy{Limit: 1, Per: 10 * time.Second},
+			steps: []step{
+				{0, "a", true, 0},
+				{0, "a", false, 10 * time.Second},
+				{4 * time.Second, "a", false, 6 * time.Second},
+				{6 * time.Second, "a", true, 0},
+			},
+		},
+		{
+			name:   "no limit",
+			policy: Policy{Limit: 0, Per: time.Hour},
+			steps: []step{
+				{0, "a", true, 0},
+				{0, "a", true, 0},
+				{0, "a", true, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, c := newTestLimiter(tt.policy)
+			for i, s := range tt.steps {
+				c.advance(s.advance)
+				// The tokens are counted in floating point, the waits
+				// may be off by rounding.
+				ok, wait := l.Allow(s.key)
+				if ok != s.ok || wait < s.wait-time.Microsecond || wait > s.wait+time.Microsecond {
+					t.Errorf("step %d: Allow(%q) = %v, %v, want %v, %v", i, s.key, ok, wait, s.ok, s.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		wait time.Duration
+		want string
+	}{
+		{time.Nanosecond, "1"},
+		{time.Second, "1"},
+		{1200 * time.Millisecond, "2"},
+		{90 * time.Second, "90"},
+	}
+	for _, tt := range tests {
+		if got := RetryAfter(tt.wait); got != tt.want {
+			t.Errorf("RetryAfter(%v) = %q, want %q", tt.wait, got, tt.want)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, c := newTestLimiter(Policy{Limit: 2, Per: time.Minute})
+	keys := func() []string {
+		var keys []string
+		for key := range l.buckets {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	l.Allow("a")
+	c.advance(30 * time.Second)
+	l.Allow("b")
+	c.advance(15 * time.Second)
+	l.Allow("c")
+	l.Allow("c")
+	if got := keys(); len(got) != 3 {
+		t.Fatalf("buckets %v before the sweep, want a, b and c", got)
+	}
+
+	// A minute after the last sweep the full buckets of a and b are dropped,
+	// c has half a token back.
+	c.advance(15 * time.Second)
+	l.Allow("d")
+	if got := keys(); len(got) != 2 || got[0] != "c" || got[1] != "d" {
+		t.Errorf("buckets %v after the sweep, want c and d", got)
+	}
+
+	// A dropped bucket starts full again.
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("a was limited after the sweep")
+	}
+}
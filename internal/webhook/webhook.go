@@ -0,0 +1,111 @@
+// Package webhook signs and sends the event notifications the forum delivers
+// to the URLs configured by the admins.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The events a webhook can subscribe to.
+const (
+	PostCreated    = "post.created"
+	CommentCreated = "comment.created"
+	PostReported   = "post.reported"
+	UserSignedUp   = "user.signed_up"
+)
+
+var Events = []string{PostCreated, CommentCreated, PostReported, UserSignedUp}
+
+// Headers sent with every delivery. The signature is the hex encoded
+// HMAC-SHA256 of the request body keyed with the secret of the webhook,
+// prefixed with "sha256=".
+const (
+	EventHeader     = "X-Forum-Event"
+	DeliveryHeader  = "X-Forum-Delivery"
+	SignatureHeader = "X-Forum-Signature"
+)
+
+// Payload is the JSON body of a delivery. ID identifies the delivery and stays
+// the same when it is retried.
+type Payload struct {
+	ID      int             `json:"id"`
+	Event   string          `json:"event"`
+	Created time.Time       `json:"created"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify() checks the signature of a received body, for use by receivers.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = 6 * time.Hour
+)
+
+// Backoff() returns the time to wait before retrying a delivery which failed
+// for the attempt-th time: 30s doubling up to 6h.
+func Backoff(attempt int) time.Duration {
+	wait := backoffBase
+	for i := 1; i < attempt && wait < backoffMax; i++ {
+		wait *= 2
+	}
+	if wait > backoffMax {
+		wait = backoffMax
+	}
+	return wait
+}
+
+// Sender posts payloads to the webhook URLs.
+type Sender struct {
+	Client *http.Client
+}
+
+// Send() delivers the payload and returns the status code of the response.
+// Responses other than 2xx are reported as errors. The request is abandoned
+// when the context ends.
+func (s *Sender) Send(ctx context.Context, url, secret string, p Payload) (int, error) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "forum-webhooks/1")
+	req.Header.Set(EventHeader, p.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(p.ID))
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook: unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
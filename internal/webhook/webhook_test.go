@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 test value from RFC 4231 section 4.3.
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1,"event":"post.created"}`)
+	signature := Sign("secret", body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", "secret", body, signature, true},
+		{"other secret", "other", body, signature, false},
+		{"changed body", "secret", []byte(`{"id":2,"event":"post.created"}`), signature, false},
+		{"missing prefix", "secret", body, signature[len("sha256="):], false},
+		{"empty", "secret", body, "", false},
+	}
+	for _, tt := range tests {
+		if got := Verify(tt.secret, tt.body, tt.signature); got != tt.want {
+			t.Errorf("%s: Verify() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{9, 128 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	status := http.StatusOK
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sender := &Sender{Client: server.Client()}
+	payload := Payload{ID: 7, Event: PostCreated, Created: time.Now().UTC(), Data: json.RawMessage(`{"post":{}}`)}
+
+	code, err := sender.Send(context.Background(), server.URL, "secret", payload)
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("Send() = %d, %v", code, err)
+	}
+	if got := received.Header.Get(EventHeader); got != PostCreated {
+		t.Errorf("%s = %q", EventHeader, got)
+	}
+	if got := received.Header.Get(DeliveryHeader); got != "7" {
+		t.Errorf("%s = %q", DeliveryHeader, got)
+	}
+	if !Verify("secret", body, received.Header.Get(SignatureHeader)) {
+		t.Error("the signature doesn't match the body")
+	}
+	var sent Payload
+	if err := json.Unmarshal(body, &sent); err != nil || sent.ID != 7 || sent.Event != PostCreated {
+		t.Errorf("sent payload %s, %v", body, err)
+	}
+
+	status = http.StatusInternalServerError
+	code, err = sender.Send(context.Background(), server.URL, "secret", payload)
+	if err == nil || code != http.StatusInternalServerError {
+		t.Errorf("Send() to a failing receiver = %d, %v", code, err)
+	}
+}
@@ -66,3 +66,236 @@ PRAGMA foreign_keys = ON;
 		postid INTEGER,
 		category TEXT
 	);
+
+	CREATE TABLE IF NOT EXISTS pending_posts (
+		postid INTEGER NOT NULL PRIMARY KEY,
+		created DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS pending_comments (
+		commentid INTEGER NOT NULL PRIMARY KEY,
+		created DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS filter_words (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		pattern TEXT NOT NULL,
+		regex BOOLEAN NOT NULL,
+		replacement TEXT NOT NULL,
+		score INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS invites (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		code TEXT NOT NULL,
+		createdby INTEGER NOT NULL,
+		max_uses INTEGER NOT NULL,
+		uses INTEGER NOT NULL,
+		expiry DATETIME NOT NULL,
+		revoked BOOLEAN NOT NULL,
+		created DATETIME NOT NULL,
+		CONSTRAINT unique_code UNIQUE (code)
+	);
+
+	CREATE TABLE IF NOT EXISTS invited_users (
+		userid INTEGER NOT NULL PRIMARY KEY,
+		inviteid INTEGER NOT NULL,
+		invitedby INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		userid INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		token_hash CHAR(64) NOT NULL,
+		prefix TEXT NOT NULL,
+		scopes TEXT NOT NULL,
+		created DATETIME NOT NULL,
+		last_used DATETIME,
+		CONSTRAINT unique_token_hash UNIQUE (token_hash)
+	);
+
+	CREATE TABLE IF NOT EXISTS comment_created (
+		commentid INTEGER NOT NULL PRIMARY KEY,
+		created DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		created DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		webhookid INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_code INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created DATETIME NOT NULL,
+		next_attempt DATETIME,
+		delivered DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt);
+
+	CREATE TABLE IF NOT EXISTS post_reports (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		postid INTEGER NOT NULL,
+		userid INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		created DATETIME NOT NULL,
+		CONSTRAINT unique_report UNIQUE (postid, userid)
+	);
+
+	CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		userid INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		actorid INTEGER NOT NULL,
+		postid INTEGER NOT NULL,
+		commentid INTEGER NOT NULL DEFAULT 0,
+		read BOOLEAN NOT NULL DEFAULT false,
+		created DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(userid, read);
+
+	CREATE TABLE IF NOT EXISTS disabled_notifications (
+		userid INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		PRIMARY KEY (userid, type)
+	);
+
+	CREATE TABLE IF NOT EXISTS mentions (
+		postid INTEGER NOT NULL,
+		commentid INTEGER NOT NULL DEFAULT 0,
+		userid INTEGER NOT NULL,
+		PRIMARY KEY (postid, commentid, userid)
+	);
+
+	CREATE TABLE IF NOT EXISTS digest_settings (
+		userid INTEGER NOT NULL PRIMARY KEY,
+		frequency TEXT NOT NULL,
+		categories TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS digests (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		userid INTEGER NOT NULL,
+		frequency TEXT NOT NULL,
+		posts INTEGER NOT NULL,
+		replies INTEGER NOT NULL,
+		top_posts INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		created DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_digests_user ON digests(userid, created);
+
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		run_at DATETIME NOT NULL,
+		created DATETIME NOT NULL,
+		started DATETIME,
+		finished DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(status, kind, run_at);
+
+	CREATE TABLE IF NOT EXISTS profiles (
+		userid INTEGER NOT NULL PRIMARY KEY,
+		bio TEXT NOT NULL,
+		show_comments BOOLEAN NOT NULL,
+		show_likes BOOLEAN NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS avatars (
+		userid INTEGER NOT NULL PRIMARY KEY,
+		image TEXT NOT NULL,
+		updated DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS post_thumbnails (
+		postid INTEGER NOT NULL PRIMARY KEY,
+		thumbnail TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		postid INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		url TEXT NOT NULL,
+		thumbnail TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		downloads INTEGER NOT NULL,
+		created DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_attachments_post ON attachments(postid);
+
+	CREATE TABLE IF NOT EXISTS rendered (
+		postid INTEGER NOT NULL,
+		commentid INTEGER NOT NULL DEFAULT 0,
+		version INTEGER NOT NULL,
+		html TEXT NOT NULL,
+		PRIMARY KEY (postid, commentid)
+	);
+
+	CREATE TABLE IF NOT EXISTS drafts (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		userid INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		categories TEXT NOT NULL,
+		created DATETIME NOT NULL,
+		updated DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_drafts_user ON drafts(userid, updated);
+
+	CREATE TABLE IF NOT EXISTS scheduled_posts (
+		postid INTEGER NOT NULL PRIMARY KEY,
+		publish_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_scheduled_posts_due ON scheduled_posts(publish_at);
+
+	CREATE VIEW IF NOT EXISTS hidden_posts AS
+		SELECT postid FROM pending_posts UNION SELECT postid FROM scheduled_posts;
+
+	CREATE TABLE IF NOT EXISTS polls (
+		postid INTEGER NOT NULL PRIMARY KEY,
+		multiple BOOLEAN NOT NULL,
+		closes DATETIME,
+		hide_results BOOLEAN NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS poll_options (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		postid INTEGER NOT NULL,
+		text TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_poll_options_post ON poll_options(postid, id);
+
+	CREATE TABLE IF NOT EXISTS poll_votes (
+		postid INTEGER NOT NULL,
+		userid INTEGER NOT NULL,
+		optionid INTEGER NOT NULL,
+		PRIMARY KEY (postid, userid, optionid)
+	);
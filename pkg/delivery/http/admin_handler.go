@@ -0,0 +1,80 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum.bbilisbe/internal/filter"
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/validator"
+)
+
+func (h *Handler) adminFilters(w http.ResponseWriter, r *http.Request) {
+	data := h.newTemplateData(r)
+	form := models.FilterWord{}
+
+	if r.Method == http.MethodPost {
+		err := r.ParseForm()
+		if err != nil {
+			h.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form.Pattern = r.PostForm.Get("pattern")
+		form.Regex = r.PostForm.Get("regex") == "on"
+		form.Replacement = r.PostForm.Get("replacement")
+		score, err := strconv.Atoi(r.PostForm.Get("score"))
+
+		data.CheckField(err == nil && score >= 0, "score", "This field must be a non-negative number")
+		data.CheckField(validator.NotBlank(form.Pattern), "pattern", "This field cannot be blank")
+		if _, err := filter.CompileWord(filter.Word{Pattern: form.Pattern, Regex: form.Regex}); err != nil {
+			data.AddFieldError("pattern", "This field must be a valid regular expression")
+		}
+		form.Score = score
+
+		if data.Valid() {
+			err = h.FUsecase.WordInsert(form)
+			if err != nil {
+				h.serverError(w, err)
+				return
+			}
+			http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+			return
+		}
+	}
+
+	words, err := h.FUsecase.Words()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	data.FilterWords = words
+	data.Form = form
+
+	status := http.StatusOK
+	if !data.Valid() {
+		status = http.StatusUnprocessableEntity
+	}
+	h.render(w, status, "filters.html", data)
+}
+
+func (h *Handler) adminFilterDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.FUsecase.WordDelete(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+}
@@ -0,0 +1,630 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/ratelimit"
+	"forum.bbilisbe/internal/validator"
+)
+
+const (
+	apiPrefix      = "/api/v1"
+	apiMaxBody     = 1 << 20
+	apiPerPage     = 20
+	apiMaxPerPage  = 100
+	apiPathParamID = "{id}"
+	apiMaxUsers    = 10
+)
+
+// apiRoute is an endpoint of the JSON API. The pattern may contain a single
+// {id} segment which is passed to the handler. Requests authenticated with an
+// API token need a token granted the scope of the route.
+type apiRoute struct {
+	method  string
+	pattern string
+	auth    bool
+	scope   string
+	limit   *ratelimit.Limiter
+	handler func(w http.ResponseWriter, r *http.Request, id int)
+}
+
+func (h *Handler) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{http.MethodGet, "/posts", false, models.ScopeRead, nil, h.apiPostList},
+		{http.MethodPost, "/posts", true, models.ScopeWrite, h.postLimit, h.apiPostCreate},
+		{http.MethodGet, "/posts/{id}", false, models.ScopeRead, nil, h.apiPostGet},
+		{http.MethodGet, "/posts/{id}/comments", false, models.ScopeRead, nil, h.apiCommentList},
+		{http.MethodPost, "/posts/{id}/comments", true, models.ScopeWrite, h.commentLimit, h.apiCommentCreate},
+		{http.MethodPost, "/posts/{id}/like", true, models.ScopeVote, h.voteLimit, h.apiPostLike},
+		{http.MethodPost, "/posts/{id}/dislike", true, models.ScopeVote, h.voteLimit, h.apiPostDislike},
+		{http.MethodGet, "/posts/{id}/poll", false, models.ScopeRead, nil, h.apiPollGet},
+		{http.MethodPost, "/posts/{id}/poll", true, models.ScopeVote, h.voteLimit, h.apiPollVote},
+		{http.MethodPost, "/comments/{id}/like", true, models.ScopeVote, h.voteLimit, h.apiCommentLike},
+		{http.MethodPost, "/comments/{id}/dislike", true, models.ScopeVote, h.voteLimit, h.apiCommentDislike},
+		{http.MethodGet, "/categories", false, models.ScopeRead, nil, h.apiCategories},
+		{http.MethodGet, "/me", true, models.ScopeRead, nil, h.apiMe},
+		{http.MethodGet, "/users", true, models.ScopeRead, nil, h.apiUserSearch},
+		{http.MethodPost, "/preview", true, models.ScopeRead, h.previewLimit, h.apiPreview},
+		{http.MethodGet, "/drafts", true, models.ScopeRead, nil, h.apiDraftList},
+		{http.MethodPost, "/drafts", true, models.ScopeWrite, nil, h.apiDraftCreate},
+		{http.MethodGet, "/drafts/{id}", true, models.ScopeRead, nil, h.apiDraftGet},
+		{http.MethodPut, "/drafts/{id}", true, models.ScopeWrite, nil, h.apiDraftUpdate},
+	}
+}
+
+// legacyRoute is a JSON endpoint of the pages which came before the API. The
+// routes take a vote sent by a signed in user and are kept for older clients.
+type legacyRoute struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+func (h *Handler) legacyRoutes() []legacyRoute {
+	return []legacyRoute{
+		{"/post/like", h.postLike},
+		{"/post/dislike", h.postDislike},
+		{"/post/commentLike", h.commentLike},
+		{"/post/commentDislike", h.commentDislike},
+	}
+}
+
+// match reports whether the path matches the pattern and returns the value of
+// the {id} segment.
+func (route apiRoute) match(path string) (int, bool) {
+	want := strings.Split(route.pattern, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return 0, false
+	}
+
+	var id int
+	for i := range want {
+		if want[i] == apiPathParamID {
+			n, err := strconv.Atoi(got[i])
+			if err != nil || n < 1 {
+				return 0, false
+			}
+			id = n
+		} else if want[i] != got[i] {
+			return 0, false
+		}
+	}
+	return id, true
+}
+
+func (h *Handler) api(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, apiPrefix), "/")
+
+	var allowed []string
+	for _, route := range h.apiRoutes() {
+		id, ok := route.match(path)
+		if !ok {
+			continue
+		}
+		if route.method != r.Method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+
+		if route.auth {
+			if _, err := h.UUsecase.GetUserId(r); err != nil {
+				h.apiError(w, http.StatusUnauthorized, nil)
+				return
+			}
+		}
+		if token, ok := r.Context().Value(models.APITokenContextKey).(*models.APIToken); ok && !token.HasScope(route.scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="api", error="insufficient_scope", scope=%q`, route.scope))
+			h.apiError(w, http.StatusForbidden, nil)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+
+		handler := func(w http.ResponseWriter, r *http.Request) { route.handler(w, r, id) }
+		if route.limit != nil {
+			handler = h.RateLimit(route.limit, handler)
+		}
+		handler(w, r)
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		h.apiError(w, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	h.apiError(w, http.StatusNotFound, nil)
+}
+
+// readJSON() decodes the request body into dst, rejecting unknown fields and
+// bodies larger than apiMaxBody.
+func (h *Handler) readJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, apiMaxBody)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, apiErrorResponse{apiErrorDetail{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+		}})
+		return false
+	}
+	return true
+}
+
+// visiblePost() loads the post, answering 404 when it doesn't exist or is
+// held for moderation or scheduled and the user is neither its author nor a
+// moderator.
+func (h *Handler) visiblePost(w http.ResponseWriter, id int, user int) (*models.Post, bool) {
+	post, err := h.PUsecase.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.apiError(w, http.StatusNotFound, nil)
+		} else {
+			h.apiServerError(w, err)
+		}
+		return nil, false
+	}
+	if post.Hidden() && post.Author != strconv.Itoa(user) && !h.UUsecase.IsModerator(user) {
+		h.apiError(w, http.StatusNotFound, nil)
+		return nil, false
+	}
+	post.Author, _ = h.UUsecase.GetUserName(post.Author)
+	return post, true
+}
+
+type apiPostPage struct {
+	Posts   []*models.Post `json:"posts"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"perPage"`
+	Total   int            `json:"total"`
+}
+
+func (h *Handler) apiPostList(w http.ResponseWriter, r *http.Request, _ int) {
+	query := r.URL.Query()
+	v := validator.Validator{}
+
+	page, perPage := 1, apiPerPage
+	if s := query.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		v.CheckField(err == nil && n >= 1, "page", "This field must be a positive number")
+		page = n
+	}
+	if s := query.Get("per_page"); s != "" {
+		n, err := strconv.Atoi(s)
+		v.CheckField(err == nil && n >= 1 && n <= apiMaxPerPage, "per_page", fmt.Sprintf("This field must be a number from 1 to %d", apiMaxPerPage))
+		perPage = n
+	}
+
+	filter := models.PostFilter{Categories: query["category"]}
+	for _, category := range filter.Categories {
+		v.CheckField(models.IsCategory(category), "category", "Unknown category")
+	}
+
+	if !v.Valid() {
+		h.apiError(w, http.StatusBadRequest, v.FieldErrors)
+		return
+	}
+
+	filter.Limit, filter.Offset = perPage, (page-1)*perPage
+	if name := query.Get("author"); name != "" {
+		author, err := h.UUsecase.GetUserIdByName(name)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				h.writeJSON(w, http.StatusOK, apiPostPage{Posts: []*models.Post{}, Page: page, PerPage: perPage})
+			} else {
+				h.apiServerError(w, err)
+			}
+			return
+		}
+		filter.Author = author
+	}
+
+	posts, total, err := h.PUsecase.List(filter)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	for _, post := range posts {
+		post.Author, _ = h.UUsecase.GetUserName(post.Author)
+	}
+
+	h.writeJSON(w, http.StatusOK, apiPostPage{Posts: posts, Page: page, PerPage: perPage, Total: total})
+}
+
+func (h *Handler) apiPostCreate(w http.ResponseWriter, r *http.Request, _ int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	var form models.PostCreateForm
+	if !h.readJSON(w, r, &form) {
+		return
+	}
+
+	v := validator.Validator{}
+	v.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(form.Content, maxContentChars), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxContentChars))
+	v.CheckField(len(form.Categories) > 0, "categories", "This field cannot be empty")
+	for _, category := range form.Categories {
+		v.CheckField(models.IsCategory(category), "categories", "Unknown category")
+	}
+	v.CheckField(form.PublishAt == nil || form.PublishAt.After(time.Now()), "publishAt", "This time has already passed")
+	if form.Poll != nil {
+		checkPoll(&v, form.Poll, form.PublishAt)
+	}
+	if !v.Valid() {
+		h.apiError(w, http.StatusUnprocessableEntity, v.FieldErrors)
+		return
+	}
+
+	id, err := h.PUsecase.Insert(form, user)
+	if err != nil && id != 0 {
+		// The post is stored, only a later step failed.
+		h.errorLog.Print(err)
+	} else if err != nil {
+		if errors.Is(err, models.ErrRejectedContent) {
+			h.apiError(w, http.StatusUnprocessableEntity, map[string]string{"content": "This post was rejected by the content filter"})
+		} else {
+			h.apiServerError(w, err)
+		}
+		return
+	}
+
+	post, ok := h.visiblePost(w, id, user)
+	if !ok {
+		return
+	}
+	post.Poll, err = h.postPoll(id, user)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/posts/%d", apiPrefix, id))
+	h.writeJSON(w, http.StatusCreated, post)
+}
+
+func (h *Handler) apiPostGet(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	post, ok := h.visiblePost(w, id, user)
+	if !ok {
+		return
+	}
+	poll, err := h.postPoll(id, user)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	post.Poll = poll
+	h.writeJSON(w, http.StatusOK, post)
+}
+
+func (h *Handler) apiCommentList(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	if _, ok := h.visiblePost(w, id, user); !ok {
+		return
+	}
+
+	comments, err := h.PUsecase.GetComments(id, user)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	for _, comment := range comments {
+		comment.Author, _ = h.UUsecase.GetUserName(comment.Author)
+		comment.IsDisliked = h.PUsecase.IsCommentDislikedByUser(user, comment.Id)
+	}
+
+	h.writeJSON(w, http.StatusOK, comments)
+}
+
+type apiCommentInput struct {
+	Comment string `json:"comment"`
+}
+
+func (h *Handler) apiCommentCreate(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	if _, ok := h.visiblePost(w, id, user); !ok {
+		return
+	}
+
+	var input apiCommentInput
+	if !h.readJSON(w, r, &input) {
+		return
+	}
+
+	v := validator.Validator{}
+	v.CheckField(validator.NotBlank(input.Comment), "comment", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(input.Comment, 100), "comment", "This field cannot be more than 100 characters long")
+	if !v.Valid() {
+		h.apiError(w, http.StatusUnprocessableEntity, v.FieldErrors)
+		return
+	}
+
+	commentID, err := h.PUsecase.CommentInsert(input.Comment, user, id)
+	if err != nil && commentID != 0 {
+		// The comment is stored, only a later step failed.
+		h.errorLog.Print(err)
+	} else if err != nil {
+		if errors.Is(err, models.ErrRejectedContent) {
+			h.apiError(w, http.StatusUnprocessableEntity, map[string]string{"comment": "This comment was rejected by the content filter"})
+		} else {
+			h.apiServerError(w, err)
+		}
+		return
+	}
+
+	comment, err := h.PUsecase.GetComment(commentID)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	comment.Author, _ = h.UUsecase.GetUserName(comment.Author)
+	h.writeJSON(w, http.StatusCreated, comment)
+}
+
+func (h *Handler) apiPostLike(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	if _, ok := h.visiblePost(w, id, user); !ok {
+		return
+	}
+	var input models.UserLikeData
+	if !h.readJSON(w, r, &input) {
+		return
+	}
+
+	data, err := h.PUsecase.SetLike(id, user, input.IsLiked)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, data)
+}
+
+func (h *Handler) apiPostDislike(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	if _, ok := h.visiblePost(w, id, user); !ok {
+		return
+	}
+	var input models.UserDislikeData
+	if !h.readJSON(w, r, &input) {
+		return
+	}
+
+	data, err := h.PUsecase.SetDislike(id, user, input.IsDisliked)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, data)
+}
+
+// visibleComment() loads the comment, answering 404 when it or its post is not
+// visible to the user.
+func (h *Handler) visibleComment(w http.ResponseWriter, id int, user int) bool {
+	comment, err := h.PUsecase.GetComment(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.apiError(w, http.StatusNotFound, nil)
+		} else {
+			h.apiServerError(w, err)
+		}
+		return false
+	}
+	if comment.Pending && comment.Author != strconv.Itoa(user) {
+		h.apiError(w, http.StatusNotFound, nil)
+		return false
+	}
+	_, ok := h.visiblePost(w, comment.PostID, user)
+	return ok
+}
+
+func (h *Handler) apiCommentLike(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	if !h.visibleComment(w, id, user) {
+		return
+	}
+	var input models.CommentLikeData
+	if !h.readJSON(w, r, &input) {
+		return
+	}
+
+	data, err := h.PUsecase.SetCommentLike(id, user, input.IsLiked)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, data)
+}
+
+func (h *Handler) apiCommentDislike(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	if !h.visibleComment(w, id, user) {
+		return
+	}
+	var input models.CommentDislikeData
+	if !h.readJSON(w, r, &input) {
+		return
+	}
+
+	data, err := h.PUsecase.SetCommentDislike(id, user, input.IsDisliked)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, data)
+}
+
+func (h *Handler) apiCategories(w http.ResponseWriter, r *http.Request, _ int) {
+	h.writeJSON(w, http.StatusOK, models.Categories)
+}
+
+type apiUser struct {
+	*models.User
+	IsModerator bool `json:"isModerator"`
+	IsAdmin     bool `json:"isAdmin"`
+}
+
+func (h *Handler) apiMe(w http.ResponseWriter, r *http.Request, _ int) {
+	id, _ := h.UUsecase.GetUserId(r)
+
+	user, err := h.UUsecase.GetUser(id)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, apiUser{
+		User:        user,
+		IsModerator: h.UUsecase.IsModerator(id),
+		IsAdmin:     h.UUsecase.IsAdmin(id),
+	})
+}
+
+// apiUserSearch returns the usernames starting with the prefix query
+// parameter, for the autocompletion of mentions.
+func (h *Handler) apiUserSearch(w http.ResponseWriter, r *http.Request, _ int) {
+	prefix := r.URL.Query().Get("prefix")
+
+	v := validator.Validator{}
+	v.CheckField(validator.NotBlank(prefix), "prefix", "This field cannot be blank")
+	if !v.Valid() {
+		h.apiError(w, http.StatusBadRequest, v.FieldErrors)
+		return
+	}
+
+	names, err := h.UUsecase.SearchUsernames(prefix, apiMaxUsers)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, names)
+}
+
+type apiPreviewInput struct {
+	Content string `json:"content"`
+}
+
+type apiPreview struct {
+	HTML template.HTML `json:"html"`
+}
+
+// apiPreview renders the Markdown of a post or a comment being written, as it
+// will be shown once published.
+func (h *Handler) apiPreview(w http.ResponseWriter, r *http.Request, _ int) {
+	var input apiPreviewInput
+	if !h.readJSON(w, r, &input) {
+		return
+	}
+
+	v := validator.Validator{}
+	v.CheckField(validator.MaxChars(input.Content, maxContentChars), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxContentChars))
+	if !v.Valid() {
+		h.apiError(w, http.StatusUnprocessableEntity, v.FieldErrors)
+		return
+	}
+
+	html, err := h.PUsecase.Preview(input.Content)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, apiPreview{HTML: html})
+}
+
+func (h *Handler) apiDraftList(w http.ResponseWriter, r *http.Request, _ int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	drafts, err := h.PUsecase.Drafts(user)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, drafts)
+}
+
+func (h *Handler) apiDraftGet(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	draft, err := h.PUsecase.GetDraft(id, user)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.apiError(w, http.StatusNotFound, nil)
+		} else {
+			h.apiServerError(w, err)
+		}
+		return
+	}
+	h.writeJSON(w, http.StatusOK, draft)
+}
+
+func (h *Handler) apiDraftCreate(w http.ResponseWriter, r *http.Request, _ int) {
+	h.apiDraftSave(w, r, 0)
+}
+
+// apiDraftUpdate saves the post being written over the draft, the create form
+// autosaves this way.
+func (h *Handler) apiDraftUpdate(w http.ResponseWriter, r *http.Request, id int) {
+	h.apiDraftSave(w, r, id)
+}
+
+// apiDraftSave creates a draft when id is 0 and updates it otherwise.
+func (h *Handler) apiDraftSave(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	var form models.PostCreateForm
+	if !h.readJSON(w, r, &form) {
+		return
+	}
+
+	v := validator.Validator{}
+	checkDraft(&v, form)
+	if !v.Valid() {
+		h.apiError(w, http.StatusUnprocessableEntity, v.FieldErrors)
+		return
+	}
+
+	form.Draft = id
+	id, err := h.PUsecase.SaveDraft(form, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			h.apiError(w, http.StatusNotFound, nil)
+		case errors.Is(err, models.ErrTooManyDrafts):
+			h.writeJSON(w, http.StatusUnprocessableEntity, apiErrorResponse{apiErrorDetail{
+				Status:  http.StatusUnprocessableEntity,
+				Message: fmt.Sprintf("You can keep at most %d drafts", models.MaxDrafts),
+			}})
+		default:
+			h.apiServerError(w, err)
+		}
+		return
+	}
+
+	draft, err := h.PUsecase.GetDraft(id, user)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	if form.Draft == 0 {
+		w.Header().Set("Location", fmt.Sprintf("%s/drafts/%d", apiPrefix, id))
+		h.writeJSON(w, http.StatusCreated, draft)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, draft)
+}
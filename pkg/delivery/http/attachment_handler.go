@@ -0,0 +1,64 @@
+package delivery
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// postAttachment serves the attachment at /attachment/{id} and counts the
+// download. Images open in the browser, other files are saved under their
+// name. The attachments of held posts are only served to their author and the
+// moderators, like the posts.
+func (h *Handler) postAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/attachment/"))
+	if err != nil || id < 1 {
+		h.notFound(w)
+		return
+	}
+
+	a, err := h.PUsecase.GetAttachment(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	post, err := h.PUsecase.Get(a.PostID)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	user, _ := h.UUsecase.GetUserId(r)
+	if post.Hidden() && post.Author != strconv.Itoa(user) && !h.UUsecase.IsModerator(user) {
+		h.notFound(w)
+		return
+	}
+
+	f, err := h.PUsecase.OpenAttachment(a)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	disposition := "attachment"
+	if a.IsImage() {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	// Every download is counted, the browser has to ask again.
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, a.Name, f.Modified, f)
+}
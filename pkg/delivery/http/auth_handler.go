@@ -189,8 +189,13 @@ func (h *Handler) loggedinHandler(w http.ResponseWriter, r *http.Request, form m
 
 	err := h.UUsecase.Insert(form.Name, form.Email, form.Password)
 		if err != nil {
-			if errors.Is(err, models.ErrDuplicateEmail) || errors.Is(err, models.ErrDuplicateUsername) {
+			if errors.Is(err, models.ErrDuplicateEmail) || errors.Is(err, models.ErrDuplicateUsername) || errors.Is(err, models.ErrInviteRequired) {
 				userID, err := h.UUsecase.GetUserInfo(form.Email, form.Name)
+				// Пользователь не найден: в режиме регистрации по приглашениям новых пользователей не создаем
+				if err != nil {
+					h.clientError(w, http.StatusForbidden)
+					return
+				}
 				// Если пользователь уже существует, создаем сессию и устанавливаем куки
 				token := cookies.SetCookie(w, userID)
 				err = h.UUsecase.AddToken(userID, token)
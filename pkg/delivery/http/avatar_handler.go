@@ -0,0 +1,123 @@
+package delivery
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum.bbilisbe/internal/avatar"
+	"forum.bbilisbe/internal/models"
+)
+
+// maxAvatarSize is the size limit of an uploaded avatar in bytes.
+const maxAvatarSize = 5 * 1024 * 1024
+
+// avatarImage serves the avatar of the user at /avatar/{username}, in the size
+// given by the size parameter, 32 or 128 pixels.
+func (h *Handler) avatarImage(w http.ResponseWriter, r *http.Request) {
+	size := avatar.Small
+	if s := r.URL.Query().Get("size"); s != "" {
+		var err error
+		size, err = strconv.Atoi(s)
+		if err != nil || (size != avatar.Small && size != avatar.Large) {
+			h.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	b, modified, err := h.UUsecase.GetAvatar(strings.TrimPrefix(r.URL.Path, "/avatar/"), size)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	// The address of an avatar doesn't change with it, so it is only cached
+	// for a while.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "avatar.png", modified, bytes.NewReader(b))
+}
+
+// userAvatar replaces the avatar of the user with the uploaded picture, cut to
+// the square given by crop_x, crop_y and crop_size in pixels of the picture.
+func (h *Handler) userAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	data, err := h.profileSettingsData(r, id)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarSize+1024*1024)
+	err = r.ParseMultipartForm(maxAvatarSize)
+	if err != nil {
+		data.AddFieldError("avatar", "Choose an image less than 5MB")
+		h.render(w, http.StatusUnprocessableEntity, "profilesettings.html", data)
+		return
+	}
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		data.AddFieldError("avatar", "Choose an image")
+		h.render(w, http.StatusUnprocessableEntity, "profilesettings.html", data)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxAvatarSize {
+		data.AddFieldError("avatar", "Choose an image less than 5MB")
+		h.render(w, http.StatusUnprocessableEntity, "profilesettings.html", data)
+		return
+	}
+
+	var crop image.Rectangle
+	x, errX := strconv.Atoi(r.PostForm.Get("crop_x"))
+	y, errY := strconv.Atoi(r.PostForm.Get("crop_y"))
+	side, errSide := strconv.Atoi(r.PostForm.Get("crop_size"))
+	if errX == nil && errY == nil && errSide == nil {
+		crop = image.Rect(x, y, x+side, y+side)
+	}
+
+	err = h.UUsecase.SetAvatar(id, file, crop)
+	switch {
+	case errors.Is(err, avatar.ErrInvalid):
+		data.AddFieldError("avatar", "The file must be a PNG, JPEG or GIF image")
+	case errors.Is(err, avatar.ErrTooLarge):
+		data.AddFieldError("avatar", "The image must be at most 4096 pixels wide and high")
+	case errors.Is(err, avatar.ErrTooSmall):
+		data.AddFieldError("avatar", "The image must be at least 32 pixels wide and high")
+	case err != nil:
+		h.serverError(w, err)
+		return
+	default:
+		http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+		return
+	}
+	h.render(w, http.StatusUnprocessableEntity, "profilesettings.html", data)
+}
+
+// userAvatarRemove goes back to the generated avatar.
+func (h *Handler) userAvatarRemove(w http.ResponseWriter, r *http.Request) {
+	id, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	err = h.UUsecase.RemoveAvatar(id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		h.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"net/http"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/models"
+)
+
+// userDigest shows and changes the email digest subscription of the user with
+// the list of the latest digests.
+func (h *Handler) userDigest(w http.ResponseWriter, r *http.Request) {
+	id, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	settings, err := h.DUsecase.Settings(id)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		err = r.ParseForm()
+		if err != nil {
+			h.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		settings = &models.DigestSettings{UserID: id, Frequency: r.PostForm.Get("frequency")}
+		for _, category := range models.Categories {
+			if config.Has(r.PostForm["categories"], category.Slug) {
+				settings.Categories = append(settings.Categories, category.Slug)
+			}
+		}
+
+		user, err := h.UUsecase.GetUser(id)
+		if err != nil {
+			h.serverError(w, err)
+			return
+		}
+
+		data.CheckField(settings.Frequency == models.DigestOff || settings.Frequency == models.DigestDaily || settings.Frequency == models.DigestWeekly,
+			"frequency", "Choose how often to get the digest")
+		data.CheckField(settings.Frequency == models.DigestOff || user.Email != "",
+			"frequency", "Your account has no email address")
+
+		if data.Valid() {
+			err = h.DUsecase.SetSettings(id, *settings)
+			if err != nil {
+				h.serverError(w, err)
+				return
+			}
+			http.Redirect(w, r, "/user/digest", http.StatusSeeOther)
+			return
+		}
+	}
+
+	history, err := h.DUsecase.History(id)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	data.Digests = history
+	data.Form = settings
+
+	status := http.StatusOK
+	if !data.Valid() {
+		status = http.StatusUnprocessableEntity
+	}
+	h.render(w, status, "digest.html", data)
+}
@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/validator"
+)
+
+// checkDraft() validates a post saved as a draft. Unlike a post it may lack
+// the content or the categories, but not both the title and the content.
+func checkDraft(v *validator.Validator, form models.PostCreateForm) {
+	v.CheckField(validator.NotBlank(form.Title) || validator.NotBlank(form.Content), "title", "A draft needs a title or some content")
+	v.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.MaxChars(form.Content, maxContentChars), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxContentChars))
+	for _, category := range form.Categories {
+		v.CheckField(models.IsCategory(category), "categories", "Unknown category")
+	}
+}
+
+// postDraftSave saves the create form as a draft and shows it again. The
+// attachments are only added when the post is published.
+func (h *Handler) postDraftSave(w http.ResponseWriter, r *http.Request, data *models.TemplateData, form models.PostCreateForm) {
+	checkDraft(&data.Validator, form)
+	if !data.Valid() {
+		data.Form = form
+		h.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		return
+	}
+
+	user, _ := h.UUsecase.GetUserId(r)
+	id, err := h.PUsecase.SaveDraft(form, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			h.notFound(w)
+		case errors.Is(err, models.ErrTooManyDrafts):
+			data.Form = form
+			data.AddNonFieldError("draft", fmt.Sprintf("You can keep at most %d drafts, publish or delete some first", models.MaxDrafts))
+			h.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		default:
+			h.serverError(w, err)
+		}
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/post/create?draft=%d", id), http.StatusSeeOther)
+}
+
+// userDrafts lists the drafts of the user, the last saved first, and the posts
+// scheduled to be published.
+func (h *Handler) userDrafts(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	drafts, err := h.PUsecase.Drafts(user)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	scheduled, err := h.PUsecase.Scheduled(user)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	data.Drafts = drafts
+	data.Scheduled = scheduled
+	h.render(w, http.StatusOK, "drafts.html", data)
+}
+
+func (h *Handler) draftDelete(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.DeleteDraft(id, user)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	http.Redirect(w, r, "/user/drafts", http.StatusSeeOther)
+}
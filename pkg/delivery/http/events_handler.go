@@ -0,0 +1,89 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum.bbilisbe/internal/live"
+	"forum.bbilisbe/internal/models"
+)
+
+// eventsKeepAlive is how often a comment is sent on idle event streams so
+// proxies don't close them.
+const eventsKeepAlive = 25 * time.Second
+
+// postEvents streams the new comments and the votes of a post.
+func (h *Handler) postEvents(w http.ResponseWriter, r *http.Request) {
+	id, err := h.PUsecase.GetPostId(r)
+	if err != nil || id < 1 {
+		h.notFound(w)
+		return
+	}
+
+	post, err := h.PUsecase.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	user, _ := h.UUsecase.GetUserId(r)
+	if post.Hidden() && post.Author != strconv.Itoa(user) && !h.UUsecase.IsModerator(user) {
+		h.notFound(w)
+		return
+	}
+
+	h.streamEvents(w, r, live.PostTopic(id))
+}
+
+// feedEvents streams the new posts and the votes shown on the home page.
+func (h *Handler) feedEvents(w http.ResponseWriter, r *http.Request) {
+	h.streamEvents(w, r, live.FeedTopic)
+}
+
+// streamEvents() sends the events of the topic as Server-Sent Events until
+// the client goes away.
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request, topic string) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the write timeout of the server.
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	events, cancel := h.live.Subscribe(topic)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	rc.Flush()
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package delivery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"forum.bbilisbe/internal/feed"
+	"forum.bbilisbe/internal/models"
+)
+
+// feedSize is the number of entries in a feed.
+const feedSize = 50
+
+// baseURL() returns the public address of the forum without a trailing slash.
+func (h *Handler) baseURL(r *http.Request) string {
+	if h.cfg.BaseURL != "" {
+		return h.cfg.BaseURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// feedFormat() returns the format of a feed path ending in feed.atom,
+// feed.rss or feed.json.
+func feedFormat(name string) (string, bool) {
+	format, ok := strings.CutPrefix(name, "feed.")
+	if !ok || (format != "atom" && format != "rss" && format != "json") {
+		return "", false
+	}
+	return format, true
+}
+
+// feedLatest serves /feed.atom, /feed.rss and /feed.json.
+func (h *Handler) feedLatest(w http.ResponseWriter, r *http.Request) {
+	format, ok := feedFormat(strings.TrimPrefix(r.URL.Path, "/"))
+	if !ok {
+		h.notFound(w)
+		return
+	}
+	base := h.baseURL(r)
+
+	h.postsFeed(w, r, format, models.PostFilter{}, &feed.Feed{
+		Title:       "K-Pop Forum",
+		Description: "The latest posts",
+		Link:        base + "/",
+		Self:        base + r.URL.Path,
+	})
+}
+
+// feedCategory serves /c/{slug}/feed.{atom,rss,json}.
+func (h *Handler) feedCategory(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/c/"), "/")
+	if len(parts) != 2 {
+		h.notFound(w)
+		return
+	}
+	format, ok := feedFormat(parts[1])
+	if !ok || !models.IsCategory(parts[0]) {
+		h.notFound(w)
+		return
+	}
+	base := h.baseURL(r)
+
+	h.postsFeed(w, r, format, models.PostFilter{Categories: []string{parts[0]}}, &feed.Feed{
+		Title:       "K-Pop Forum: " + categoryName(parts[0]),
+		Description: "The latest posts in " + categoryName(parts[0]),
+		Link:        base + "/",
+		Self:        base + r.URL.Path,
+	})
+}
+
+// feedUser serves /u/{username}/feed.{atom,rss,json}.
+func (h *Handler) feedUser(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/u/"), "/")
+	if len(parts) != 2 {
+		h.notFound(w)
+		return
+	}
+	format, ok := feedFormat(parts[1])
+	if !ok {
+		h.notFound(w)
+		return
+	}
+
+	author, err := h.UUsecase.GetUserIdByName(parts[0])
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	base := h.baseURL(r)
+
+	h.postsFeed(w, r, format, models.PostFilter{Author: author}, &feed.Feed{
+		Title:       "K-Pop Forum: posts by " + parts[0],
+		Description: "The latest posts by " + parts[0],
+		Link:        base + "/",
+		Self:        base + r.URL.Path,
+	})
+}
+
+func (h *Handler) postsFeed(w http.ResponseWriter, r *http.Request, format string, filter models.PostFilter, f *feed.Feed) {
+	filter.Limit = feedSize
+	posts, _, err := h.PUsecase.List(filter)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	if err = h.PUsecase.Render(posts); err != nil {
+		h.serverError(w, err)
+		return
+	}
+	base := h.baseURL(r)
+
+	for _, post := range posts {
+		author, _ := h.UUsecase.GetUserName(post.Author)
+		link := fmt.Sprintf("%s/post/view/%d", base, post.ID)
+
+		item := feed.Item{
+			ID:        link,
+			Title:     post.Title,
+			Link:      link,
+			Author:    author,
+			Text:      post.Content,
+			HTML:      absoluteLinks(string(post.HTML), base),
+			Published: post.Created,
+		}
+		for _, slug := range strings.Fields(post.Tags) {
+			item.Categories = append(item.Categories, categoryName(slug))
+		}
+		f.Items = append(f.Items, item)
+
+		if post.Created.After(f.Updated) {
+			f.Updated = post.Created
+		}
+	}
+	h.serveFeed(w, r, format, f)
+}
+
+// feedComments serves /p/{id}/feed.{atom,rss,json}, the comments of a post.
+func (h *Handler) feedComments(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/p/"), "/")
+	if len(parts) != 2 {
+		h.notFound(w)
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	format, ok := feedFormat(parts[1])
+	if err != nil || id < 1 || !ok {
+		h.notFound(w)
+		return
+	}
+
+	post, err := h.PUsecase.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	if post.Hidden() {
+		h.notFound(w)
+		return
+	}
+
+	// Held comments are only returned to their author, there is none here.
+	comments, err := h.PUsecase.GetComments(id, 0)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	base := h.baseURL(r)
+	link := fmt.Sprintf("%s/post/view/%d", base, post.ID)
+
+	f := &feed.Feed{
+		Title:       "Comments on " + post.Title,
+		Description: "The comments on " + post.Title,
+		Link:        link,
+		Self:        base + r.URL.Path,
+		Updated:     post.Created,
+	}
+	// The newest comments come first like in the other feeds.
+	for i := len(comments) - 1; i >= 0 && len(f.Items) < feedSize; i-- {
+		comment := comments[i]
+		author, _ := h.UUsecase.GetUserName(comment.Author)
+
+		// Comments written before their time was recorded get the time of
+		// the post.
+		created := post.Created
+		if comment.Created != nil {
+			created = *comment.Created
+		}
+
+		f.Items = append(f.Items, feed.Item{
+			ID:        fmt.Sprintf("%s#comment-%d", link, comment.Id),
+			Title:     "Comment by " + author,
+			Link:      link,
+			Author:    author,
+			Text:      comment.Comment,
+			HTML:      absoluteLinks(string(comment.HTML), base),
+			Published: created,
+		})
+		if created.After(f.Updated) {
+			f.Updated = created
+		}
+	}
+	h.serveFeed(w, r, format, f)
+}
+
+// localLink matches the links of rendered Markdown to the forum itself.
+var localLink = regexp.MustCompile(`(href|src)="/([^/])`)
+
+// absoluteLinks() makes the links of the rendered HTML to the forum absolute,
+// feed readers don't resolve them against the feed.
+func absoluteLinks(html string, base string) string {
+	return localLink.ReplaceAllString(html, `$1="`+strings.ReplaceAll(base, "$", "$$")+`/$2`)
+}
+
+// serveFeed() renders the feed and answers conditional requests with 304 Not
+// Modified using the update time of the feed and a hash of its content.
+func (h *Handler) serveFeed(w http.ResponseWriter, r *http.Request, format string, f *feed.Feed) {
+	var body []byte
+	var err error
+
+	switch format {
+	case "atom":
+		body, err = f.Atom()
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	case "rss":
+		body, err = f.RSS()
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	default:
+		body, err = f.JSON()
+		w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	}
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:16]))
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", f.Updated, bytes.NewReader(body))
+}
+
+func categoryName(slug string) string {
+	for _, category := range models.Categories {
+		if category.Slug == slug {
+			return category.Name
+		}
+	}
+	return slug
+}
@@ -6,35 +6,85 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"time"
 
+	"forum.bbilisbe/internal/captcha"
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/jobs"
+	"forum.bbilisbe/internal/live"
 	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/ratelimit"
 )
 
+// captchaTTL is the time a user has to solve a CAPTCHA.
+const captchaTTL = 10 * time.Minute
+
 type Handler struct {
 	PUsecase      models.PostUsecases
 	UUsecase      models.UserUsecases
+	FUsecase      models.FilterUsecases
+	WUsecase      models.WebhookUsecases
+	NUsecase      models.NotificationUsecases
+	DUsecase      models.DigestUsecases
+	media         models.MediaStore
+	jobs          *jobs.Runner
+	live          *live.Hub
+	cfg           *config.Config
+	captcha       *captcha.Captcha
+	postLimit     *ratelimit.Limiter
+	commentLimit  *ratelimit.Limiter
+	voteLimit     *ratelimit.Limiter
+	previewLimit  *ratelimit.Limiter
 	templateCache map[string]*template.Template
+	openAPISpec   []byte
 	infoLog       *log.Logger
 	errorLog      *log.Logger
 }
 
-func NewPostHandler(pu models.PostUsecases, uu models.UserUsecases, infoLog, errorLog *log.Logger) http.Handler {
+func NewPostHandler(pu models.PostUsecases, uu models.UserUsecases, fu models.FilterUsecases, wu models.WebhookUsecases, nu models.NotificationUsecases, du models.DigestUsecases, store models.MediaStore, runner *jobs.Runner, hub *live.Hub, cfg *config.Config, infoLog, errorLog *log.Logger) http.Handler {
 	templateCache, _ := newTemplateCache()
 
+	captcha, err := captcha.New(captchaTTL)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	handler := &Handler{
 		PUsecase:      pu,
 		UUsecase:      uu,
+		FUsecase:      fu,
+		WUsecase:      wu,
+		NUsecase:      nu,
+		DUsecase:      du,
+		media:         store,
+		jobs:          runner,
+		live:          hub,
+		cfg:           cfg,
+		captcha:       captcha,
+		postLimit:     ratelimit.New(ratelimit.Policy{Limit: cfg.PostLimit, Per: time.Hour}),
+		commentLimit:  ratelimit.New(ratelimit.Policy{Limit: cfg.CommentLimit, Per: time.Hour}),
+		voteLimit:     ratelimit.New(ratelimit.Policy{Limit: cfg.VoteLimit, Per: time.Hour}),
+		previewLimit:  ratelimit.New(ratelimit.Policy{Limit: cfg.PreviewLimit, Per: time.Hour}),
 		templateCache: templateCache,
 		infoLog:       infoLog,
 		errorLog:      errorLog,
 	}
+	handler.openAPISpec, err = handler.openAPI()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	mux := http.NewServeMux()
 
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	mux.Handle("/static/", http.StripPrefix("/static", fileServer))
+	mux.HandleFunc("/media/", handler.RestrictGet(handler.mediaFile))
 
 	mux.HandleFunc("/", handler.RestrictGetPost(handler.home))
-	mux.HandleFunc("/post/view/", handler.RestrictGetPost(handler.postView))
+	mux.HandleFunc("/post/view/", handler.RestrictGetPost(handler.RateLimit(handler.commentLimit, handler.postView)))
+	mux.HandleFunc("/post/events/", handler.RestrictGet(handler.postEvents))
+	mux.HandleFunc("/events", handler.RestrictGet(handler.feedEvents))
+	mux.HandleFunc("/attachment/", handler.RestrictGet(handler.postAttachment))
 	mux.HandleFunc("/post/create", handler.RestrictGetPost(handler.RequireLog(handler.postCreate)))
 	mux.HandleFunc("/user/signup", handler.RestrictGetPost(handler.userSignup))
 	mux.HandleFunc("/user/login", handler.RestrictGetPost(handler.userLogin))
@@ -45,10 +95,50 @@ func NewPostHandler(pu models.PostUsecases, uu models.UserUsecases, infoLog, err
 	mux.HandleFunc("/user/logout", handler.RestrictPost(handler.userLogout))
 	mux.HandleFunc("/user/posts", handler.RestrictGet(handler.userPosts))
 	mux.HandleFunc("/user/likedposts", handler.RestrictGet(handler.userLikedPosts))
-	mux.HandleFunc("/post/like", handler.RequireLog(handler.RestrictPost(handler.postLike)))
-	mux.HandleFunc("/post/dislike", handler.RequireLog(handler.RestrictPost(handler.postDislike)))
-	mux.HandleFunc("/post/commentLike", handler.RequireLog(handler.RestrictPost(handler.commentLike)))
-	mux.HandleFunc("/post/commentDislike", handler.RequireLog(handler.RestrictPost(handler.commentDislike)))
+	mux.HandleFunc("/post/schedule", handler.RequireLog(handler.RestrictPost(handler.postSchedule)))
+	mux.HandleFunc("/post/publish", handler.RequireLog(handler.RestrictPost(handler.postPublishNow)))
+	mux.HandleFunc("/user/drafts", handler.RequireLog(handler.RestrictGet(handler.userDrafts)))
+	mux.HandleFunc("/user/drafts/delete", handler.RequireLog(handler.RestrictPost(handler.draftDelete)))
+	for _, route := range handler.legacyRoutes() {
+		mux.HandleFunc(route.pattern, handler.RequireLog(handler.RestrictPost(handler.RateLimit(handler.voteLimit, route.handler))))
+	}
+	mux.HandleFunc("/moderation/queue", handler.RequireLog(handler.RequireModerator(handler.RestrictGet(handler.moderationQueue))))
+	mux.HandleFunc("/moderation/approve", handler.RequireLog(handler.RequireModerator(handler.RestrictPost(handler.moderationApprove))))
+	mux.HandleFunc("/moderation/reject", handler.RequireLog(handler.RequireModerator(handler.RestrictPost(handler.moderationReject))))
+	mux.HandleFunc("/moderation/comment/approve", handler.RequireLog(handler.RequireModerator(handler.RestrictPost(handler.moderationCommentApprove))))
+	mux.HandleFunc("/moderation/comment/reject", handler.RequireLog(handler.RequireModerator(handler.RestrictPost(handler.moderationCommentReject))))
+	mux.HandleFunc("/post/report", handler.RequireLog(handler.RestrictPost(handler.RateLimit(handler.commentLimit, handler.postReport))))
+	mux.HandleFunc("/moderation/dismiss", handler.RequireLog(handler.RequireModerator(handler.RestrictPost(handler.moderationDismiss))))
+	mux.HandleFunc("/moderation/remove", handler.RequireLog(handler.RequireModerator(handler.RestrictPost(handler.moderationRemove))))
+	mux.HandleFunc("/admin/filters", handler.RequireLog(handler.RequireAdmin(handler.RestrictGetPost(handler.adminFilters))))
+	mux.HandleFunc("/admin/filters/delete", handler.RequireLog(handler.RequireAdmin(handler.RestrictPost(handler.adminFilterDelete))))
+	mux.HandleFunc("/admin/webhooks", handler.RequireLog(handler.RequireAdmin(handler.RestrictGetPost(handler.adminWebhooks))))
+	mux.HandleFunc("/admin/webhooks/delete", handler.RequireLog(handler.RequireAdmin(handler.RestrictPost(handler.adminWebhookDelete))))
+	mux.HandleFunc("/admin/webhooks/deliveries", handler.RequireLog(handler.RequireAdmin(handler.RestrictGet(handler.adminWebhookDeliveries))))
+	mux.HandleFunc("/admin/jobs", handler.RequireLog(handler.RequireAdmin(handler.RestrictGet(handler.adminJobs))))
+	mux.HandleFunc("/admin/jobs/retry", handler.RequireLog(handler.RequireAdmin(handler.RestrictPost(handler.adminJobRetry))))
+	mux.HandleFunc("/user/invites", handler.RequireLog(handler.RestrictGetPost(handler.userInvites)))
+	mux.HandleFunc("/user/invites/revoke", handler.RequireLog(handler.RestrictPost(handler.inviteRevoke)))
+	mux.HandleFunc("/user/tokens", handler.RequireLog(handler.RestrictGetPost(handler.userTokens)))
+	mux.HandleFunc("/user/tokens/revoke", handler.RequireLog(handler.RestrictPost(handler.tokenRevoke)))
+	mux.HandleFunc("/user/profile", handler.RequireLog(handler.RestrictGetPost(handler.userProfileSettings)))
+	mux.HandleFunc("/user/avatar", handler.RequireLog(handler.RestrictPost(handler.userAvatar)))
+	mux.HandleFunc("/user/avatar/remove", handler.RequireLog(handler.RestrictPost(handler.userAvatarRemove)))
+	mux.HandleFunc("/avatar/", handler.RestrictGet(handler.avatarImage))
+	mux.HandleFunc("/user/digest", handler.RequireLog(handler.RestrictGetPost(handler.userDigest)))
+	mux.HandleFunc("/notifications", handler.RequireLog(handler.RestrictGet(handler.notifications)))
+	mux.HandleFunc("/notifications/read", handler.RequireLog(handler.RestrictPost(handler.notificationRead)))
+	mux.HandleFunc("/notifications/read-all", handler.RequireLog(handler.RestrictPost(handler.notificationReadAll)))
+	mux.HandleFunc("/notifications/settings", handler.RequireLog(handler.RestrictPost(handler.notificationSettings)))
+	mux.HandleFunc("/admin/invites", handler.RequireLog(handler.RequireAdmin(handler.RestrictGet(handler.adminInvites))))
+	mux.HandleFunc("/feed.atom", handler.RestrictGet(handler.feedLatest))
+	mux.HandleFunc("/feed.rss", handler.RestrictGet(handler.feedLatest))
+	mux.HandleFunc("/feed.json", handler.RestrictGet(handler.feedLatest))
+	mux.HandleFunc("/c/", handler.RestrictGet(handler.feedCategory))
+	mux.HandleFunc("/u/", handler.RestrictGet(handler.userPage))
+	mux.HandleFunc("/p/", handler.RestrictGet(handler.feedComments))
+	mux.HandleFunc("/api/v1/", handler.api)
+	mux.HandleFunc("/api/openapi.json", handler.RestrictGet(handler.apiSpec))
 
 	return handler.RecoverPanic(handler.AuthMiddleware(handler.LogRequest(handler.SecureHeaders(mux))))
 }
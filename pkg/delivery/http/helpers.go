@@ -3,14 +3,18 @@ package delivery
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
 	"path/filepath"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"time"
 
+	"forum.bbilisbe/internal/captcha"
+	"forum.bbilisbe/internal/config"
 	"forum.bbilisbe/internal/models"
 )
 
@@ -32,8 +36,31 @@ func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// fileSize() formats a size in bytes for people, e.g. "1.5 MB".
+func fileSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	}
+}
+
+// datetimeLocal() formats the time in UTC as the value of a datetime-local
+// input, empty for nil. schedule.js shows it in the user's time zone.
+func datetimeLocal(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04")
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"fileSize":      fileSize,
+	"datetimeLocal": datetimeLocal,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -68,10 +95,95 @@ func newTemplateCache() (map[string]*template.Template, error) {
 }
 
 func (h *Handler) newTemplateData(r *http.Request) *models.TemplateData {
-	return &models.TemplateData{
+	data := &models.TemplateData{
 		CurrentYear: time.Now().Year(),
 		Logged:      h.UUsecase.IsLogged(r),
+		InviteOnly:  h.cfg.InviteOnly,
+	}
+	if user, err := h.UUsecase.GetUserId(r); err == nil {
+		data.IsModerator = h.UUsecase.IsModerator(user)
+		data.IsAdmin = h.UUsecase.IsAdmin(user)
+		data.CanInvite = h.cfg.InviteOnly && h.UUsecase.CanInvite(user)
+		data.Unread, _ = h.NUsecase.Unread(user)
+	}
+	return data
+}
+
+// setCaptcha() adds a new challenge to the template data when the form
+// requires a CAPTCHA.
+func (h *Handler) setCaptcha(data *models.TemplateData, form string) {
+	data.Captcha = h.newCaptcha(form)
+}
+
+// newCaptcha() returns a new challenge for the form, or nil when the form
+// doesn't require a CAPTCHA.
+func (h *Handler) newCaptcha(form string) *captcha.Challenge {
+	if !config.Has(h.cfg.CaptchaForms, form) {
+		return nil
+	}
+	challenge, err := h.captcha.New()
+	if err != nil {
+		h.errorLog.Print(err)
+		return nil
+	}
+	return challenge
+}
+
+// checkCaptcha() verifies the answer sent with the form when the form
+// requires a CAPTCHA.
+func (h *Handler) checkCaptcha(r *http.Request, data *models.TemplateData, form string) {
+	data.CheckField(h.captchaSolved(r, form), "captcha", "The answer is not correct")
+}
+
+// captchaSolved() reports whether the form doesn't require a CAPTCHA or the
+// request carries the right answer.
+func (h *Handler) captchaSolved(r *http.Request, form string) bool {
+	if !config.Has(h.cfg.CaptchaForms, form) {
+		return true
 	}
+	return h.captcha.Verify(r.PostForm.Get("captcha_token"), r.PostForm.Get("captcha"))
+}
+
+// apiErrorResponse is the envelope of every error returned by the JSON API.
+type apiErrorResponse struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+func isAPI(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, v any) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		h.apiServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// apiError() writes the error envelope. Fields holds the validation errors of
+// the request body, if any.
+func (h *Handler) apiError(w http.ResponseWriter, status int, fields map[string]string) {
+	h.writeJSON(w, status, apiErrorResponse{apiErrorDetail{
+		Status:  status,
+		Message: http.StatusText(status),
+		Fields:  fields,
+	}})
+}
+
+func (h *Handler) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	h.errorLog.Output(2, trace)
+	h.apiError(w, http.StatusInternalServerError, nil)
 }
 
 func Errors(w http.ResponseWriter, status int, message string) {
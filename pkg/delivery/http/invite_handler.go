@@ -0,0 +1,123 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+func (h *Handler) userInvites(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	form := models.InviteCreateForm{MaxUses: 1, Days: 7}
+
+	if r.Method == http.MethodPost {
+		if !data.CanInvite {
+			h.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		maxUses, errUses := strconv.Atoi(r.FormValue("uses"))
+		days, errDays := strconv.Atoi(r.FormValue("days"))
+		form = models.InviteCreateForm{MaxUses: maxUses, Days: days}
+
+		data.CheckField(errUses == nil && maxUses >= 1 && maxUses <= 100, "uses", "This field must be a number from 1 to 100")
+		data.CheckField(errDays == nil && days >= 1 && days <= 90, "days", "This field must be a number from 1 to 90")
+
+		if data.Valid() {
+			_, err = h.UUsecase.CreateInvite(user, form.MaxUses, time.Duration(form.Days)*24*time.Hour)
+			if err != nil {
+				h.serverError(w, err)
+				return
+			}
+			http.Redirect(w, r, "/user/invites", http.StatusSeeOther)
+			return
+		}
+	}
+
+	invites, err := h.UUsecase.GetUserInvites(user)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	data.Invites = invites
+	data.Form = form
+
+	status := http.StatusOK
+	if !data.Valid() {
+		status = http.StatusUnprocessableEntity
+	}
+	h.render(w, status, "invites.html", data)
+}
+
+// inviteRevoke revokes an invite. Users can revoke their own codes, admins can
+// revoke any code.
+func (h *Handler) inviteRevoke(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	invite, err := h.UUsecase.GetInvite(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	isAdmin := h.UUsecase.IsAdmin(user)
+	if invite.CreatedBy != user && !isAdmin {
+		h.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = h.UUsecase.RevokeInvite(id)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	if isAdmin && invite.CreatedBy != user {
+		http.Redirect(w, r, "/admin/invites", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/user/invites", http.StatusSeeOther)
+}
+
+func (h *Handler) adminInvites(w http.ResponseWriter, r *http.Request) {
+	invites, err := h.UUsecase.GetInvites()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	tree, err := h.UUsecase.GetInviteTree()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	data.Invites = invites
+	data.InviteTree = tree
+
+	h.render(w, http.StatusOK, "admininvites.html", data)
+}
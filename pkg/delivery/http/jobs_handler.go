@@ -0,0 +1,63 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/models"
+)
+
+// jobListSize is the number of jobs shown on the jobs page.
+const jobListSize = 100
+
+// adminJobs lists the latest background jobs, optionally of one status, with
+// the number of jobs of each status and the recurring jobs.
+func (h *Handler) adminJobs(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !config.Has(models.JobStatuses, status) {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	jobs, err := h.jobs.Jobs(status, jobListSize)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	counts, err := h.jobs.Counts()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	data.Jobs = jobs
+	data.JobCounts = counts
+	data.JobStatus = status
+	data.JobSchedules = h.jobs.Schedules()
+
+	h.render(w, http.StatusOK, "jobs.html", data)
+}
+
+// adminJobRetry queues a failed job again.
+func (h *Handler) adminJobRetry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.jobs.RunAgain(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/jobs?status="+models.JobPending, http.StatusSeeOther)
+}
@@ -0,0 +1,38 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"forum.bbilisbe/internal/media"
+	"forum.bbilisbe/internal/models"
+)
+
+// mediaFile serves the uploaded file at /media/{key} from the media store.
+// Stored files get new keys instead of being changed, so browsers and proxies
+// may keep them for a year.
+func (h *Handler) mediaFile(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/media/")
+
+	f, err := h.media.Get(key)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) || errors.Is(err, media.ErrInvalidKey) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if f.ContentType != "" {
+		w.Header().Set("Content-Type", f.ContentType)
+	}
+	// Only pictures are shown in the browser, other uploads are downloaded.
+	if !strings.HasPrefix(f.ContentType, "image/") {
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, key, f.Modified, f)
+}
@@ -1,16 +1,23 @@
 package delivery
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"forum.bbilisbe/internal/cookies"
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/ratelimit"
 )
 
 func (h *Handler) SecureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Security-Policy",
-			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; img-src 'self' blob:")
 
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -23,6 +30,29 @@ func (h *Handler) SecureHeaders(next http.Handler) http.Handler {
 
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// API clients may authenticate with a personal token instead of the
+		// session cookie.
+		if header := r.Header.Get("Authorization"); header != "" && isAPI(r) {
+			scheme, value, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				h.apiError(w, http.StatusUnauthorized, nil)
+				return
+			}
+			token, err := h.UUsecase.AuthenticateAPIToken(strings.TrimSpace(value))
+			if err != nil {
+				if errors.Is(err, models.ErrInvalidToken) {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+					h.apiError(w, http.StatusUnauthorized, nil)
+				} else {
+					h.apiServerError(w, err)
+				}
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), models.APITokenContextKey, token)))
+			return
+		}
+
 		token, err := cookies.GetCookie(r)
 		if err != nil {
 			next.ServeHTTP(w, r)
@@ -110,3 +140,60 @@ func (h *Handler) RequireLog(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	}
 }
+
+func (h *Handler) RequireModerator(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := h.UUsecase.GetUserId(r)
+		if err != nil || !h.UUsecase.IsModerator(user) {
+			h.clientError(w, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (h *Handler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := h.UUsecase.GetUserId(r)
+		if err != nil || !h.UUsecase.IsAdmin(user) {
+			h.clientError(w, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RateLimit limits the POST requests of every user, or of every IP address
+// for anonymous clients. Moderators are not limited.
+func (h *Handler) RateLimit(limiter *ratelimit.Limiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := "ip:" + r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			key = "ip:" + host
+		}
+		if user, err := h.UUsecase.GetUserId(r); err == nil {
+			if h.UUsecase.IsModerator(user) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key = "user:" + strconv.Itoa(user)
+		}
+
+		ok, wait := limiter.Allow(key)
+		if !ok {
+			w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+			if isAPI(r) {
+				h.apiError(w, http.StatusTooManyRequests, nil)
+			} else {
+				h.clientError(w, http.StatusTooManyRequests)
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
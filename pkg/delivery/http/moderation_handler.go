@@ -0,0 +1,209 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/validator"
+)
+
+func (h *Handler) moderationQueue(w http.ResponseWriter, r *http.Request) {
+	posts, err := h.PUsecase.Pending()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	for _, post := range posts {
+		post.Author, _ = h.UUsecase.GetUserName(post.Author)
+	}
+
+	comments, err := h.PUsecase.PendingComments()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	for _, comment := range comments {
+		comment.Author, _ = h.UUsecase.GetUserName(comment.Author)
+	}
+
+	reports, err := h.PUsecase.Reports()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	data.Posts = posts
+	data.Comments = comments
+	data.Reports = reports
+
+	h.render(w, http.StatusOK, "queue.html", data)
+}
+
+func (h *Handler) moderationApprove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.Approve(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/moderation/queue", http.StatusSeeOther)
+}
+
+func (h *Handler) moderationReject(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.PUsecase.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	if !post.Pending {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.Reject(id)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/moderation/queue", http.StatusSeeOther)
+}
+
+func (h *Handler) moderationCommentApprove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.ApproveComment(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/moderation/queue", http.StatusSeeOther)
+}
+
+func (h *Handler) moderationCommentReject(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.RejectComment(id)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/moderation/queue", http.StatusSeeOther)
+}
+
+// postReport lets users flag a post for the moderators.
+func (h *Handler) postReport(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if !validator.NotBlank(reason) || !validator.MaxChars(reason, 200) {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if !h.captchaSolved(r, "report") {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.Report(id, user, reason)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", id), http.StatusSeeOther)
+}
+
+// moderationDismiss clears the reports of a post which is fine.
+func (h *Handler) moderationDismiss(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.DismissReports(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/moderation/queue", http.StatusSeeOther)
+}
+
+// moderationRemove deletes a reported post.
+func (h *Handler) moderationRemove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.Reject(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/moderation/queue", http.StatusSeeOther)
+}
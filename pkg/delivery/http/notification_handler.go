@@ -0,0 +1,106 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// notificationsShown is the number of notifications listed on the page.
+const notificationsShown = 50
+
+// notifications lists the latest notifications of the user with the form of
+// the notification preferences.
+func (h *Handler) notifications(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	notifications, err := h.NUsecase.Notifications(user, notificationsShown)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	prefs, err := h.NUsecase.Preferences(user)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	data.Notifications = notifications
+	data.Form = models.NotificationPrefsForm{Enabled: prefs}
+	h.render(w, http.StatusOK, "notifications.html", data)
+}
+
+func (h *Handler) notificationRead(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.NUsecase.MarkRead(user, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+func (h *Handler) notificationReadAll(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	err = h.NUsecase.MarkAllRead(user)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// notificationSettings stores the types of notifications the user wants, the
+// unchecked ones are turned off.
+func (h *Handler) notificationSettings(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	prefs := make(map[string]bool)
+	for _, typ := range r.PostForm["types"] {
+		prefs[typ] = true
+	}
+
+	err = h.NUsecase.SetPreferences(user, prefs)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
@@ -0,0 +1,435 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// apiDoc documents an entry of apiRoutes() in the OpenAPI document. Request
+// and response are values of the types sent and returned by the handler, the
+// schemas of the types are generated from their json tags.
+type apiDoc struct {
+	summary  string
+	query    []apiParam
+	request  any
+	response any
+	status   int
+}
+
+type apiParam struct {
+	name        string
+	typ         string
+	repeated    bool
+	description string
+}
+
+// apiDocs is keyed by the method and the pattern of the route. The tests
+// check with checkAPIDocs() that every route is documented.
+var apiDocs = map[string]apiDoc{
+	"GET /posts": {
+		summary: "List the published posts, newest first",
+		query: []apiParam{
+			{"page", "integer", false, "Page number, starting at 1"},
+			{"per_page", "integer", false, fmt.Sprintf("Posts per page, up to %d", apiMaxPerPage)},
+			{"category", "string", true, "Category slug, every given category must match"},
+			{"author", "string", false, "Username of the author"},
+		},
+		response: apiPostPage{},
+	},
+	"POST /posts": {
+		summary:  "Create a post",
+		request:  models.PostCreateForm{},
+		response: models.Post{},
+		status:   http.StatusCreated,
+	},
+	"GET /posts/{id}": {
+		summary:  "Get a post",
+		response: models.Post{},
+	},
+	"GET /posts/{id}/comments": {
+		summary:  "List the comments of a post",
+		response: []models.PostComments{},
+	},
+	"POST /posts/{id}/comments": {
+		summary:  "Comment on a post",
+		request:  apiCommentInput{},
+		response: models.PostComments{},
+		status:   http.StatusCreated,
+	},
+	"POST /posts/{id}/like": {
+		summary:  "Like a post or take the like back",
+		request:  models.UserLikeData{},
+		response: models.UserLikeData{},
+	},
+	"POST /posts/{id}/dislike": {
+		summary:  "Dislike a post or take the dislike back",
+		request:  models.UserDislikeData{},
+		response: models.UserDislikeData{},
+	},
+	"GET /posts/{id}/poll": {
+		summary:  "Get the poll of a post, without the counts while they are hidden from the user",
+		response: models.Poll{},
+	},
+	"POST /posts/{id}/poll": {
+		summary:  "Vote in the poll of a post, replacing the previous vote",
+		request:  apiVoteInput{},
+		response: models.Poll{},
+	},
+	"POST /comments/{id}/like": {
+		summary:  "Like a comment or take the like back",
+		request:  models.CommentLikeData{},
+		response: models.CommentLikeData{},
+	},
+	"POST /comments/{id}/dislike": {
+		summary:  "Dislike a comment or take the dislike back",
+		request:  models.CommentDislikeData{},
+		response: models.CommentDislikeData{},
+	},
+	"GET /categories": {
+		summary:  "List the categories",
+		response: []models.Category{},
+	},
+	"GET /me": {
+		summary:  "Get the authenticated user",
+		response: apiUser{},
+	},
+	"GET /users": {
+		summary: "List the usernames starting with a prefix",
+		query: []apiParam{
+			{"prefix", "string", false, fmt.Sprintf("Start of the username, required; ASCII letters match either case and up to %d names are returned", apiMaxUsers)},
+		},
+		response: []string{},
+	},
+	"POST /preview": {
+		summary:  "Render the Markdown of a post or a comment as HTML",
+		request:  apiPreviewInput{},
+		response: apiPreview{},
+	},
+	"GET /drafts": {
+		summary:  "List the drafts of the user, the last saved first",
+		response: []models.Draft{},
+	},
+	"POST /drafts": {
+		summary:  "Save a post being written as a draft",
+		request:  models.PostCreateForm{},
+		response: models.Draft{},
+		status:   http.StatusCreated,
+	},
+	"GET /drafts/{id}": {
+		summary:  "Get a draft",
+		response: models.Draft{},
+	},
+	"PUT /drafts/{id}": {
+		summary:  "Save a post being written over the draft",
+		request:  models.PostCreateForm{},
+		response: models.Draft{},
+	},
+}
+
+// legacyDoc documents an entry of legacyRoutes(). The routes answer with an
+// empty body.
+type legacyDoc struct {
+	summary    string
+	request    any
+	replacedBy string
+}
+
+// legacyDocs is keyed by the pattern of the route, every route is a POST.
+var legacyDocs = map[string]legacyDoc{
+	"/post/like": {
+		summary:    "Like a post or take the like back",
+		request:    models.UserLikeData{},
+		replacedBy: "POST " + apiPrefix + "/posts/{id}/like",
+	},
+	"/post/dislike": {
+		summary:    "Dislike a post or take the dislike back",
+		request:    models.UserDislikeData{},
+		replacedBy: "POST " + apiPrefix + "/posts/{id}/dislike",
+	},
+	"/post/commentLike": {
+		summary:    "Like a comment or take the like back",
+		request:    models.CommentLikeData{},
+		replacedBy: "POST " + apiPrefix + "/comments/{id}/like",
+	},
+	"/post/commentDislike": {
+		summary:    "Dislike a comment or take the dislike back",
+		request:    models.CommentDislikeData{},
+		replacedBy: "POST " + apiPrefix + "/comments/{id}/dislike",
+	},
+}
+
+// checkAPIDocs() reports the routes missing from apiDocs and legacyDocs and
+// the documented routes which don't exist.
+func checkAPIDocs(routes []apiRoute, legacy []legacyRoute) error {
+	var problems []string
+
+	known := make(map[string]bool)
+	for _, route := range routes {
+		key := route.method + " " + route.pattern
+		known[key] = true
+		if _, ok := apiDocs[key]; !ok {
+			problems = append(problems, "undocumented route "+key)
+		}
+	}
+	for key := range apiDocs {
+		if !known[key] {
+			problems = append(problems, "documented route "+key+" does not exist")
+		}
+	}
+
+	knownLegacy := make(map[string]bool)
+	for _, route := range legacy {
+		knownLegacy[route.pattern] = true
+		if _, ok := legacyDocs[route.pattern]; !ok {
+			problems = append(problems, "undocumented legacy route "+route.pattern)
+		}
+	}
+	for pattern := range legacyDocs {
+		if !knownLegacy[pattern] {
+			problems = append(problems, "documented legacy route "+pattern+" does not exist")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("openapi: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// openAPI() builds the OpenAPI 3 document of the JSON API.
+func (h *Handler) openAPI() ([]byte, error) {
+	routes := h.apiRoutes()
+	schemas := openAPISchemas{}
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     map[string]any{"application/json": map[string]any{"schema": schemas.ref(reflect.TypeOf(apiErrorResponse{}))}},
+		}
+	}
+
+	paths := make(map[string]map[string]any)
+	for _, route := range routes {
+		// Undocumented routes are left out, the tests report them.
+		doc, ok := apiDocs[route.method+" "+route.pattern]
+		if !ok {
+			continue
+		}
+
+		var parameters []any
+		if strings.Contains(route.pattern, apiPathParamID) {
+			parameters = append(parameters, map[string]any{
+				"name":     "id",
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "integer", "minimum": 1},
+			})
+		}
+		for _, param := range doc.query {
+			schema := map[string]any{"type": param.typ}
+			if param.repeated {
+				schema = map[string]any{"type": "array", "items": schema}
+			}
+			parameters = append(parameters, map[string]any{
+				"name":        param.name,
+				"in":          "query",
+				"description": param.description,
+				"schema":      schema,
+			})
+		}
+
+		status := doc.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		responses := map[string]any{
+			fmt.Sprint(status): map[string]any{
+				"description": http.StatusText(status),
+				"content":     map[string]any{"application/json": map[string]any{"schema": schemas.ref(reflect.TypeOf(doc.response))}},
+			},
+			"default": errorResponse("Error"),
+		}
+
+		operation := map[string]any{
+			"summary":     doc.summary,
+			"description": fmt.Sprintf("API tokens need the `%s` scope.", route.scope),
+			"responses":   responses,
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if doc.request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemas.ref(reflect.TypeOf(doc.request))}},
+			}
+			responses["400"] = errorResponse("Malformed JSON body")
+			responses["422"] = errorResponse("Validation failed, the fields of the error name the invalid ones")
+		}
+		if route.auth {
+			operation["security"] = []any{
+				map[string]any{"session": []string{}},
+				map[string]any{"token": []string{}},
+			}
+			responses["401"] = errorResponse("Not authenticated")
+		}
+		responses["403"] = errorResponse("The API token lacks the scope")
+		if strings.Contains(route.pattern, apiPathParamID) {
+			responses["404"] = errorResponse("Not found")
+		}
+		if route.limit != nil {
+			responses["429"] = errorResponse("Rate limited, see the Retry-After header")
+		}
+
+		path := apiPrefix + route.pattern
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(route.method)] = operation
+	}
+
+	// The legacy routes only take the session cookie and answer errors in
+	// plain text; signed out users are redirected to the login page.
+	for _, route := range h.legacyRoutes() {
+		doc, ok := legacyDocs[route.pattern]
+		if !ok {
+			continue
+		}
+		paths[route.pattern] = map[string]any{
+			"post": map[string]any{
+				"summary":     doc.summary,
+				"description": fmt.Sprintf("Deprecated, use `%s`.", doc.replacedBy),
+				"deprecated":  true,
+				"requestBody": map[string]any{
+					"required": true,
+					"content":  map[string]any{"application/json": map[string]any{"schema": schemas.ref(reflect.TypeOf(doc.request))}},
+				},
+				"security": []any{map[string]any{"session": []string{}}},
+				"responses": map[string]any{
+					"200": map[string]any{"description": http.StatusText(http.StatusOK)},
+					"303": map[string]any{"description": "Not signed in, redirects to the login page"},
+					"400": map[string]any{"description": "Malformed JSON body"},
+					"429": map[string]any{"description": "Rate limited, see the Retry-After header"},
+				},
+			},
+		}
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Forum API",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"session": map[string]any{"type": "apiKey", "in": "cookie", "name": "session"},
+				"token":   map[string]any{"type": "http", "scheme": "bearer", "description": "Personal API token"},
+			},
+		},
+		"security": []any{
+			map[string]any{},
+			map[string]any{"session": []string{}},
+			map[string]any{"token": []string{}},
+		},
+	}, "", "  ")
+}
+
+// openAPISchemas collects the component schemas of the named struct types.
+type openAPISchemas map[string]any
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (s openAPISchemas) ref(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.ref(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.ref(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.ref(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		// The types of the handlers are named apiX, the schema is called X.
+		name := strings.TrimPrefix(t.Name(), "api")
+		if _, ok := s[name]; !ok {
+			s[name] = nil
+			s[name] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (s openAPISchemas) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	s.fields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields() adds the fields of the struct as encoding/json marshals them,
+// embedded structs included.
+func (s openAPISchemas) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.fields(embedded, properties, required)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = s.ref(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func (h *Handler) apiSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.openAPISpec)
+}
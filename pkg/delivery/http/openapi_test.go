@@ -0,0 +1,53 @@
+package delivery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAPIDocs(t *testing.T) {
+	h := &Handler{}
+	if err := checkAPIDocs(h.apiRoutes(), h.legacyRoutes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	h := &Handler{}
+	spec, err := h.openAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q, want 3.0.3", doc.OpenAPI)
+	}
+	for _, route := range h.apiRoutes() {
+		path := doc.Paths[apiPrefix+route.pattern]
+		if path == nil {
+			t.Errorf("path %s missing", apiPrefix+route.pattern)
+			continue
+		}
+		if _, ok := path[strings.ToLower(route.method)]; !ok {
+			t.Errorf("operation %s %s missing", route.method, route.pattern)
+		}
+	}
+	for _, route := range h.legacyRoutes() {
+		operation, ok := doc.Paths[route.pattern]["post"].(map[string]any)
+		if !ok {
+			t.Errorf("operation POST %s missing", route.pattern)
+			continue
+		}
+		if operation["deprecated"] != true {
+			t.Errorf("operation POST %s is not deprecated", route.pattern)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/validator"
+)
+
+// checkPoll() trims the options of the poll of a post being created and
+// validates it. A poll of a scheduled post must close after it is published.
+func checkPoll(v *validator.Validator, poll *models.PollForm, publishAt *time.Time) {
+	seen := make(map[string]bool)
+	for i, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		poll.Options[i] = option
+		v.CheckField(validator.NotBlank(option), "poll", "The options cannot be blank")
+		v.CheckField(validator.MaxChars(option, 100), "poll", "The options cannot be more than 100 characters long")
+		v.CheckField(!seen[strings.ToLower(option)], "poll", "The options must be different")
+		seen[strings.ToLower(option)] = true
+	}
+	v.CheckField(len(poll.Options) >= models.MinPollOptions, "poll", fmt.Sprintf("A poll needs at least %d options", models.MinPollOptions))
+	v.CheckField(len(poll.Options) <= models.MaxPollOptions, "poll", fmt.Sprintf("A poll can have at most %d options", models.MaxPollOptions))
+	if poll.Closes != nil {
+		v.CheckField(poll.Closes.After(time.Now()), "poll", "The closing time has already passed")
+		v.CheckField(publishAt == nil || poll.Closes.After(*publishAt), "poll", "The poll must close after the post is published")
+	}
+}
+
+// readPoll() reads the poll fields of the create form: nil when no option is
+// filled in. The empty inputs left for more options are skipped.
+func readPoll(r *http.Request, data *models.TemplateData) *models.PollForm {
+	poll := &models.PollForm{
+		Multiple:    r.PostForm.Get("poll_multiple") != "",
+		HideResults: r.PostForm.Get("poll_hide") != "",
+	}
+	for _, option := range r.PostForm["poll_option"] {
+		if validator.NotBlank(option) {
+			poll.Options = append(poll.Options, option)
+		}
+	}
+	if len(poll.Options) == 0 {
+		return nil
+	}
+
+	if value := r.PostForm.Get("poll_closes"); value != "" {
+		closes, err := parsePublishAt(value, r.PostForm.Get("poll_tz"))
+		if err != nil {
+			data.AddFieldError("poll", "The closing time must be a date and a time")
+		} else {
+			poll.Closes = &closes
+		}
+	}
+	return poll
+}
+
+// postPoll() returns the poll of the post as the user sees it, or nil when the
+// post has none.
+func (h *Handler) postPoll(id int, user int) (*models.Poll, error) {
+	poll, err := h.PUsecase.Poll(id, user)
+	if errors.Is(err, models.ErrNoRecord) {
+		return nil, nil
+	}
+	return poll, err
+}
+
+type apiVoteInput struct {
+	Options []int `json:"options"`
+}
+
+// apiPollGet returns the poll of the post. The counts are left out while the
+// results are hidden from the user.
+func (h *Handler) apiPollGet(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	if _, ok := h.visiblePost(w, id, user); !ok {
+		return
+	}
+
+	poll, err := h.PUsecase.Poll(id, user)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.apiError(w, http.StatusNotFound, nil)
+		} else {
+			h.apiServerError(w, err)
+		}
+		return
+	}
+	h.writeJSON(w, http.StatusOK, poll)
+}
+
+// apiPollVote replaces the vote of the user in the poll of the post and
+// returns the poll with the results.
+func (h *Handler) apiPollVote(w http.ResponseWriter, r *http.Request, id int) {
+	user, _ := h.UUsecase.GetUserId(r)
+
+	if _, ok := h.visiblePost(w, id, user); !ok {
+		return
+	}
+	var input apiVoteInput
+	if !h.readJSON(w, r, &input) {
+		return
+	}
+
+	poll, err := h.PUsecase.Vote(id, user, input.Options)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			h.apiError(w, http.StatusNotFound, nil)
+		case errors.Is(err, models.ErrInvalidVote):
+			h.apiError(w, http.StatusUnprocessableEntity, map[string]string{"options": "Choose one of the options, or several when the poll allows it"})
+		case errors.Is(err, models.ErrPollClosed):
+			h.writeJSON(w, http.StatusConflict, apiErrorResponse{apiErrorDetail{
+				Status:  http.StatusConflict,
+				Message: "The poll is closed",
+			}})
+		default:
+			h.apiServerError(w, err)
+		}
+		return
+	}
+	h.writeJSON(w, http.StatusOK, poll)
+}
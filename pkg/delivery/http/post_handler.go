@@ -4,17 +4,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
+	"strconv"
 
+	"forum.bbilisbe/internal/images"
 	"forum.bbilisbe/internal/models"
 	"forum.bbilisbe/internal/validator"
-	"github.com/gofrs/uuid"
 )
 
+// maxAttachments is the number of files a post can have.
+const maxAttachments = 10
+
+// maxContentChars is the length of the content of a post, which bounds the
+// work of rendering its Markdown.
+const maxContentChars = 50000
+
 func (h *Handler) postView(w http.ResponseWriter, r *http.Request) {
 	postId, err := h.PUsecase.GetPostId(r)
 	if err != nil {
@@ -31,14 +36,23 @@ func (h *Handler) postView(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	post.Author, _ = h.UUsecase.GetUserName(post.Author)
-
+	// Visitors who aren't signed in get the error and user 0.
 	user, err := h.UUsecase.GetUserId(r)
-	if err != nil {
-		h.serverError(w, err)
+
+	// Held and scheduled posts are only visible to their author and the
+	// moderators.
+	if post.Hidden() && post.Author != strconv.Itoa(user) && !h.UUsecase.IsModerator(user) {
+		h.notFound(w)
+		return
 	}
+	isAuthor := post.Author == strconv.Itoa(user)
+	post.Author, _ = h.UUsecase.GetUserName(post.Author)
 	data := h.newTemplateData(r)
+	data.IsAuthor = isAuthor
 	Comments, _ := h.PUsecase.GetComments(postId, user)
+	for _, comment := range Comments {
+		comment.Author, _ = h.UUsecase.GetUserName(comment.Author)
+	}
 	data.Comments = Comments
 	data.Post = post
 
@@ -52,11 +66,26 @@ func (h *Handler) postView(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	post.Poll, err = h.postPoll(postId, user)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	// Every rendering of the page gets new challenges, a solved one can't be
+	// used again.
+	h.setCaptcha(data, "comment")
+	data.ReportCaptcha = h.newCaptcha("report")
 
 	if r.Method == http.MethodPost {
+		// Visitors have to sign in to comment.
+		if !data.Logged {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
 		comment := models.PostComments{
 			Comment: r.FormValue("comment"),
 		}
+		h.checkCaptcha(r, data, "comment")
 		data.CheckField(validator.NotBlank(comment.Comment), "comment", "This field cannot be blank")
 		data.CheckField(validator.MaxChars(comment.Comment, 100), "comment", "This field cannot be more than 100 characters long")
 		if !data.Valid() {
@@ -64,15 +93,24 @@ func (h *Handler) postView(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err := h.PUsecase.CommentInsert(comment.Comment, user, postId)
-		if err != nil {
-			h.serverError(w, err)
+		id, err := h.PUsecase.CommentInsert(comment.Comment, user, postId)
+		if err != nil && id != 0 {
+			// The comment is stored, only a later step failed.
+			h.errorLog.Print(err)
+		} else if err != nil {
+			if errors.Is(err, models.ErrRejectedContent) {
+				data.AddFieldError("comment", "This comment was rejected by the content filter")
+				h.render(w, http.StatusUnprocessableEntity, "view.html", data)
+			} else {
+				h.serverError(w, err)
+			}
 			return
 		}
 
 		Comments, _ := h.PUsecase.GetComments(postId, user)
 		data.Comments = Comments
 		http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postId), http.StatusSeeOther)
+		return
 	}
 
 	h.render(w, http.StatusOK, "view.html", data)
@@ -82,11 +120,40 @@ func (h *Handler) postCreate(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 
 		data := h.newTemplateData(r)
-		data.Form = models.PostCreateForm{}
+		form := models.PostCreateForm{Categories: []string{"music"}}
+
+		// ?draft= continues writing a draft.
+		if s := r.URL.Query().Get("draft"); s != "" {
+			id, err := strconv.Atoi(s)
+			if err != nil || id < 1 {
+				h.notFound(w)
+				return
+			}
+			user, _ := h.UUsecase.GetUserId(r)
+			draft, err := h.PUsecase.GetDraft(id, user)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					h.notFound(w)
+				} else {
+					h.serverError(w, err)
+				}
+				return
+			}
+			form = models.PostCreateForm{
+				Title:      draft.Title,
+				Content:    draft.Content,
+				Categories: draft.Categories,
+				Draft:      draft.ID,
+			}
+			data.Draft = draft
+		}
+
+		data.Form = form
 		h.render(w, http.StatusOK, "create.html", data)
 
 	} else if r.Method == http.MethodPost {
-		maxRequestSize := int64(20 * 1024 * 1024)
+		// The form fields come on top of the attachments.
+		maxRequestSize := h.cfg.MaxPostSize + 1024*1024
 
 		err := r.ParseMultipartForm(maxRequestSize)
 		if err != nil {
@@ -101,72 +168,135 @@ func (h *Handler) postCreate(w http.ResponseWriter, r *http.Request) {
 			Content:    r.PostForm.Get("content"),
 			Categories: r.Form["category"],
 		}
+		form.Draft, _ = strconv.Atoi(r.PostForm.Get("draft"))
 
-		file, handler, err := r.FormFile("image")
-		// Check if the request size exceeds the limit.
-		if r.ContentLength > maxRequestSize {
-			data.Form = form
-			data.AddNonFieldError("image", "Choose image less than 20MB")
-			h.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		if r.PostForm.Get("save") != "" {
+			h.postDraftSave(w, r, data, form)
 			return
 		}
-		if err == nil {
-			uniqueID, err := uuid.NewV4()
-			if err != nil {
-				h.serverError(w, err)
-				return
-			}
-			filename := strings.Replace(uniqueID.String(), "-", "", -1)
-			prevname := filepath.Base(handler.Filename)
-			fileExt := filepath.Ext(prevname)
 
-			if fileExt != ".jpeg" && fileExt != ".png" && fileExt != ".gif" && fileExt != ".jpg" {
-				h.clientError(w, http.StatusBadRequest)
-			}
+		// Only publishing counts against the post limit, drafts can be
+		// saved as often as needed.
+		h.RateLimit(h.postLimit, func(w http.ResponseWriter, r *http.Request) {
+			h.postPublish(w, r, data, form)
+		})(w, r)
+	}
+}
 
-			image := fmt.Sprintf("%s%s", filename, fileExt)
-			f, err := os.Create(fmt.Sprintf("./ui/static/img/user_images/%s", image))
-			if err != nil {
-				h.serverError(w, err)
-				return
-			}
-			defer f.Close()
+// postPublish validates the create form, stores the attachments and creates
+// the post.
+func (h *Handler) postPublish(w http.ResponseWriter, r *http.Request, data *models.TemplateData, form models.PostCreateForm) {
+	maxRequestSize := h.cfg.MaxPostSize + 1024*1024
+	form.PublishAt = checkPublishAt(r, data)
+	form.Poll = readPoll(r, data)
+	if form.Poll != nil {
+		checkPoll(&data.Validator, form.Poll, form.PublishAt)
+	}
 
-			_, err = io.Copy(f, file)
-			if err != nil {
-				h.serverError(w, err)
-				return
-			}
-			form.ImageURL = fmt.Sprintf("/static/img/user_images/%s", image)
-		}
+	// Check if the request size exceeds the limit.
+	if r.ContentLength > maxRequestSize {
+		data.Form = form
+		data.AddFieldError("attachments", fmt.Sprintf("The attachments must be less than %s in total", fileSize(h.cfg.MaxPostSize)))
+		h.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		return
+	}
 
-		if len(form.Categories) == 0 {
-			data.Form = form
-			data.AddNonFieldError("tags", "Tags cannot be empty")
-			h.render(w, http.StatusUnprocessableEntity, "create.html", data)
-			os.Remove(fmt.Sprintf("./ui%s", form.ImageURL))
-			return
+	if len(form.Categories) == 0 {
+		data.Form = form
+		data.AddNonFieldError("tags", "Tags cannot be empty")
+		h.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		return
+	}
+
+	data.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	data.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	data.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	data.CheckField(validator.MaxChars(form.Content, maxContentChars), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxContentChars))
+
+	// Browsers send an empty part when no file is chosen.
+	var files []*multipart.FileHeader
+	var total int64
+	for _, file := range r.MultipartForm.File["attachments"] {
+		if file.Filename == "" && file.Size == 0 {
+			continue
 		}
+		files = append(files, file)
+		total += file.Size
+		data.CheckField(file.Size <= h.cfg.MaxFileSize, "attachments", fmt.Sprintf("%s is larger than %s", file.Filename, fileSize(h.cfg.MaxFileSize)))
+	}
+	data.CheckField(len(files) <= maxAttachments, "attachments", fmt.Sprintf("A post can have at most %d attachments", maxAttachments))
+	data.CheckField(total <= h.cfg.MaxPostSize, "attachments", fmt.Sprintf("The attachments must be less than %s in total", fileSize(h.cfg.MaxPostSize)))
 
-		data.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-		data.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-		data.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	if !data.Valid() {
+		data.Form = form
+		h.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		return
+	}
 
-		if !data.Valid() {
-			data.Form = form
-			h.render(w, http.StatusUnprocessableEntity, "create.html", data)
-			return
+	// The attachments are only stored once the rest of the form is valid,
+	// and deleted again when the post isn't created.
+	for _, file := range files {
+		a, err := h.saveAttachment(file)
+		if err == nil {
+			form.Attachments = append(form.Attachments, a)
+			continue
 		}
 
-		author, _ := h.UUsecase.GetUserId(r)
-		id, err := h.PUsecase.Insert(form, author)
-		if err != nil {
+		if err := h.PUsecase.DeleteAttachments(form.Attachments); err != nil {
+			h.errorLog.Print(err)
+		}
+		data.Form = form
+		switch {
+		case errors.Is(err, models.ErrAttachmentType):
+			data.AddFieldError("attachments", fmt.Sprintf("%s: files of this type can't be attached", file.Filename))
+		case errors.Is(err, models.ErrAttachmentTooLarge):
+			data.AddFieldError("attachments", fmt.Sprintf("%s is larger than %s", file.Filename, fileSize(h.cfg.MaxFileSize)))
+		case errors.Is(err, images.ErrInvalid):
+			data.AddFieldError("attachments", fmt.Sprintf("%s is a damaged image", file.Filename))
+		case errors.Is(err, images.ErrTooLarge):
+			data.AddFieldError("attachments", fmt.Sprintf("%s must be at most %d pixels wide and high", file.Filename, images.MaxSide))
+		case errors.Is(err, images.ErrTooLong):
+			data.AddFieldError("attachments", fmt.Sprintf("%s has more than %d frames or is too large to animate", file.Filename, images.MaxFrames))
+		default:
 			h.serverError(w, err)
 			return
 		}
+		h.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		return
+	}
 
-		http.Redirect(w, r, fmt.Sprintf("/post/view/%d", id), http.StatusSeeOther)
+	author, _ := h.UUsecase.GetUserId(r)
+	id, err := h.PUsecase.Insert(form, author)
+	if err != nil && id != 0 {
+		// The post and its attachments are stored, only a later step
+		// failed.
+		h.errorLog.Print(err)
+	} else if err != nil {
+		if err := h.PUsecase.DeleteAttachments(form.Attachments); err != nil {
+			h.errorLog.Print(err)
+		}
+		if errors.Is(err, models.ErrRejectedContent) {
+			data.Form = form
+			data.AddNonFieldError("content", "This post was rejected by the content filter")
+			h.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", id), http.StatusSeeOther)
+}
+
+// saveAttachment stores an uploaded file with PUsecase.SaveAttachment().
+func (h *Handler) saveAttachment(header *multipart.FileHeader) (*models.Attachment, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
+
+	return h.PUsecase.SaveAttachment(header.Filename, file)
 }
 
 func (h *Handler) postLike(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,149 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"forum.bbilisbe/internal/models"
+)
+
+const (
+	// profileListSize is the number of posts or comments shown on a tab of a
+	// profile.
+	profileListSize = 50
+	// maxBio is the length limit of a bio in characters.
+	maxBio = 500
+)
+
+// userPage serves the profile at /u/{username} and the feeds of the posts of
+// the user at /u/{username}/{format}.
+func (h *Handler) userPage(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(strings.TrimPrefix(r.URL.Path, "/u/"), "/") {
+		h.feedUser(w, r)
+		return
+	}
+	h.userProfile(w, r)
+}
+
+// userProfile shows the profile of a user with a tab of their posts, comments
+// or liked posts. The comments and likes tabs are only shown to others when
+// the user allows it.
+func (h *Handler) userProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.UUsecase.GetProfile(strings.TrimPrefix(r.URL.Path, "/u/"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	viewer, err := h.UUsecase.GetUserId(r)
+	if err == nil && viewer == profile.ID {
+		profile.ShowComments, profile.ShowLikes = true, true
+	}
+
+	tab := r.URL.Query().Get("tab")
+	switch {
+	case tab == "":
+		tab = models.ProfilePosts
+	case tab == models.ProfileComments && !profile.ShowComments,
+		tab == models.ProfileLikes && !profile.ShowLikes,
+		tab != models.ProfilePosts && tab != models.ProfileComments && tab != models.ProfileLikes:
+		h.notFound(w)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	data.Profile = profile
+	data.ProfileTab = tab
+
+	switch tab {
+	case models.ProfilePosts:
+		data.ProfilePosts, err = h.UUsecase.GetProfilePosts(profile.ID, profileListSize)
+		for _, post := range data.ProfilePosts {
+			post.Author = profile.Name
+		}
+	case models.ProfileComments:
+		data.ProfileComments, err = h.UUsecase.GetProfileComments(profile.ID, profileListSize)
+	case models.ProfileLikes:
+		data.ProfilePosts, err = h.UUsecase.GetProfileLikes(profile.ID, profileListSize)
+		for _, post := range data.ProfilePosts {
+			post.Author, _ = h.UUsecase.GetUserName(post.Author)
+		}
+	}
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	h.render(w, http.StatusOK, "profile.html", data)
+}
+
+// profileSettingsData() returns the template data of the profile settings of
+// the user, with the current settings in the form.
+func (h *Handler) profileSettingsData(r *http.Request, id int) (*models.TemplateData, error) {
+	user, err := h.UUsecase.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := h.UUsecase.GetProfile(user.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	data := h.newTemplateData(r)
+	data.Profile = profile
+	data.Form = models.ProfileForm{Bio: profile.Bio, ShowComments: profile.ShowComments, ShowLikes: profile.ShowLikes}
+	return data, nil
+}
+
+// userProfileSettings changes the bio and the privacy settings of the user.
+func (h *Handler) userProfileSettings(w http.ResponseWriter, r *http.Request) {
+	id, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	data, err := h.profileSettingsData(r, id)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		err = r.ParseForm()
+		if err != nil {
+			h.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form := models.ProfileForm{
+			Bio:          strings.TrimSpace(r.PostForm.Get("bio")),
+			ShowComments: r.PostForm.Get("show_comments") != "",
+			ShowLikes:    r.PostForm.Get("show_likes") != "",
+		}
+		data.Form = form
+
+		data.CheckField(utf8.RuneCountInString(form.Bio) <= maxBio, "bio", "This field cannot be more than 500 characters long")
+
+		if data.Valid() {
+			err = h.UUsecase.UpdateProfile(id, form)
+			if err != nil {
+				h.serverError(w, err)
+				return
+			}
+			http.Redirect(w, r, "/u/"+data.Profile.Name, http.StatusSeeOther)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	if !data.Valid() {
+		status = http.StatusUnprocessableEntity
+	}
+	h.render(w, status, "profilesettings.html", data)
+}
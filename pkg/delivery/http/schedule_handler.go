@@ -0,0 +1,104 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// parsePublishAt() parses the value of a datetime-local input. The browser
+// sends no time zone; schedule.js adds the offset of the user's clock in
+// minutes as tz, without it the time is taken as UTC.
+func parsePublishAt(value, tz string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02T15:04", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if tz != "" {
+		offset, err := strconv.Atoi(tz)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t = t.Add(time.Duration(offset) * time.Minute)
+	}
+	return t, nil
+}
+
+// checkPublishAt() reads the publish_at field of the form: nil when it is
+// empty, otherwise a time in the future or a field error.
+func checkPublishAt(r *http.Request, data *models.TemplateData) *time.Time {
+	value := r.PostForm.Get("publish_at")
+	if value == "" {
+		return nil
+	}
+	at, err := parsePublishAt(value, r.PostForm.Get("tz"))
+	if err != nil {
+		data.AddFieldError("publish_at", "This field must be a date and a time")
+		return nil
+	}
+	data.CheckField(at.After(time.Now()), "publish_at", "This time has already passed")
+	return &at
+}
+
+// postSchedule moves a scheduled post of the user to another time.
+func (h *Handler) postSchedule(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	at := checkPublishAt(r, data)
+	if at == nil || !data.Valid() {
+		h.clientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = h.PUsecase.Reschedule(id, user, *at)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", id), http.StatusSeeOther)
+}
+
+// postPublishNow publishes a scheduled post of the user without waiting.
+func (h *Handler) postPublishNow(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.PUsecase.PublishNow(id, user)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", id), http.StatusSeeOther)
+}
@@ -0,0 +1,92 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/models"
+)
+
+// userTokens lists the personal API tokens of the user and creates new ones.
+// A created token is shown once, only its hash is kept.
+func (h *Handler) userTokens(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	form := models.APITokenForm{Scopes: []string{models.ScopeRead}}
+
+	if r.Method == http.MethodPost {
+		err = r.ParseForm()
+		if err != nil {
+			h.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form = models.APITokenForm{Name: strings.TrimSpace(r.PostForm.Get("name"))}
+		for _, scope := range models.Scopes {
+			if config.Has(r.PostForm["scopes"], scope) {
+				form.Scopes = append(form.Scopes, scope)
+			}
+		}
+
+		data.CheckField(form.Name != "", "name", "This field cannot be blank")
+		data.CheckField(utf8.RuneCountInString(form.Name) <= 50, "name", "This field cannot be more than 50 characters long")
+		data.CheckField(len(form.Scopes) > 0, "scopes", "Select at least one scope")
+
+		if data.Valid() {
+			data.NewAPIToken, err = h.UUsecase.CreateAPIToken(user, form)
+			if err != nil {
+				h.serverError(w, err)
+				return
+			}
+			form = models.APITokenForm{Scopes: []string{models.ScopeRead}}
+		}
+	}
+
+	tokens, err := h.UUsecase.GetAPITokens(user)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	data.APITokens = tokens
+	data.Form = form
+
+	status := http.StatusOK
+	if !data.Valid() {
+		status = http.StatusUnprocessableEntity
+	}
+	h.render(w, status, "tokens.html", data)
+}
+
+func (h *Handler) tokenRevoke(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UUsecase.GetUserId(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.UUsecase.RevokeAPIToken(user, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
+}
@@ -15,7 +15,8 @@ func (h *Handler) userSignup(w http.ResponseWriter, r *http.Request) {
 		if data.Logged {
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 		}
-		data.Form = models.UserSignupForm{}
+		data.Form = models.UserSignupForm{Invite: r.URL.Query().Get("invite")}
+		h.setCaptcha(data, "signup")
 		h.render(w, http.StatusOK, "signup.html", data)
 	} else if r.Method == http.MethodPost {
 
@@ -31,6 +32,7 @@ func (h *Handler) userSignup(w http.ResponseWriter, r *http.Request) {
 			Name:     r.PostForm.Get("name"),
 			Email:    r.PostForm.Get("email"),
 			Password: r.PostForm.Get("password"),
+			Invite:   r.PostForm.Get("invite"),
 		}
 
 		data.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
@@ -38,26 +40,44 @@ func (h *Handler) userSignup(w http.ResponseWriter, r *http.Request) {
 		data.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 		data.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 		data.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+		if h.cfg.InviteOnly {
+			data.CheckField(validator.NotBlank(form.Invite), "invite", "This field cannot be blank")
+		}
+		h.checkCaptcha(r, data, "signup")
 
 		if !data.Valid() {
 			data.Form = form
+			h.setCaptcha(data, "signup")
 			h.render(w, http.StatusUnprocessableEntity, "signup.html", data)
 			return
 		}
 
-		err = h.UUsecase.Insert(form.Name, form.Email, form.Password)
+		if h.cfg.InviteOnly {
+			err = h.UUsecase.InsertWithInvite(form)
+		} else {
+			err = h.UUsecase.Insert(form.Name, form.Email, form.Password)
+		}
 		if err != nil {
-			if errors.Is(err, models.ErrDuplicateEmail) {
+			if errors.Is(err, models.ErrInvalidInvite) {
+
+				data := h.newTemplateData(r)
+				data.AddFieldError("invite", "This invite code is not valid")
+				data.Form = form
+				h.setCaptcha(data, "signup")
+				h.render(w, http.StatusUnprocessableEntity, "signup.html", data)
+			} else if errors.Is(err, models.ErrDuplicateEmail) {
 
 				data := h.newTemplateData(r)
 				data.AddFieldError("email", "Email address is already in use")
 				data.Form = form
+				h.setCaptcha(data, "signup")
 				h.render(w, http.StatusUnprocessableEntity, "signup.html", data)
 			} else if errors.Is(err, models.ErrDuplicateUsername) {
 
 				data := h.newTemplateData(r)
 				data.AddFieldError("name", "Username is already in use")
 				data.Form = form
+				h.setCaptcha(data, "signup")
 				h.render(w, http.StatusUnprocessableEntity, "signup.html", data)
 			} else {
 				h.serverError(w, err)
@@ -76,6 +96,7 @@ func (h *Handler) userLogin(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 		}
 		data.Form = models.UserLoginForm{}
+		h.setCaptcha(data, "login")
 		h.render(w, http.StatusOK, "login.html", data)
 	} else if r.Method == http.MethodPost {
 		err := r.ParseForm()
@@ -94,9 +115,11 @@ func (h *Handler) userLogin(w http.ResponseWriter, r *http.Request) {
 		data.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
 		data.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 		data.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+		h.checkCaptcha(r, data, "login")
 
 		if !data.Valid() {
 			data.Form = form
+			h.setCaptcha(data, "login")
 			h.render(w, http.StatusUnprocessableEntity, "login.html", data)
 			return
 		}
@@ -107,6 +130,7 @@ func (h *Handler) userLogin(w http.ResponseWriter, r *http.Request) {
 				data := h.newTemplateData(r)
 				data.AddNonFieldError("email", "Email or password is incorrect")
 				data.Form = form
+				h.setCaptcha(data, "login")
 				h.render(w, http.StatusUnprocessableEntity, "login.html", data)
 			} else {
 				h.serverError(w, err)
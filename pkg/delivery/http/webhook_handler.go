@@ -0,0 +1,100 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/webhook"
+)
+
+// deliveryLogSize is the number of deliveries shown in the delivery log.
+const deliveryLogSize = 100
+
+func (h *Handler) adminWebhooks(w http.ResponseWriter, r *http.Request) {
+	data := h.newTemplateData(r)
+	form := models.WebhookForm{Events: webhook.Events}
+
+	if r.Method == http.MethodPost {
+		err := r.ParseForm()
+		if err != nil {
+			h.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form = models.WebhookForm{
+			URL:    strings.TrimSpace(r.PostForm.Get("url")),
+			Secret: strings.TrimSpace(r.PostForm.Get("secret")),
+		}
+		for _, event := range webhook.Events {
+			if config.Has(r.PostForm["events"], event) {
+				form.Events = append(form.Events, event)
+			}
+		}
+
+		u, err := url.Parse(form.URL)
+		data.CheckField(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "This field must be an http or https URL")
+		data.CheckField(len(form.Events) > 0, "events", "Select at least one event")
+
+		if data.Valid() {
+			err = h.WUsecase.WebhookInsert(form)
+			if err != nil {
+				h.serverError(w, err)
+				return
+			}
+			http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+			return
+		}
+	}
+
+	hooks, err := h.WUsecase.Webhooks()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	data.Webhooks = hooks
+	data.Form = form
+
+	status := http.StatusOK
+	if !data.Valid() {
+		status = http.StatusUnprocessableEntity
+	}
+	h.render(w, status, "webhooks.html", data)
+}
+
+func (h *Handler) adminWebhookDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || id < 1 {
+		h.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.WUsecase.WebhookDelete(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.notFound(w)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+func (h *Handler) adminWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.WUsecase.Deliveries(deliveryLogSize)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	data := h.newTemplateData(r)
+	data.Deliveries = deliveries
+
+	h.render(w, http.StatusOK, "deliveries.html", data)
+}
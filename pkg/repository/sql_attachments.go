@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"forum.bbilisbe/internal/models"
+)
+
+const attachmentColumns = `id, postid, name, url, thumbnail, content_type, size, width, height, downloads, created`
+
+func scanAttachment(row interface{ Scan(...any) error }) (*models.Attachment, error) {
+	a := &models.Attachment{}
+	err := row.Scan(&a.ID, &a.PostID, &a.Name, &a.URL, &a.Thumbnail, &a.ContentType, &a.Size, &a.Width, &a.Height, &a.Downloads, &a.Created)
+	return a, err
+}
+
+// AttachmentInsert records the files uploaded with the post in their order.
+// insertAttachments adds the stored files to the post being created.
+func insertAttachments(tx *sql.Tx, postid int, attachments []*models.Attachment) error {
+	stmt := `INSERT INTO attachments (postid, name, url, thumbnail, content_type, size, width, height, downloads, created)
+	VALUES (?, ?, ?, '', ?, ?, ?, ?, 0, datetime('now')) RETURNING id`
+
+	for _, a := range attachments {
+		err := tx.QueryRow(stmt, postid, a.Name, a.URL, a.ContentType, a.Size, a.Width, a.Height).Scan(&a.ID)
+		if err != nil {
+			return err
+		}
+		a.PostID = postid
+	}
+	return nil
+}
+
+// Attachments returns the files of the post in the order they were uploaded.
+func (m *sqlPostsRepository) Attachments(postid int) ([]*models.Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + ` FROM attachments WHERE postid = ? ORDER BY id`
+
+	rows, err := m.Conn.Query(stmt, postid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*models.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (m *sqlPostsRepository) GetAttachment(id int) (*models.Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = ?`
+
+	a, err := scanAttachment(m.Conn.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+func (m *sqlPostsRepository) AttachmentThumbnail(id int, thumbnail string) error {
+	stmt := `UPDATE attachments SET thumbnail = ? WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, thumbnail, id)
+	return err
+}
+
+// AttachmentDownloaded counts a download of the attachment.
+func (m *sqlPostsRepository) AttachmentDownloaded(id int) error {
+	stmt := `UPDATE attachments SET downloads = downloads + 1 WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, id)
+	return err
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// GetAvatar returns the avatar of the user with the name. Without an uploaded
+// avatar Image is empty and Updated is the creation of the account.
+func (m *sqlUserRepository) GetAvatar(name string) (*models.Avatar, error) {
+	a := &models.Avatar{}
+
+	stmt := `SELECT users.id, users.username, users.created, COALESCE(avatars.image, ''), avatars.updated
+	FROM users LEFT JOIN avatars ON users.id = avatars.userid
+	WHERE users.username = ?`
+
+	var updated sql.NullTime
+	err := m.Conn.QueryRow(stmt, name).Scan(&a.UserID, &a.Name, &a.Updated, &a.Image, &updated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	if updated.Valid {
+		a.Updated = updated.Time
+	}
+	return a, nil
+}
+
+// SetAvatar records the image of the avatar of the user and returns the image
+// it replaces, if any.
+func (m *sqlUserRepository) SetAvatar(user int, image string) (string, error) {
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return "", err
+	}
+
+	var old string
+	err = tx.QueryRow(`SELECT image FROM avatars WHERE userid = ?`, user).Scan(&old)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return "", err
+	}
+
+	stmt := `INSERT INTO avatars (userid, image, updated) VALUES (?, ?, datetime('now'))
+	ON CONFLICT (userid) DO UPDATE SET image = excluded.image, updated = excluded.updated`
+
+	if _, err = tx.Exec(stmt, user, image); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	return old, tx.Commit()
+}
+
+// RemoveAvatar deletes the avatar of the user and returns its image. It
+// returns ErrNoRecord when the user has no uploaded avatar.
+func (m *sqlUserRepository) RemoveAvatar(user int) (string, error) {
+	var image string
+
+	stmt := `DELETE FROM avatars WHERE userid = ? RETURNING image`
+
+	err := m.Conn.QueryRow(stmt, user).Scan(&image)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", models.ErrNoRecord
+		}
+		return "", err
+	}
+	return image, nil
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+type sqlDigestRepository struct {
+	Conn *sql.DB
+}
+
+func NewSqlDigestRepository(conn *sql.DB) models.DigestRepository {
+	return &sqlDigestRepository{conn}
+}
+
+// window() returns the datetime modifier selecting the last d.
+func window(d time.Duration) string {
+	return fmt.Sprintf("-%d seconds", int(d.Seconds()))
+}
+
+// Settings returns the digest settings of the user, turned off when the user
+// never changed them.
+func (m *sqlDigestRepository) Settings(user int) (*models.DigestSettings, error) {
+	s := &models.DigestSettings{UserID: user, Frequency: models.DigestOff}
+	var categories string
+
+	stmt := `SELECT frequency, categories FROM digest_settings WHERE userid = ?`
+
+	err := m.Conn.QueryRow(stmt, user).Scan(&s.Frequency, &categories)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	s.Categories = strings.Fields(categories)
+	return s, nil
+}
+
+func (m *sqlDigestRepository) SetSettings(user int, s models.DigestSettings) error {
+	stmt := `INSERT INTO digest_settings (userid, frequency, categories) VALUES (?, ?, ?)
+	ON CONFLICT (userid) DO UPDATE SET frequency = excluded.frequency, categories = excluded.categories`
+
+	_, err := m.Conn.Exec(stmt, user, s.Frequency, strings.Join(s.Categories, " "))
+	return err
+}
+
+// Due returns the subscribers of the frequency with an email address who
+// didn't get a digest during the last period.
+func (m *sqlDigestRepository) Due(frequency string, period time.Duration) ([]*models.DigestSettings, error) {
+	stmt := `SELECT users.id, users.username, users.email, frequency, categories
+	FROM digest_settings JOIN users ON digest_settings.userid = users.id
+	WHERE frequency = ? AND users.email != ''
+	AND NOT EXISTS (SELECT true FROM digests WHERE digests.userid = users.id AND digests.created > datetime('now', ?))
+	ORDER BY users.id`
+
+	rows, err := m.Conn.Query(stmt, frequency, window(period))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	due := []*models.DigestSettings{}
+
+	for rows.Next() {
+		s := &models.DigestSettings{}
+		var categories string
+
+		err = rows.Scan(&s.UserID, &s.Name, &s.Email, &s.Frequency, &categories)
+		if err != nil {
+			return nil, err
+		}
+		s.Categories = strings.Fields(categories)
+
+		due = append(due, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// NewPosts returns the posts published during the period in any of the
+// categories, in every category when none is given, newest first.
+func (m *sqlDigestRepository) NewPosts(categories []string, period time.Duration, limit int) ([]*models.Post, error) {
+	clause := ``
+	args := []any{window(period)}
+	if len(categories) > 0 {
+		clause = ` AND id IN (SELECT postid FROM categories WHERE category IN (?` + strings.Repeat(`, ?`, len(categories)-1) + `))`
+		for _, category := range categories {
+			args = append(args, category)
+		}
+	}
+
+	stmt := `SELECT id, title, content, created, author, likes, dislikes, tags FROM posts
+	WHERE id NOT IN (SELECT postid FROM hidden_posts) AND created > datetime('now', ?)` + clause + `
+	ORDER BY created DESC, id DESC LIMIT ?`
+
+	return m.posts(stmt, append(args, limit)...)
+}
+
+// TopPosts returns the most liked posts published during the period.
+func (m *sqlDigestRepository) TopPosts(period time.Duration, limit int) ([]*models.Post, error) {
+	stmt := `SELECT id, title, content, created, author, likes, dislikes, tags FROM posts
+	WHERE id NOT IN (SELECT postid FROM hidden_posts) AND created > datetime('now', ?) AND likes > 0
+	ORDER BY likes DESC, id DESC LIMIT ?`
+
+	return m.posts(stmt, window(period), limit)
+}
+
+func (m *sqlDigestRepository) posts(stmt string, args ...any) ([]*models.Post, error) {
+	rows, err := m.Conn.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []*models.Post{}
+
+	for rows.Next() {
+		p := &models.Post{}
+
+		err = rows.Scan(&p.ID, &p.Title, &p.Content, &p.Created, &p.Author, &p.Likes, &p.Dislikes, &p.Tags)
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// Replies returns the published comments written by others on the posts of
+// the user during the period, newest first.
+func (m *sqlDigestRepository) Replies(user int, period time.Duration, limit int) ([]*models.DigestReply, error) {
+	stmt := `SELECT posts.id, posts.title, users.username, comments.comment, comment_created.created
+	FROM comments JOIN posts ON comments.postid = posts.id
+	JOIN comment_created ON comments.id = comment_created.commentid
+	JOIN users ON comments.commentby = users.id
+	WHERE posts.author = ? AND comments.commentby != ? AND comment_created.created > datetime('now', ?)
+	AND comments.id NOT IN (SELECT commentid FROM pending_comments)
+	ORDER BY comments.id DESC LIMIT ?`
+
+	rows, err := m.Conn.Query(stmt, user, user, window(period), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	replies := []*models.DigestReply{}
+
+	for rows.Next() {
+		r := &models.DigestReply{}
+
+		err = rows.Scan(&r.PostID, &r.PostTitle, &r.Author, &r.Comment, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		replies = append(replies, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+func (m *sqlDigestRepository) Record(r models.DigestRecord) error {
+	stmt := `INSERT INTO digests (userid, frequency, posts, replies, top_posts, status, created)
+	VALUES (?, ?, ?, ?, ?, ?, datetime('now'))`
+
+	_, err := m.Conn.Exec(stmt, r.UserID, r.Frequency, r.Posts, r.Replies, r.TopPosts, r.Status)
+	return err
+}
+
+// History returns the latest digests of the user, newest first.
+func (m *sqlDigestRepository) History(user int, limit int) ([]*models.DigestRecord, error) {
+	stmt := `SELECT id, userid, frequency, posts, replies, top_posts, status, created FROM digests
+	WHERE userid = ? ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.Conn.Query(stmt, user, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	records := []*models.DigestRecord{}
+
+	for rows.Next() {
+		r := &models.DigestRecord{}
+
+		err = rows.Scan(&r.ID, &r.UserID, &r.Frequency, &r.Posts, &r.Replies, &r.TopPosts, &r.Status, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"forum.bbilisbe/internal/models"
+)
+
+const draftColumns = `id, title, content, categories, created, updated`
+
+func scanDraft(row interface{ Scan(...any) error }) (*models.Draft, error) {
+	d := &models.Draft{}
+	var categories string
+
+	err := row.Scan(&d.ID, &d.Title, &d.Content, &categories, &d.Created, &d.Updated)
+	if err != nil {
+		return nil, err
+	}
+	d.Categories = strings.Fields(categories)
+	return d, nil
+}
+
+func (m *sqlPostsRepository) DraftInsert(form models.PostCreateForm, user int) (int, error) {
+	stmt := `INSERT INTO drafts (userid, title, content, categories, created, updated)
+	VALUES (?, ?, ?, ?, datetime('now'), datetime('now'))`
+
+	result, err := m.Conn.Exec(stmt, user, form.Title, form.Content, strings.Join(form.Categories, " "))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// DraftUpdate saves the form over the draft form.Draft of the user.
+func (m *sqlPostsRepository) DraftUpdate(form models.PostCreateForm, user int) error {
+	stmt := `UPDATE drafts SET title = ?, content = ?, categories = ?, updated = datetime('now')
+	WHERE id = ? AND userid = ?`
+
+	result, err := m.Conn.Exec(stmt, form.Title, form.Content, strings.Join(form.Categories, " "), form.Draft, user)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *sqlPostsRepository) GetDraft(id int, user int) (*models.Draft, error) {
+	stmt := `SELECT ` + draftColumns + ` FROM drafts WHERE id = ? AND userid = ?`
+
+	d, err := scanDraft(m.Conn.QueryRow(stmt, id, user))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// Drafts returns the drafts of the user, the last saved first.
+func (m *sqlPostsRepository) Drafts(user int) ([]*models.Draft, error) {
+	stmt := `SELECT ` + draftColumns + ` FROM drafts WHERE userid = ? ORDER BY updated DESC, id DESC`
+
+	rows, err := m.Conn.Query(stmt, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []*models.Draft{}
+	for rows.Next() {
+		d, err := scanDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+func (m *sqlPostsRepository) DraftCount(user int) (int, error) {
+	stmt := `SELECT COUNT(*) FROM drafts WHERE userid = ?`
+
+	var n int
+	err := m.Conn.QueryRow(stmt, user).Scan(&n)
+	return n, err
+}
+
+func (m *sqlPostsRepository) DeleteDraft(id int, user int) error {
+	stmt := `DELETE FROM drafts WHERE id = ? AND userid = ?`
+
+	result, err := m.Conn.Exec(stmt, id, user)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
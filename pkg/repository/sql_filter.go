@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+type sqlFilterRepository struct {
+	Conn *sql.DB
+}
+
+func NewSqlFilterRepository(conn *sql.DB) models.FilterRepository {
+	return &sqlFilterRepository{conn}
+}
+
+func (m *sqlFilterRepository) Words() ([]*models.FilterWord, error) {
+	stmt := `SELECT id, pattern, regex, replacement, score FROM filter_words ORDER BY id`
+
+	rows, err := m.Conn.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	words := []*models.FilterWord{}
+
+	for rows.Next() {
+		w := &models.FilterWord{}
+
+		err = rows.Scan(&w.ID, &w.Pattern, &w.Regex, &w.Replacement, &w.Score)
+		if err != nil {
+			return nil, err
+		}
+
+		words = append(words, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+func (m *sqlFilterRepository) WordInsert(w models.FilterWord) error {
+	stmt := `INSERT INTO filter_words (pattern, regex, replacement, score) VALUES (?, ?, ?, ?)`
+
+	_, err := m.Conn.Exec(stmt, w.Pattern, w.Regex, w.Replacement, w.Score)
+	return err
+}
+
+func (m *sqlFilterRepository) WordDelete(id int) error {
+	stmt := `DELETE FROM filter_words WHERE id = ?`
+
+	result, err := m.Conn.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Recent returns the posts created within the window and the latest comments,
+// which have no creation time.
+func (m *sqlFilterRepository) Recent(window time.Duration) ([]string, error) {
+	stmt := `SELECT content FROM posts WHERE created > datetime('now', ?)
+	UNION ALL
+	SELECT * FROM (SELECT comment FROM comments ORDER BY id DESC LIMIT 100)`
+
+	rows, err := m.Conn.Query(stmt, fmt.Sprintf("-%d seconds", int(window.Seconds())))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	texts := []string{}
+
+	for rows.Next() {
+		var text string
+
+		if err = rows.Scan(&text); err != nil {
+			return nil, err
+		}
+
+		texts = append(texts, text)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return texts, nil
+}
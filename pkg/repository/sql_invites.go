@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (m *sqlUserRepository) InviteInsert(code string, createdBy int, maxUses int, ttl time.Duration) (int, error) {
+	stmt := `INSERT INTO invites (code, createdby, max_uses, uses, expiry, revoked, created)
+	VALUES (?, ?, ?, 0, datetime('now', ?), false, datetime('now'))`
+
+	result, err := m.Conn.Exec(stmt, code, createdBy, maxUses, fmt.Sprintf("+%d seconds", int(ttl.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+const inviteColumns = `invites.id, invites.code, invites.createdby, users.username, invites.max_uses,
+	invites.uses, invites.expiry, invites.revoked, invites.created
+	FROM invites JOIN users ON invites.createdby = users.id`
+
+func scanInvite(row interface{ Scan(...any) error }) (*models.Invite, error) {
+	i := &models.Invite{}
+	err := row.Scan(&i.ID, &i.Code, &i.CreatedBy, &i.Creator, &i.MaxUses, &i.Uses, &i.Expiry, &i.Revoked, &i.Created)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (m *sqlUserRepository) GetInvite(id int) (*models.Invite, error) {
+	stmt := `SELECT ` + inviteColumns + ` WHERE invites.id = ?`
+
+	i, err := scanInvite(m.Conn.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return i, nil
+}
+
+func (m *sqlUserRepository) GetUserInvites(user int) ([]*models.Invite, error) {
+	return m.queryInvites(`SELECT `+inviteColumns+` WHERE createdby = ? ORDER BY invites.id DESC`, user)
+}
+
+func (m *sqlUserRepository) GetInvites() ([]*models.Invite, error) {
+	return m.queryInvites(`SELECT ` + inviteColumns + ` ORDER BY invites.id DESC`)
+}
+
+func (m *sqlUserRepository) queryInvites(stmt string, args ...any) ([]*models.Invite, error) {
+	rows, err := m.Conn.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invites := []*models.Invite{}
+
+	for rows.Next() {
+		i, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		invites = append(invites, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (m *sqlUserRepository) RevokeInvite(id int) error {
+	stmt := `UPDATE invites SET revoked = true WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, id)
+	return err
+}
+
+// InviteSignup signs the user up with the invite code in one transaction:
+// it takes one use of the invite, stores the user and records who invited
+// them. It fails with ErrInvalidInvite when the code is unknown, revoked,
+// expired or used up, and returns the new user's id.
+func (m *sqlUserRepository) InviteSignup(code, username, email, password string) (int, error) {
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE invites SET uses = uses + 1
+	WHERE code = ? AND NOT revoked AND uses < max_uses AND expiry > datetime('now')`
+
+	result, err := tx.Exec(stmt, code)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, models.ErrInvalidInvite
+	}
+
+	var invite, invitedBy int
+	err = tx.QueryRow(`SELECT id, createdby FROM invites WHERE code = ?`, code).Scan(&invite, &invitedBy)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := insertUser(tx, username, email, hashedPwd)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt2 := `INSERT INTO invited_users (userid, inviteid, invitedby) VALUES (?, ?, ?)`
+	_, err = tx.Exec(stmt2, id, invite, invitedBy)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (m *sqlUserRepository) GetInvitedUsers() ([]*models.InvitedUser, error) {
+	stmt := `SELECT userid, users.username, invitedby, invites.code FROM invited_users
+	JOIN users ON invited_users.userid = users.id
+	JOIN invites ON invited_users.inviteid = invites.id
+	ORDER BY userid`
+
+	rows, err := m.Conn.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []*models.InvitedUser{}
+
+	for rows.Next() {
+		u := &models.InvitedUser{}
+
+		err = rows.Scan(&u.UserID, &u.Name, &u.InvitedBy, &u.Code)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+type sqlJobRepository struct {
+	Conn *sql.DB
+}
+
+func NewSqlJobRepository(conn *sql.DB) models.JobRepository {
+	return &sqlJobRepository{conn}
+}
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, error, run_at, created, started, finished`
+
+func scanJob(row interface{ Scan(...any) error }) (*models.Job, error) {
+	j := &models.Job{}
+	var payload string
+	var started, finished sql.NullTime
+
+	err := row.Scan(&j.ID, &j.Kind, &payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.Error, &j.RunAt, &j.Created, &started, &finished)
+	if err != nil {
+		return nil, err
+	}
+	j.Payload = []byte(payload)
+	if started.Valid {
+		j.Started = &started.Time
+	}
+	if finished.Valid {
+		j.Finished = &finished.Time
+	}
+	return j, nil
+}
+
+func (m *sqlJobRepository) jobs(stmt string, args ...any) ([]*models.Job, error) {
+	rows, err := m.Conn.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := []*models.Job{}
+
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Insert queues a job of the kind to run after the delay.
+func (m *sqlJobRepository) Insert(kind string, payload []byte, delay time.Duration, maxAttempts int) (int, error) {
+	stmt := `INSERT INTO jobs (kind, payload, status, max_attempts, run_at, created)
+	VALUES (?, ?, ?, ?, datetime('now', ?), datetime('now'))`
+
+	result, err := m.Conn.Exec(stmt, kind, string(payload), models.JobPending, maxAttempts, fmt.Sprintf("+%d seconds", int(delay.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// Claim marks up to limit due jobs of the kind as running and returns them,
+// the oldest first.
+func (m *sqlJobRepository) Claim(kind string, limit int) ([]*models.Job, error) {
+	stmt := `UPDATE jobs SET status = ?, attempts = attempts + 1, started = datetime('now')
+	WHERE id IN (SELECT id FROM jobs WHERE kind = ? AND status = ? AND run_at <= datetime('now')
+		ORDER BY run_at, id LIMIT ?)
+	RETURNING ` + jobColumns
+
+	return m.jobs(stmt, models.JobRunning, kind, models.JobPending, limit)
+}
+
+// Active reports whether a job of the kind is pending or running.
+func (m *sqlJobRepository) Active(kind string) (bool, error) {
+	var active bool
+
+	stmt := `SELECT EXISTS (SELECT true FROM jobs WHERE kind = ? AND status IN (?, ?))`
+
+	err := m.Conn.QueryRow(stmt, kind, models.JobPending, models.JobRunning).Scan(&active)
+	return active, err
+}
+
+func (m *sqlJobRepository) Done(id int) error {
+	stmt := `UPDATE jobs SET status = ?, error = '', finished = datetime('now') WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, models.JobDone, id)
+	return err
+}
+
+// Retry puts the job back in the queue to run after the wait.
+func (m *sqlJobRepository) Retry(id int, message string, wait time.Duration) error {
+	stmt := `UPDATE jobs SET status = ?, error = ?, run_at = datetime('now', ?) WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, models.JobPending, message, fmt.Sprintf("+%d seconds", int(wait.Seconds())), id)
+	return err
+}
+
+func (m *sqlJobRepository) Failed(id int, message string) error {
+	stmt := `UPDATE jobs SET status = ?, error = ?, finished = datetime('now') WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, models.JobFailed, message, id)
+	return err
+}
+
+// Requeue puts the jobs left running by a previous process back in the queue.
+// Jobs don't record the process running them, so the jobs of any other
+// process running at the same time are queued again too.
+func (m *sqlJobRepository) Requeue() error {
+	stmt := `UPDATE jobs SET status = ? WHERE status = ?`
+
+	_, err := m.Conn.Exec(stmt, models.JobPending, models.JobRunning)
+	return err
+}
+
+// Reset queues a failed job again with all its attempts. It returns
+// ErrNoRecord when there is no such failed job.
+func (m *sqlJobRepository) Reset(id int) error {
+	stmt := `UPDATE jobs SET status = ?, attempts = 0, error = '', run_at = datetime('now'), finished = NULL
+	WHERE id = ? AND status = ?`
+
+	result, err := m.Conn.Exec(stmt, models.JobPending, id, models.JobFailed)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// List returns the latest jobs with the status, of any status when it is
+// empty.
+func (m *sqlJobRepository) List(status string, limit int) ([]*models.Job, error) {
+	if status == "" {
+		return m.jobs(`SELECT `+jobColumns+` FROM jobs ORDER BY id DESC LIMIT ?`, limit)
+	}
+	return m.jobs(`SELECT `+jobColumns+` FROM jobs WHERE status = ? ORDER BY id DESC LIMIT ?`, status, limit)
+}
+
+func (m *sqlJobRepository) Counts() (map[string]int, error) {
+	stmt := `SELECT status, COUNT(*) FROM jobs GROUP BY status`
+
+	rows, err := m.Conn.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := map[string]int{}
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err = rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Prune deletes the jobs with the status finished longer than age ago.
+func (m *sqlJobRepository) Prune(status string, age time.Duration) error {
+	stmt := `DELETE FROM jobs WHERE status = ? AND finished < datetime('now', ?)`
+
+	_, err := m.Conn.Exec(stmt, status, fmt.Sprintf("-%d seconds", int(age.Seconds())))
+	return err
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"database/sql"
+
+	"forum.bbilisbe/internal/models"
+)
+
+type sqlNotificationRepository struct {
+	Conn *sql.DB
+}
+
+func NewSqlNotificationRepository(conn *sql.DB) models.NotificationRepository {
+	return &sqlNotificationRepository{conn}
+}
+
+// Insert adds the notification unless the user already has the same one, so
+// taking a like back and giving it again doesn't notify twice.
+func (m *sqlNotificationRepository) Insert(n models.Notification) error {
+	stmt := `INSERT INTO notifications (userid, type, actorid, postid, commentid, read, created)
+	SELECT ?, ?, ?, ?, ?, false, datetime('now')
+	WHERE NOT EXISTS (SELECT true FROM notifications
+		WHERE userid = ? AND type = ? AND actorid = ? AND postid = ? AND commentid = ?)`
+
+	_, err := m.Conn.Exec(stmt, n.UserID, n.Type, n.ActorID, n.PostID, n.CommentID,
+		n.UserID, n.Type, n.ActorID, n.PostID, n.CommentID)
+	return err
+}
+
+// List returns the latest notifications of the user, newest first.
+func (m *sqlNotificationRepository) List(user int, limit int) ([]*models.Notification, error) {
+	stmt := `SELECT notifications.id, userid, type, actorid, COALESCE(users.username, ''), postid,
+	COALESCE(posts.title, ''), commentid, read, notifications.created
+	FROM notifications LEFT JOIN users ON notifications.actorid = users.id
+	LEFT JOIN posts ON notifications.postid = posts.id
+	WHERE userid = ? ORDER BY notifications.id DESC LIMIT ?`
+
+	rows, err := m.Conn.Query(stmt, user, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+
+	for rows.Next() {
+		n := &models.Notification{}
+
+		err = rows.Scan(&n.ID, &n.UserID, &n.Type, &n.ActorID, &n.Actor, &n.PostID, &n.PostTitle, &n.CommentID, &n.Read, &n.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (m *sqlNotificationRepository) Unread(user int) (int, error) {
+	var count int
+
+	stmt := `SELECT COUNT(*) FROM notifications WHERE userid = ? AND NOT read`
+
+	err := m.Conn.QueryRow(stmt, user).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks the notification as read. It returns ErrNoRecord when the
+// notification doesn't belong to the user.
+func (m *sqlNotificationRepository) MarkRead(user int, id int) error {
+	stmt := `UPDATE notifications SET read = true WHERE id = ? AND userid = ?`
+
+	result, err := m.Conn.Exec(stmt, id, user)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *sqlNotificationRepository) MarkAllRead(user int) error {
+	stmt := `UPDATE notifications SET read = true WHERE userid = ? AND NOT read`
+
+	_, err := m.Conn.Exec(stmt, user)
+	return err
+}
+
+// Disabled returns the types of notifications the user turned off.
+func (m *sqlNotificationRepository) Disabled(user int) ([]string, error) {
+	stmt := `SELECT type FROM disabled_notifications WHERE userid = ?`
+
+	rows, err := m.Conn.Query(stmt, user)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	types := []string{}
+
+	for rows.Next() {
+		var typ string
+		if err = rows.Scan(&typ); err != nil {
+			return nil, err
+		}
+		types = append(types, typ)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// SetDisabled replaces the types of notifications the user turned off.
+func (m *sqlNotificationRepository) SetDisabled(user int, types []string) error {
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM disabled_notifications WHERE userid = ?`, user)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, typ := range types {
+		_, err = tx.Exec(`INSERT INTO disabled_notifications (userid, type) VALUES (?, ?)`, user, typ)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
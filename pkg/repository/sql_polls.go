@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// insertPoll adds the poll to the post being created.
+func insertPoll(tx *sql.Tx, postid int, form models.PollForm) error {
+	var closes any
+	if form.Closes != nil {
+		closes = form.Closes.UTC().Format("2006-01-02 15:04:05")
+	}
+	_, err := tx.Exec(`INSERT INTO polls (postid, multiple, closes, hide_results) VALUES (?, ?, ?, ?)`,
+		postid, form.Multiple, closes, form.HideResults)
+	if err != nil {
+		return err
+	}
+	for _, option := range form.Options {
+		_, err = tx.Exec(`INSERT INTO poll_options (postid, text) VALUES (?, ?)`, postid, option)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPoll returns the poll of the post with the votes of every option, in the
+// order they were written.
+func (m *sqlPostsRepository) GetPoll(postid int) (*models.Poll, error) {
+	stmt := `SELECT multiple, closes, hide_results,
+	(SELECT COUNT(DISTINCT userid) FROM poll_votes WHERE postid = polls.postid)
+	FROM polls WHERE postid = ?`
+
+	p := &models.Poll{PostID: postid}
+	err := m.Conn.QueryRow(stmt, postid).Scan(&p.Multiple, &p.Closes, &p.HideResults, &p.Voters)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	stmt = `SELECT id, text, (SELECT COUNT(*) FROM poll_votes WHERE optionid = poll_options.id)
+	FROM poll_options WHERE postid = ? ORDER BY id`
+
+	rows, err := m.Conn.Query(stmt, postid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		o := &models.PollOption{}
+		if err = rows.Scan(&o.ID, &o.Text, &o.Votes); err != nil {
+			return nil, err
+		}
+		p.Options = append(p.Options, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PollVotes returns the options of the poll chosen by the user.
+func (m *sqlPostsRepository) PollVotes(postid int, user int) ([]int, error) {
+	stmt := `SELECT optionid FROM poll_votes WHERE postid = ? AND userid = ? ORDER BY optionid`
+
+	rows, err := m.Conn.Query(stmt, postid, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		options = append(options, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// PollVote replaces the vote of the user with the options.
+func (m *sqlPostsRepository) PollVote(postid int, user int, options []int) error {
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM poll_votes WHERE postid = ? AND userid = ?`, postid, user)
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
+		_, err = tx.Exec(`INSERT INTO poll_votes (postid, userid, optionid) VALUES (?, ?, ?)`, postid, user, option)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
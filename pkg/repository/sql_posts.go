@@ -16,24 +16,59 @@ func NewSqlPostsRepository(conn *sql.DB) models.PostRepository {
 	return &sqlPostsRepository{conn}
 }
 
-func (m *sqlPostsRepository) Insert(data models.PostCreateForm, author int) (int, error) {
+// Insert creates the post with its categories, attachments, poll and schedule
+// in one transaction. A held post is added to the moderation queue with it.
+func (m *sqlPostsRepository) Insert(data models.PostCreateForm, author int, held bool) (int, error) {
 	stmt := `INSERT INTO posts (title, content, created, author, likes, dislikes, tags, image)
 	VALUES(?, ?, datetime('now', 'utc'), ?, "0", "0", ?, ?);`
 
-	result, err := m.Conn.Exec(stmt, data.Title, data.Content, author, strings.Join(data.Categories, " "), data.ImageURL)
+	tx, err := m.Conn.Begin()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
-	id, err := result.LastInsertId()
+	result, err := tx.Exec(stmt, data.Title, data.Content, author, strings.Join(data.Categories, " "), data.ImageURL)
 	if err != nil {
 		return 0, err
 	}
-	err = m.CategoryInsert(id, data.Categories)
+	lastID, err := result.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
-	return int(id), nil
+	id := int(lastID)
+
+	for _, category := range data.Categories {
+		_, err = tx.Exec(`INSERT INTO categories (postid, category) VALUES (?, ?)`, id, category)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err = insertAttachments(tx, id, data.Attachments); err != nil {
+		return 0, err
+	}
+	if data.Poll != nil {
+		if err = insertPoll(tx, id, *data.Poll); err != nil {
+			return 0, err
+		}
+	}
+	if data.PublishAt != nil {
+		_, err = tx.Exec(scheduleStmt, id, data.PublishAt.UTC().Format("2006-01-02 15:04:05"))
+		if err != nil {
+			return 0, err
+		}
+	}
+	if held {
+		_, err = tx.Exec(`INSERT INTO pending_posts (postid, created) VALUES (?, datetime('now', 'utc'))`, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
 func (m *sqlPostsRepository) CategoryInsert(postid int64, categories []string) error {
@@ -52,9 +87,11 @@ func (m *sqlPostsRepository) Get(id int) (*models.Post, error) {
 	p := &models.Post{}
 	var image sql.NullString
 
-	stmt := `SELECT id, title, content, created, author, likes, dislikes, tags, image FROM posts WHERE id = ?`
+	stmt := `SELECT id, title, content, created, author, likes, dislikes, tags, image,
+	EXISTS (SELECT true FROM pending_posts WHERE postid = posts.id), scheduled_posts.publish_at
+	FROM posts LEFT JOIN scheduled_posts ON posts.id = scheduled_posts.postid WHERE id = ?`
 
-	err := m.Conn.QueryRow(stmt, id).Scan(&p.ID, &p.Title, &p.Content, &p.Created, &p.Author, &p.Likes, &p.Dislikes, &p.Tags, &image)
+	err := m.Conn.QueryRow(stmt, id).Scan(&p.ID, &p.Title, &p.Content, &p.Created, &p.Author, &p.Likes, &p.Dislikes, &p.Tags, &image, &p.Pending, &p.PublishAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
@@ -73,7 +110,10 @@ func (m *sqlPostsRepository) Get(id int) (*models.Post, error) {
 // This will return the 10 most recently created posts.
 
 func (m *sqlPostsRepository) Latest() (map[int]*models.Post, error) {
-	stmt := `SELECT id, title, author, likes FROM posts ORDER BY id DESC LIMIT 10`
+	stmt := `SELECT id, title, author, likes, COALESCE(post_thumbnails.thumbnail, '')
+	FROM posts LEFT JOIN post_thumbnails ON posts.id = post_thumbnails.postid
+	WHERE id NOT IN (SELECT postid FROM hidden_posts)
+	ORDER BY created DESC, id DESC LIMIT 10`
 
 	rows, err := m.Conn.Query(stmt)
 	if err != nil {
@@ -87,7 +127,7 @@ func (m *sqlPostsRepository) Latest() (map[int]*models.Post, error) {
 	for rows.Next() {
 		p := &models.Post{}
 
-		err = rows.Scan(&p.ID, &p.Title, &p.Author, &p.Likes)
+		err = rows.Scan(&p.ID, &p.Title, &p.Author, &p.Likes, &p.Thumbnail)
 		if err != nil {
 			return nil, err
 		}
@@ -103,7 +143,10 @@ func (m *sqlPostsRepository) Latest() (map[int]*models.Post, error) {
 
 func (m *sqlPostsRepository) FilteredPosts(categories []string) (map[int]*models.Post, error) {
 	posts := map[int]*models.Post{}
-	stmt := `SELECT id, title, author, likes, tags FROM posts JOIN categories ON posts.id = categories.postid WHERE categories.category = ?;`
+	stmt := `SELECT id, title, author, likes, tags, COALESCE(post_thumbnails.thumbnail, '')
+	FROM posts JOIN categories ON posts.id = categories.postid
+	LEFT JOIN post_thumbnails ON posts.id = post_thumbnails.postid
+	WHERE categories.category = ? AND id NOT IN (SELECT postid FROM hidden_posts);`
 
 	for _, category := range categories {
 		rows, err := m.Conn.Query(stmt, category)
@@ -114,7 +157,7 @@ func (m *sqlPostsRepository) FilteredPosts(categories []string) (map[int]*models
 		for rows.Next() {
 			p := &models.Post{}
 
-			err := rows.Scan(&p.ID, &p.Title, &p.Author, &p.Likes, &p.Tags)
+			err := rows.Scan(&p.ID, &p.Title, &p.Author, &p.Likes, &p.Tags, &p.Thumbnail)
 			if err != nil {
 				return nil, err
 			}
@@ -259,20 +302,35 @@ func (m *sqlPostsRepository) IsDislikedByUser(user int, postid int) bool {
 	return exists
 }
 
-func (m *sqlPostsRepository) CommentInsert(comment string, commentBy int, postId int) error {
+func (m *sqlPostsRepository) CommentInsert(comment string, commentBy int, postId int) (int, error) {
 	stmt := `INSERT INTO comments (postid, comment, commentby, likes, dislikes) VALUES(?, ?, ?, '0', '0');`
 
-	_, err := m.Conn.Exec(stmt, postId, comment, commentBy)
+	result, err := m.Conn.Exec(stmt, postId, comment, commentBy)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO comment_created (commentid, created) VALUES (?, datetime('now', 'utc'))`
+	_, err = m.Conn.Exec(stmt, id)
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
 }
 
+// GetComments returns the comments of the post. Held comments are only
+// returned to their author.
 func (m *sqlPostsRepository) GetComments(postId int, user int) ([]*models.PostComments, error) {
-	stmt := `SELECT id, comment, commentby, likes, dislikes FROM comments WHERE postid = ?;`
-	rows, err := m.Conn.Query(stmt, postId)
+	stmt := `SELECT id, postid, comment, commentby, likes, dislikes, pending_comments.commentid IS NOT NULL, comment_created.created
+	FROM comments LEFT JOIN pending_comments ON comments.id = pending_comments.commentid
+	LEFT JOIN comment_created ON comments.id = comment_created.commentid
+	WHERE postid = ? AND (pending_comments.commentid IS NULL OR commentby = ?);`
+	rows, err := m.Conn.Query(stmt, postId, user)
 	if err != nil {
 		return nil, err
 	}
@@ -285,11 +343,16 @@ func (m *sqlPostsRepository) GetComments(postId int, user int) ([]*models.PostCo
 
 		c := &models.PostComments{}
 
-		err = rows.Scan(&c.Id, &c.Comment, &c.Author, &c.Likes, &c.Dislikes)
+		var created sql.NullTime
+
+		err = rows.Scan(&c.Id, &c.PostID, &c.Comment, &c.Author, &c.Likes, &c.Dislikes, &c.Pending, &created)
 
 		if err != nil {
 			return nil, err
 		}
+		if created.Valid {
+			c.Created = &created.Time
+		}
 		c.IsLiked = m.IsCommentLikedByUser(user, c.Id)
 
 		comments = append(comments, c)
@@ -429,6 +492,499 @@ func (m *sqlPostsRepository) IsCommentDislikedByUser(user int, commentid int) bo
 	return exists
 }
 
+// Approve takes the post out of the moderation queue, failing with
+// ErrNoRecord when it isn't held.
+func (m *sqlPostsRepository) Approve(postid int) error {
+	stmt := `DELETE FROM pending_posts WHERE postid = ?`
+
+	result, err := m.Conn.Exec(stmt, postid)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Delete removes the post together with everything attached to it.
+func (m *sqlPostsRepository) Delete(postid int) error {
+	stmts := []string{
+		`DELETE FROM pending_posts WHERE postid = ?`,
+		`DELETE FROM scheduled_posts WHERE postid = ?`,
+		`DELETE FROM post_reports WHERE postid = ?`,
+		`DELETE FROM notifications WHERE postid = ?`,
+		`DELETE FROM mentions WHERE postid = ?`,
+		`DELETE FROM rendered WHERE postid = ?`,
+		`DELETE FROM post_thumbnails WHERE postid = ?`,
+		`DELETE FROM attachments WHERE postid = ?`,
+		`DELETE FROM poll_votes WHERE postid = ?`,
+		`DELETE FROM poll_options WHERE postid = ?`,
+		`DELETE FROM polls WHERE postid = ?`,
+		`DELETE FROM categories WHERE postid = ?`,
+		`DELETE FROM likes WHERE postid = ?`,
+		`DELETE FROM dislikes WHERE postid = ?`,
+		`DELETE FROM comment_likes WHERE postid = ?`,
+		`DELETE FROM comment_dislikes WHERE postid = ?`,
+		`DELETE FROM pending_comments WHERE commentid IN (SELECT id FROM comments WHERE postid = ?)`,
+		`DELETE FROM comment_created WHERE commentid IN (SELECT id FROM comments WHERE postid = ?)`,
+		`DELETE FROM comments WHERE postid = ?`,
+		`DELETE FROM posts WHERE id = ?`,
+	}
+
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(stmt, postid); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Pending returns the posts waiting in the moderation queue.
+func (m *sqlPostsRepository) Pending() (map[int]*models.Post, error) {
+	stmt := `SELECT id, title, content, posts.created, author, tags FROM posts
+	JOIN pending_posts ON posts.id = pending_posts.postid`
+
+	rows, err := m.Conn.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := map[int]*models.Post{}
+
+	for rows.Next() {
+		p := &models.Post{Pending: true}
+
+		err = rows.Scan(&p.ID, &p.Title, &p.Content, &p.Created, &p.Author, &p.Tags)
+		if err != nil {
+			return nil, err
+		}
+
+		posts[p.ID] = p
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (m *sqlPostsRepository) HoldComment(commentid int) error {
+	stmt := `INSERT INTO pending_comments (commentid, created) VALUES (?, datetime('now', 'utc'))`
+
+	_, err := m.Conn.Exec(stmt, commentid)
+	return err
+}
+
+func (m *sqlPostsRepository) ApproveComment(commentid int) error {
+	stmt := `DELETE FROM pending_comments WHERE commentid = ?`
+
+	result, err := m.Conn.Exec(stmt, commentid)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *sqlPostsRepository) DeleteComment(commentid int) error {
+	stmts := []string{
+		`DELETE FROM pending_comments WHERE commentid = ?`,
+		`DELETE FROM comment_likes WHERE commentid = ?`,
+		`DELETE FROM comment_dislikes WHERE commentid = ?`,
+		`DELETE FROM comment_created WHERE commentid = ?`,
+		`DELETE FROM notifications WHERE commentid = ?`,
+		`DELETE FROM mentions WHERE commentid = ?`,
+		`DELETE FROM rendered WHERE commentid = ?`,
+		`DELETE FROM comments WHERE id = ?`,
+	}
+
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(stmt, commentid); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PendingComments returns the comments waiting in the moderation queue.
+func (m *sqlPostsRepository) PendingComments() ([]*models.PostComments, error) {
+	stmt := `SELECT id, postid, comment, commentby FROM comments
+	JOIN pending_comments ON comments.id = pending_comments.commentid ORDER BY id`
+
+	rows, err := m.Conn.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []*models.PostComments{}
+
+	for rows.Next() {
+		c := &models.PostComments{Pending: true}
+
+		err = rows.Scan(&c.Id, &c.PostID, &c.Comment, &c.Author)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// filterClause builds the WHERE clause selecting the published posts matching
+// the filter.
+func filterClause(f models.PostFilter) (string, []any) {
+	clause := `WHERE id NOT IN (SELECT postid FROM hidden_posts)`
+	args := []any{}
+
+	for _, category := range f.Categories {
+		clause += ` AND id IN (SELECT postid FROM categories WHERE category = ?)`
+		args = append(args, category)
+	}
+	if f.Author != 0 {
+		clause += ` AND author = ?`
+		args = append(args, f.Author)
+	}
+	return clause, args
+}
+
+func (m *sqlPostsRepository) List(f models.PostFilter) ([]*models.Post, error) {
+	clause, args := filterClause(f)
+	stmt := `SELECT id, title, content, created, author, likes, dislikes, tags, image FROM posts ` +
+		clause + ` ORDER BY created DESC, id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.Conn.Query(stmt, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []*models.Post{}
+
+	for rows.Next() {
+		p := &models.Post{}
+		var image sql.NullString
+
+		err = rows.Scan(&p.ID, &p.Title, &p.Content, &p.Created, &p.Author, &p.Likes, &p.Dislikes, &p.Tags, &image)
+		if err != nil {
+			return nil, err
+		}
+		p.Image = image.String
+
+		posts = append(posts, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (m *sqlPostsRepository) Count(f models.PostFilter) (int, error) {
+	clause, args := filterClause(f)
+	stmt := `SELECT COUNT(*) FROM posts ` + clause
+
+	var count int
+	err := m.Conn.QueryRow(stmt, args...).Scan(&count)
+	return count, err
+}
+
+func (m *sqlPostsRepository) GetComment(id int) (*models.PostComments, error) {
+	c := &models.PostComments{}
+
+	stmt := `SELECT id, postid, comment, commentby, likes, dislikes,
+	EXISTS (SELECT true FROM pending_comments WHERE commentid = comments.id), comment_created.created
+	FROM comments LEFT JOIN comment_created ON comments.id = comment_created.commentid WHERE id = ?`
+
+	var created sql.NullTime
+
+	err := m.Conn.QueryRow(stmt, id).Scan(&c.Id, &c.PostID, &c.Comment, &c.Author, &c.Likes, &c.Dislikes, &c.Pending, &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	if created.Valid {
+		c.Created = &created.Time
+	}
+	return c, nil
+}
+
+// ReportInsert records the report of the post by the user. It returns false
+// when the user already reported the post.
+func (m *sqlPostsRepository) ReportInsert(postid int, user int, reason string) (bool, error) {
+	stmt := `INSERT OR IGNORE INTO post_reports (postid, userid, reason, created) VALUES (?, ?, ?, datetime('now'))`
+
+	result, err := m.Conn.Exec(stmt, postid, user, reason)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Reports returns the reported posts, the most recently reported first.
+func (m *sqlPostsRepository) Reports() ([]*models.Report, error) {
+	stmt := `SELECT postid, title, COUNT(*), group_concat(reason, '; ') FROM post_reports
+	JOIN posts ON posts.id = post_reports.postid GROUP BY postid ORDER BY MAX(post_reports.id) DESC`
+
+	rows, err := m.Conn.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reports := []*models.Report{}
+
+	for rows.Next() {
+		r := &models.Report{}
+
+		err = rows.Scan(&r.PostID, &r.Title, &r.Count, &r.Reasons)
+		if err != nil {
+			return nil, err
+		}
+
+		reports = append(reports, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (m *sqlPostsRepository) DeleteReports(postid int) error {
+	stmt := `DELETE FROM post_reports WHERE postid = ?`
+
+	result, err := m.Conn.Exec(stmt, postid)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
 func (m *sqlPostsRepository) GetPostId() {
 	return
 }
+
+// Commenters returns the users with a published comment on the post.
+func (m *sqlPostsRepository) Commenters(postid int) ([]int, error) {
+	stmt := `SELECT DISTINCT commentby FROM comments
+	WHERE postid = ? AND id NOT IN (SELECT commentid FROM pending_comments)`
+
+	rows, err := m.Conn.Query(stmt, postid)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []int{}
+
+	for rows.Next() {
+		var user int
+		if err = rows.Scan(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// MentionInsert records the mentions of existing users in the post, or in its
+// comment when commentid isn't 0. Unknown names are ignored.
+func (m *sqlPostsRepository) MentionInsert(postid int, commentid int, names []string) error {
+	stmt := `INSERT OR IGNORE INTO mentions (postid, commentid, userid)
+	SELECT ?, ?, id FROM users WHERE username = ?`
+
+	for _, name := range names {
+		_, err := m.Conn.Exec(stmt, postid, commentid, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mentions returns the mentioned usernames of the post and its comments keyed
+// by the comment id, 0 for the post itself.
+func (m *sqlPostsRepository) Mentions(postid int) (map[int][]string, error) {
+	stmt := `SELECT commentid, username FROM mentions JOIN users ON mentions.userid = users.id
+	WHERE postid = ?`
+
+	rows, err := m.Conn.Query(stmt, postid)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	mentions := map[int][]string{}
+
+	for rows.Next() {
+		var commentid int
+		var name string
+		if err = rows.Scan(&commentid, &name); err != nil {
+			return nil, err
+		}
+		mentions[commentid] = append(mentions[commentid], name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+// Mentioned returns the users mentioned in the post, or in its comment when
+// commentid isn't 0.
+func (m *sqlPostsRepository) Mentioned(postid int, commentid int) ([]int, error) {
+	stmt := `SELECT userid FROM mentions WHERE postid = ? AND commentid = ?`
+
+	rows, err := m.Conn.Query(stmt, postid, commentid)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []int{}
+
+	for rows.Next() {
+		var user int
+		if err = rows.Scan(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// RenderedInsert stores the HTML rendered from the Markdown of the post, or of
+// its comment when commentid isn't 0, by the given version of the renderer.
+func (m *sqlPostsRepository) RenderedInsert(postid int, commentid int, version int, html string) error {
+	stmt := `INSERT OR REPLACE INTO rendered (postid, commentid, version, html) VALUES (?, ?, ?, ?)`
+
+	_, err := m.Conn.Exec(stmt, postid, commentid, version, html)
+	return err
+}
+
+// Rendered returns the stored HTML of the post and its comments made by the
+// version of the renderer, keyed by the comment id, 0 for the post itself.
+func (m *sqlPostsRepository) Rendered(postid int, version int) (map[int]string, error) {
+	stmt := `SELECT commentid, html FROM rendered WHERE postid = ? AND version = ?`
+
+	rows, err := m.Conn.Query(stmt, postid, version)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	rendered := map[int]string{}
+
+	for rows.Next() {
+		var commentid int
+		var html string
+		if err = rows.Scan(&commentid, &html); err != nil {
+			return nil, err
+		}
+		rendered[commentid] = html
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+func (m *sqlPostsRepository) ThumbnailInsert(postid int, thumbnail string) error {
+	stmt := `INSERT OR REPLACE INTO post_thumbnails (postid, thumbnail) VALUES (?, ?)`
+
+	_, err := m.Conn.Exec(stmt, postid, thumbnail)
+	return err
+}
+
+// MediaUsed reports whether a post shows the stored file at the URL, as its
+// image, its thumbnail or one of its attachments.
+func (m *sqlPostsRepository) MediaUsed(url string) (bool, error) {
+	var used bool
+
+	stmt := `SELECT EXISTS (SELECT true FROM posts WHERE image = ?)
+		OR EXISTS (SELECT true FROM post_thumbnails WHERE thumbnail = ?)
+		OR EXISTS (SELECT true FROM attachments WHERE url = ? OR thumbnail = ?)`
+
+	err := m.Conn.QueryRow(stmt, url, url, url, url).Scan(&used)
+	return used, err
+}
+
+// ReplaceImage changes the address of an image shown by the posts, as their
+// image or their thumbnail.
+func (m *sqlPostsRepository) ReplaceImage(old, new string) error {
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`UPDATE posts SET image = ? WHERE image = ?`, new, old); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE post_thumbnails SET thumbnail = ? WHERE thumbnail = ?`, new, old); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE attachments SET url = ? WHERE url = ?`, new, old); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
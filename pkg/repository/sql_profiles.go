@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// GetProfile returns the profile of the user with the name. Users who never
+// changed their profile have no bio and show their comments but not their
+// likes.
+func (m *sqlUserRepository) GetProfile(name string) (*models.Profile, error) {
+	p := &models.Profile{}
+
+	stmt := `SELECT users.id, users.username, users.created, COALESCE(profiles.bio, ''),
+		COALESCE(profiles.show_comments, true), COALESCE(profiles.show_likes, false),
+		(SELECT COUNT(*) FROM posts WHERE author = users.id AND id NOT IN (SELECT postid FROM hidden_posts)),
+		(SELECT COUNT(*) FROM comments WHERE commentby = users.id
+			AND id NOT IN (SELECT commentid FROM pending_comments)
+			AND postid NOT IN (SELECT postid FROM hidden_posts)),
+		(SELECT COALESCE(SUM(likes), 0) FROM posts WHERE author = users.id AND id NOT IN (SELECT postid FROM hidden_posts))
+			+ (SELECT COALESCE(SUM(likes), 0) FROM comments WHERE commentby = users.id AND id NOT IN (SELECT commentid FROM pending_comments))
+	FROM users LEFT JOIN profiles ON users.id = profiles.userid
+	WHERE users.username = ?`
+
+	err := m.Conn.QueryRow(stmt, name).Scan(&p.ID, &p.Name, &p.Created, &p.Bio, &p.ShowComments, &p.ShowLikes, &p.Posts, &p.Comments, &p.Likes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (m *sqlUserRepository) profilePosts(stmt string, args ...any) ([]*models.Post, error) {
+	rows, err := m.Conn.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []*models.Post{}
+
+	for rows.Next() {
+		p := &models.Post{}
+
+		err = rows.Scan(&p.ID, &p.Title, &p.Content, &p.Created, &p.Author, &p.Likes, &p.Tags)
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// GetProfilePosts returns the latest published posts of the user.
+func (m *sqlUserRepository) GetProfilePosts(user, limit int) ([]*models.Post, error) {
+	stmt := `SELECT id, title, content, created, author, likes, tags FROM posts
+	WHERE author = ? AND id NOT IN (SELECT postid FROM hidden_posts)
+	ORDER BY created DESC, id DESC LIMIT ?`
+
+	return m.profilePosts(stmt, user, limit)
+}
+
+// GetProfileLikes returns the published posts the user liked, the latest
+// first.
+func (m *sqlUserRepository) GetProfileLikes(user, limit int) ([]*models.Post, error) {
+	stmt := `SELECT id, title, content, created, author, posts.likes, tags FROM posts
+	JOIN likes ON posts.id = likes.postid
+	WHERE likes.likedby = ? AND id NOT IN (SELECT postid FROM hidden_posts)
+	ORDER BY created DESC, id DESC LIMIT ?`
+
+	return m.profilePosts(stmt, user, limit)
+}
+
+// GetProfileComments returns the latest published comments of the user on
+// published posts.
+func (m *sqlUserRepository) GetProfileComments(user, limit int) ([]*models.ProfileComment, error) {
+	stmt := `SELECT comments.id, comments.postid, posts.title, comments.comment, COALESCE(comments.likes, 0), comment_created.created
+	FROM comments JOIN posts ON comments.postid = posts.id
+	LEFT JOIN comment_created ON comments.id = comment_created.commentid
+	WHERE comments.commentby = ?
+		AND comments.id NOT IN (SELECT commentid FROM pending_comments)
+		AND posts.id NOT IN (SELECT postid FROM hidden_posts)
+	ORDER BY comments.id DESC LIMIT ?`
+
+	rows, err := m.Conn.Query(stmt, user, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []*models.ProfileComment{}
+
+	for rows.Next() {
+		c := &models.ProfileComment{}
+		var created sql.NullTime
+
+		err = rows.Scan(&c.ID, &c.PostID, &c.PostTitle, &c.Comment, &c.Likes, &created)
+		if err != nil {
+			return nil, err
+		}
+		if created.Valid {
+			c.Created = &created.Time
+		}
+
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (m *sqlUserRepository) UpdateProfile(user int, form models.ProfileForm) error {
+	stmt := `INSERT INTO profiles (userid, bio, show_comments, show_likes) VALUES (?, ?, ?, ?)
+	ON CONFLICT (userid) DO UPDATE SET bio = excluded.bio, show_comments = excluded.show_comments, show_likes = excluded.show_likes`
+
+	_, err := m.Conn.Exec(stmt, user, form.Bio, form.ShowComments, form.ShowLikes)
+	return err
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+const scheduleStmt = `INSERT OR REPLACE INTO scheduled_posts (postid, publish_at) VALUES (?, ?)`
+
+// Schedule hides the post until the time, when DueScheduled returns it.
+func (m *sqlPostsRepository) Schedule(postid int, at time.Time) error {
+	_, err := m.Conn.Exec(scheduleStmt, postid, at.UTC().Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// Unschedule publishes the scheduled post: the schedule is removed and the
+// post dated now, so it shows up as a new post. Removing the schedule claims
+// the post, of two callers only one gets nil.
+func (m *sqlPostsRepository) Unschedule(postid int) error {
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM scheduled_posts WHERE postid = ?`, postid)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	_, err = tx.Exec(`UPDATE posts SET created = datetime('now', 'utc') WHERE id = ?`, postid)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DueScheduled returns the scheduled posts whose time has come, the earliest
+// first.
+func (m *sqlPostsRepository) DueScheduled() ([]int, error) {
+	stmt := `SELECT postid FROM scheduled_posts WHERE publish_at <= datetime('now') ORDER BY publish_at, postid`
+
+	rows, err := m.Conn.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Scheduled returns the scheduled posts of the author, the next to be
+// published first.
+func (m *sqlPostsRepository) Scheduled(author int) ([]*models.Post, error) {
+	stmt := `SELECT id, title, created, author, tags, EXISTS (SELECT true FROM pending_posts WHERE postid = posts.id),
+	scheduled_posts.publish_at
+	FROM posts JOIN scheduled_posts ON posts.id = scheduled_posts.postid
+	WHERE author = ? ORDER BY scheduled_posts.publish_at, id`
+
+	rows, err := m.Conn.Query(stmt, author)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*models.Post{}
+	for rows.Next() {
+		p := &models.Post{}
+		err = rows.Scan(&p.ID, &p.Title, &p.Created, &p.Author, &p.Tags, &p.Pending, &p.PublishAt)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
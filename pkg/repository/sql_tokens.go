@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"forum.bbilisbe/internal/models"
+)
+
+func (m *sqlUserRepository) APITokenInsert(user int, name, hash, prefix string, scopes []string) error {
+	stmt := `INSERT INTO api_tokens (userid, name, token_hash, prefix, scopes, created)
+	VALUES (?, ?, ?, ?, ?, datetime('now'))`
+
+	_, err := m.Conn.Exec(stmt, user, name, hash, prefix, strings.Join(scopes, " "))
+	return err
+}
+
+const apiTokenColumns = `id, userid, name, prefix, scopes, created, last_used FROM api_tokens`
+
+func scanAPIToken(row interface{ Scan(...any) error }) (*models.APIToken, error) {
+	t := &models.APIToken{}
+	var scopes string
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &scopes, &t.Created, &t.LastUsed)
+	if err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	return t, nil
+}
+
+func (m *sqlUserRepository) GetAPITokens(user int) ([]*models.APIToken, error) {
+	stmt := `SELECT ` + apiTokenColumns + ` WHERE userid = ? ORDER BY id DESC`
+
+	rows, err := m.Conn.Query(stmt, user)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []*models.APIToken{}
+
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (m *sqlUserRepository) DeleteAPIToken(user int, id int) error {
+	stmt := `DELETE FROM api_tokens WHERE id = ? AND userid = ?`
+
+	result, err := m.Conn.Exec(stmt, id, user)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *sqlUserRepository) GetAPITokenByHash(hash string) (*models.APIToken, error) {
+	stmt := `SELECT ` + apiTokenColumns + ` WHERE token_hash = ?`
+
+	t, err := scanAPIToken(m.Conn.QueryRow(stmt, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *sqlUserRepository) TouchAPIToken(id int) error {
+	stmt := `UPDATE api_tokens SET last_used = datetime('now') WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, id)
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"forum.bbilisbe/internal/cookies"
@@ -25,21 +26,35 @@ func (m *sqlUserRepository) Insert(username, email, password string) error {
 	if err != nil {
 		return err
 	}
+
+	_, err = insertUser(m.Conn, username, email, hashedPwd)
+	return err
+}
+
+// insertUser stores the user with either the database or a transaction and
+// returns the new user's id.
+func insertUser(db interface {
+	Exec(string, ...any) (sql.Result, error)
+}, username, email string, hashedPwd []byte) (int, error) {
 	stmt := `INSERT INTO users (username, email, hashed_password, created)
 	VALUES(?, ?, ?, datetime('now'))`
 
-	_, err = m.Conn.Exec(stmt, username, email, string(hashedPwd))
+	result, err := db.Exec(stmt, username, email, string(hashedPwd))
 	if err != nil {
 		if err.Error() == "UNIQUE constraint failed: users.email" {
-			return models.ErrDuplicateEmail
+			return 0, models.ErrDuplicateEmail
 		} else if err.Error() == "UNIQUE constraint failed: users.username" {
-			return models.ErrDuplicateUsername
+			return 0, models.ErrDuplicateUsername
 		}
 
-		return err
+		return 0, err
 	}
 
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
 }
 
 func (m *sqlUserRepository) Authenticate(email, password string) (int, error) {
@@ -98,6 +113,13 @@ func (m *sqlUserRepository) RemoveToken(token string) error {
 	return err
 }
 
+// RemoveExpiredTokens ends the sessions past their expiry.
+func (m *sqlUserRepository) RemoveExpiredTokens() error {
+	stmt := `UPDATE users SET token = NULL, expiry = NULL WHERE expiry < datetime('now')`
+	_, err := m.Conn.Exec(stmt)
+	return err
+}
+
 func (m *sqlUserRepository) GetUserId(r *http.Request) (int, error) {
 	var user int
 
@@ -170,6 +192,93 @@ func (m *sqlUserRepository) IsExpired(token string) (*time.Time, error) {
 	return result, nil
 }
 
+func (m *sqlUserRepository) GetUserCreated(id int) (time.Time, error) {
+	var created time.Time
+
+	stmt := "SELECT created FROM users WHERE id = ?"
+
+	err := m.Conn.QueryRow(stmt, id).Scan(&created)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return created, nil
+}
+
+// GetTrustScore counts the published posts of the user and the likes they
+// have received.
+func (m *sqlUserRepository) GetTrustScore(id int) (int, error) {
+	var score int
+
+	stmt := `SELECT COUNT(*) + COALESCE(SUM(likes), 0) FROM posts
+	WHERE author = ? AND id NOT IN (SELECT postid FROM hidden_posts)`
+
+	err := m.Conn.QueryRow(stmt, id).Scan(&score)
+	if err != nil {
+		return 0, err
+	}
+	return score, nil
+}
+
+func (m *sqlUserRepository) GetUser(id int) (*models.User, error) {
+	u := &models.User{}
+
+	stmt := "SELECT id, username, email, created FROM users WHERE id = ?"
+
+	err := m.Conn.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func (m *sqlUserRepository) GetUserIdByName(name string) (int, error) {
+	var id int
+
+	stmt := "SELECT id FROM users WHERE username = ?"
+
+	err := m.Conn.QueryRow(stmt, name).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrNoRecord
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// SearchUsernames returns the usernames starting with the prefix, ignoring the
+// case of ASCII letters, in alphabetical order.
+func (m *sqlUserRepository) SearchUsernames(prefix string, limit int) ([]string, error) {
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+
+	stmt := `SELECT username FROM users WHERE username LIKE ? ESCAPE '\' ORDER BY username LIMIT ?`
+
+	rows, err := m.Conn.Query(stmt, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	names := []string{}
+
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
 func (m *sqlUserRepository) IsLogged() {
 	return
 }
@@ -0,0 +1,99 @@
+package repository
+
+import "database/sql"
+
+// voteTable holds the votes of one kind, e.g. the likes of the posts, and
+// keeps the count of the voted row. The statements take the voted id and the
+// user; insert adds nothing when the user already voted.
+type voteTable struct {
+	insert string
+	delete string
+	count  string
+}
+
+var (
+	postLikes = voteTable{
+		insert: `INSERT INTO likes (postid, likedby) SELECT ?1, ?2
+		WHERE NOT EXISTS (SELECT * FROM likes WHERE postid = ?1 AND likedby = ?2)`,
+		delete: `DELETE FROM likes WHERE postid = ? AND likedby = ?`,
+		count:  `UPDATE posts SET likes = likes + ? WHERE id = ?`,
+	}
+	postDislikes = voteTable{
+		insert: `INSERT INTO dislikes (postid, dislikedby) SELECT ?1, ?2
+		WHERE NOT EXISTS (SELECT * FROM dislikes WHERE postid = ?1 AND dislikedby = ?2)`,
+		delete: `DELETE FROM dislikes WHERE postid = ? AND dislikedby = ?`,
+		count:  `UPDATE posts SET dislikes = dislikes + ? WHERE id = ?`,
+	}
+	commentLikes = voteTable{
+		insert: `INSERT INTO comment_likes (commentid, postid, likedby)
+		SELECT ?1, (SELECT postid FROM comments WHERE id = ?1), ?2
+		WHERE NOT EXISTS (SELECT * FROM comment_likes WHERE commentid = ?1 AND likedby = ?2)`,
+		delete: `DELETE FROM comment_likes WHERE commentid = ? AND likedby = ?`,
+		count:  `UPDATE comments SET likes = likes + ? WHERE id = ?`,
+	}
+	commentDislikes = voteTable{
+		insert: `INSERT INTO comment_dislikes (commentid, postid, dislikedby)
+		SELECT ?1, (SELECT postid FROM comments WHERE id = ?1), ?2
+		WHERE NOT EXISTS (SELECT * FROM comment_dislikes WHERE commentid = ?1 AND dislikedby = ?2)`,
+		delete: `DELETE FROM comment_dislikes WHERE commentid = ? AND dislikedby = ?`,
+		count:  `UPDATE comments SET dislikes = dislikes + ? WHERE id = ?`,
+	}
+)
+
+// SetLike adds or takes back the like of the user on the post and reports
+// whether it changed. A like replaces the dislike of the user.
+func (m *sqlPostsRepository) SetLike(postid int, user int, liked bool) (bool, error) {
+	return m.setVote(postLikes, postDislikes, postid, user, liked)
+}
+
+func (m *sqlPostsRepository) SetDislike(postid int, user int, disliked bool) (bool, error) {
+	return m.setVote(postDislikes, postLikes, postid, user, disliked)
+}
+
+func (m *sqlPostsRepository) SetCommentLike(commentid int, user int, liked bool) (bool, error) {
+	return m.setVote(commentLikes, commentDislikes, commentid, user, liked)
+}
+
+func (m *sqlPostsRepository) SetCommentDislike(commentid int, user int, disliked bool) (bool, error) {
+	return m.setVote(commentDislikes, commentLikes, commentid, user, disliked)
+}
+
+// setVote changes the vote and the counts in one transaction, the counts are
+// moved by the statements themselves so concurrent votes all add up.
+func (m *sqlPostsRepository) setVote(vote, opposite voteTable, id int, user int, on bool) (bool, error) {
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	changed, err := changeVote(tx, vote, id, user, on)
+	if err != nil || !changed {
+		return false, err
+	}
+	if on {
+		if _, err = changeVote(tx, opposite, id, user, false); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+func changeVote(tx *sql.Tx, t voteTable, id int, user int, on bool) (bool, error) {
+	stmt, delta := t.delete, -1
+	if on {
+		stmt, delta = t.insert, 1
+	}
+	result, err := tx.Exec(stmt, id, user)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	if _, err = tx.Exec(t.count, delta, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
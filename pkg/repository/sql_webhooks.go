@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+type sqlWebhookRepository struct {
+	Conn *sql.DB
+}
+
+func NewSqlWebhookRepository(conn *sql.DB) models.WebhookRepository {
+	return &sqlWebhookRepository{conn}
+}
+
+func (m *sqlWebhookRepository) Insert(url, secret string, events []string) error {
+	stmt := `INSERT INTO webhooks (url, secret, events, created) VALUES (?, ?, ?, datetime('now'))`
+
+	_, err := m.Conn.Exec(stmt, url, secret, strings.Join(events, " "))
+	return err
+}
+
+// Delete removes the webhook together with its deliveries.
+func (m *sqlWebhookRepository) Delete(id int) error {
+	tx, err := m.Conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM webhook_deliveries WHERE webhookid = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n == 0 {
+		tx.Rollback()
+		return models.ErrNoRecord
+	}
+	return tx.Commit()
+}
+
+func (m *sqlWebhookRepository) List() ([]*models.Webhook, error) {
+	stmt := `SELECT id, url, secret, events, created FROM webhooks ORDER BY id`
+
+	rows, err := m.Conn.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	hooks := []*models.Webhook{}
+
+	for rows.Next() {
+		w := &models.Webhook{}
+		var events string
+
+		err = rows.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Created)
+		if err != nil {
+			return nil, err
+		}
+		w.Events = strings.Fields(events)
+
+		hooks = append(hooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// Enqueue adds a pending delivery of the event for every webhook subscribed
+// to it.
+func (m *sqlWebhookRepository) Enqueue(event string, payload []byte) error {
+	stmt := `INSERT INTO webhook_deliveries (webhookid, event, payload, status, created, next_attempt)
+	SELECT id, ?, ?, ?, datetime('now'), datetime('now') FROM webhooks
+	WHERE instr(' ' || events || ' ', ?) > 0`
+
+	_, err := m.Conn.Exec(stmt, event, string(payload), models.DeliveryPending, " "+event+" ")
+	return err
+}
+
+const deliveryColumns = `webhook_deliveries.id, webhookid, COALESCE(webhooks.url, ''), COALESCE(webhooks.secret, ''),
+	event, payload, status, attempts, response_code, error, webhook_deliveries.created, next_attempt, delivered
+	FROM webhook_deliveries LEFT JOIN webhooks ON webhooks.id = webhook_deliveries.webhookid`
+
+func (m *sqlWebhookRepository) queryDeliveries(stmt string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := m.Conn.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+
+	for rows.Next() {
+		d := &models.WebhookDelivery{}
+		var payload string
+
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &payload, &d.Status, &d.Attempts,
+			&d.ResponseCode, &d.Error, &d.Created, &d.NextAttempt, &d.Delivered)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = []byte(payload)
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Due returns the oldest pending deliveries whose next attempt is due.
+func (m *sqlWebhookRepository) Due(limit int) ([]*models.WebhookDelivery, error) {
+	stmt := `SELECT ` + deliveryColumns + `
+	WHERE status = ? AND next_attempt <= datetime('now') ORDER BY webhook_deliveries.id LIMIT ?`
+
+	return m.queryDeliveries(stmt, models.DeliveryPending, limit)
+}
+
+// Deliveries returns the latest deliveries for the delivery log.
+func (m *sqlWebhookRepository) Deliveries(limit int) ([]*models.WebhookDelivery, error) {
+	stmt := `SELECT ` + deliveryColumns + ` ORDER BY webhook_deliveries.id DESC LIMIT ?`
+
+	return m.queryDeliveries(stmt, limit)
+}
+
+func (m *sqlWebhookRepository) Delivered(id int, code int) error {
+	stmt := `UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, response_code = ?, error = '',
+	next_attempt = NULL, delivered = datetime('now') WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, models.DeliveryDelivered, code, id)
+	return err
+}
+
+// Retry records a failed attempt and schedules the next one after wait.
+func (m *sqlWebhookRepository) Retry(id int, code int, message string, wait time.Duration) error {
+	stmt := `UPDATE webhook_deliveries SET attempts = attempts + 1, response_code = ?, error = ?,
+	next_attempt = datetime('now', ?) WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, code, message, fmt.Sprintf("+%d seconds", int(wait.Seconds())), id)
+	return err
+}
+
+// Failed records the last attempt of a delivery which is given up.
+func (m *sqlWebhookRepository) Failed(id int, code int, message string) error {
+	stmt := `UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, response_code = ?, error = ?,
+	next_attempt = NULL WHERE id = ?`
+
+	_, err := m.Conn.Exec(stmt, models.DeliveryFailed, code, message, id)
+	return err
+}
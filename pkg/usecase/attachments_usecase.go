@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/images"
+	"forum.bbilisbe/internal/models"
+)
+
+const (
+	// filePrefix starts the keys of the attachments other than images in the
+	// media store. They are stored without extension, so the store never
+	// serves them as a type derived from a name chosen by the user.
+	filePrefix = "files/"
+	// maxAttachmentName is the length in characters an attachment name is
+	// cut to.
+	maxAttachmentName = 100
+)
+
+// attachmentName() keeps the last element of an uploaded file's name without
+// control characters, some browsers send the whole path.
+func attachmentName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+
+	if r := []rune(name); len(r) > maxAttachmentName {
+		name = string(r[:maxAttachmentName])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// SaveAttachment stores a file uploaded with a post under a random name. PNG,
+// JPEG and GIF pictures are cleaned by images.Process(), other files are
+// accepted as they are when their sniffed type is one of the AttachmentTypes.
+// Files larger than MaxFileSize are refused.
+func (m *postsUsecase) SaveAttachment(name string, r io.Reader) (*models.Attachment, error) {
+	data, err := io.ReadAll(io.LimitReader(r, m.cfg.MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > m.cfg.MaxFileSize {
+		return nil, models.ErrAttachmentTooLarge
+	}
+
+	random, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+	a := &models.Attachment{Name: attachmentName(name)}
+
+	var key string
+	img, err := images.Process(data)
+	switch {
+	case err == nil:
+		key = postImagePrefix + random + img.Ext
+		data = img.Data
+		a.ContentType, a.Width, a.Height = img.Type, img.Width, img.Height
+	case errors.Is(err, images.ErrUnsupported):
+		a.ContentType = http.DetectContentType(data)
+		mediaType, _, _ := mime.ParseMediaType(a.ContentType)
+		if !config.Has(m.cfg.AttachmentTypes, mediaType) {
+			return nil, models.ErrAttachmentType
+		}
+		key = filePrefix + random
+	default:
+		return nil, err
+	}
+
+	a.Size = int64(len(data))
+	if err = m.media.Put(key, a.ContentType, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	a.URL = m.media.URL(key)
+	return a, nil
+}
+
+// DeleteAttachments removes the stored files of the attachments, e.g. when
+// the post they were uploaded with can't be created.
+func (m *postsUsecase) DeleteAttachments(attachments []*models.Attachment) error {
+	var errs []error
+	for _, a := range attachments {
+		for _, url := range []string{a.URL, a.Thumbnail} {
+			if key := mediaKey(m.media, url, ""); key != "" {
+				errs = append(errs, m.media.Delete(key))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *postsUsecase) GetAttachment(id int) (*models.Attachment, error) {
+	return m.postsRepo.GetAttachment(id)
+}
+
+// OpenAttachment opens the stored file of the attachment and counts the
+// download.
+func (m *postsUsecase) OpenAttachment(a *models.Attachment) (*models.MediaFile, error) {
+	key := mediaKey(m.media, a.URL, "")
+	if key == "" {
+		return nil, models.ErrNoRecord
+	}
+
+	f, err := m.media.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.postsRepo.AttachmentDownloaded(a.ID); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"time"
+
+	"forum.bbilisbe/internal/avatar"
+	"forum.bbilisbe/internal/models"
+)
+
+// avatarPrefix starts the keys of the uploaded avatars in the media store.
+const avatarPrefix = "avatars/"
+
+func avatarKey(image string, size int) string {
+	return fmt.Sprintf("%s%s-%d.png", avatarPrefix, image, size)
+}
+
+// GetAvatar returns the PNG avatar of the user in the size, one of
+// avatar.Sizes, and the time it was last changed. Users without an uploaded
+// avatar get their identicon.
+func (m *userUsecase) GetAvatar(name string, size int) ([]byte, time.Time, error) {
+	a, err := m.usersRepo.GetAvatar(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if a.Image != "" {
+		f, err := m.media.Get(avatarKey(a.Image, size))
+		if err == nil {
+			defer f.Close()
+			b, err := io.ReadAll(f)
+			return b, a.Updated, err
+		}
+		if !errors.Is(err, models.ErrNoRecord) {
+			return nil, time.Time{}, err
+		}
+	}
+
+	b, err := avatar.Identicon(a.Name, size)
+	return b, a.Updated, err
+}
+
+// SetAvatar crops the uploaded picture, see avatar.Process(), stores it in all
+// the sizes and deletes the previous avatar of the user.
+func (m *userUsecase) SetAvatar(user int, picture io.Reader, crop image.Rectangle) error {
+	avatars, err := avatar.Process(picture, crop)
+	if err != nil {
+		return err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return err
+	}
+	for size, b := range avatars {
+		if err = m.media.Put(avatarKey(name, size), "image/png", bytes.NewReader(b)); err != nil {
+			m.removeAvatarFiles(name)
+			return err
+		}
+	}
+
+	old, err := m.usersRepo.SetAvatar(user, name)
+	if err != nil {
+		m.removeAvatarFiles(name)
+		return err
+	}
+	if old != "" {
+		m.removeAvatarFiles(old)
+	}
+	return nil
+}
+
+// RemoveAvatar brings back the identicon of the user.
+func (m *userUsecase) RemoveAvatar(user int) error {
+	old, err := m.usersRepo.RemoveAvatar(user)
+	if err != nil {
+		return err
+	}
+	m.removeAvatarFiles(old)
+	return nil
+}
+
+// ImportAvatars moves the avatars uploaded before the media store into it
+// from dir. Their names stay the same.
+func (m *userUsecase) ImportAvatars(dir string) error {
+	return importFiles(dir, func(name, contentType string, content io.Reader) error {
+		return m.media.Put(avatarPrefix+name, contentType, content)
+	})
+}
+
+func (m *userUsecase) removeAvatarFiles(image string) {
+	for _, size := range avatar.Sizes {
+		m.media.Delete(avatarKey(image, size))
+	}
+}
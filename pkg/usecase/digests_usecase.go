@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"unicode/utf8"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/mail"
+	"forum.bbilisbe/internal/models"
+)
+
+const (
+	digestPosts    = 10
+	digestReplies  = 10
+	digestTopPosts = 5
+	digestHistory  = 10
+	digestExcerpt  = 200
+)
+
+type digestUsecase struct {
+	digestRepo models.DigestRepository
+	usersRepo  models.UserRepository
+	mailer     mail.Mailer
+	html       *htmltemplate.Template
+	text       *texttemplate.Template
+	cfg        *config.Config
+}
+
+// NewDigestUsecase loads the email templates from ./ui/email, the digests are
+// sent through the mailer.
+func NewDigestUsecase(d models.DigestRepository, u models.UserRepository, mailer mail.Mailer, cfg *config.Config) (models.DigestUsecases, error) {
+	funcs := map[string]any{"excerpt": excerpt}
+
+	html, err := htmltemplate.New("digest.html").Funcs(funcs).ParseFiles("./ui/email/digest.html")
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New("digest.txt").Funcs(funcs).ParseFiles("./ui/email/digest.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	return &digestUsecase{
+		digestRepo: d,
+		usersRepo:  u,
+		mailer:     mailer,
+		html:       html,
+		text:       text,
+		cfg:        cfg,
+	}, nil
+}
+
+// excerpt() shortens the text to digestExcerpt characters.
+func excerpt(text string) string {
+	if utf8.RuneCountInString(text) <= digestExcerpt {
+		return text
+	}
+	return strings.TrimSpace(string([]rune(text)[:digestExcerpt])) + "…"
+}
+
+func (m *digestUsecase) Settings(user int) (*models.DigestSettings, error) {
+	return m.digestRepo.Settings(user)
+}
+
+func (m *digestUsecase) SetSettings(user int, s models.DigestSettings) error {
+	return m.digestRepo.SetSettings(user, s)
+}
+
+func (m *digestUsecase) History(user int) ([]*models.DigestRecord, error) {
+	return m.digestRepo.History(user, digestHistory)
+}
+
+// SendDue sends the digests of the subscribers who didn't get one during the
+// last day or week. A failed digest doesn't stop the others, it is tried again
+// on the next call, like the ones left when the context ends.
+func (m *digestUsecase) SendDue(ctx context.Context) error {
+	var errs []error
+
+	for _, frequency := range []string{models.DigestDaily, models.DigestWeekly} {
+		due, err := m.digestRepo.Due(frequency, models.DigestPeriod(frequency))
+		if err != nil {
+			return err
+		}
+		for _, s := range due {
+			if err = ctx.Err(); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			if err = m.send(s); err != nil {
+				errs = append(errs, fmt.Errorf("digest for user %d: %w", s.UserID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send builds the digest of the subscriber and mails it unless it is empty.
+// Either way the digest is recorded.
+func (m *digestUsecase) send(s *models.DigestSettings) error {
+	period := models.DigestPeriod(s.Frequency)
+
+	posts, err := m.digestRepo.NewPosts(s.Categories, period, digestPosts)
+	if err != nil {
+		return err
+	}
+	replies, err := m.digestRepo.Replies(s.UserID, period, digestReplies)
+	if err != nil {
+		return err
+	}
+	top, err := m.digestRepo.TopPosts(period, digestTopPosts)
+	if err != nil {
+		return err
+	}
+	for _, post := range append(posts, top...) {
+		post.Author, _ = m.usersRepo.GetUserName(post.Author)
+	}
+
+	digest := &models.Digest{
+		Name:      s.Name,
+		Frequency: s.Frequency,
+		BaseURL:   m.cfg.BaseURL,
+		Posts:     posts,
+		Replies:   replies,
+		TopPosts:  top,
+	}
+	record := models.DigestRecord{
+		UserID:    s.UserID,
+		Frequency: s.Frequency,
+		Posts:     len(posts),
+		Replies:   len(replies),
+		TopPosts:  len(top),
+		Status:    models.DigestEmpty,
+	}
+
+	if !digest.Empty() {
+		var html, text bytes.Buffer
+		if err = m.html.Execute(&html, digest); err != nil {
+			return err
+		}
+		if err = m.text.Execute(&text, digest); err != nil {
+			return err
+		}
+
+		err = m.mailer.Send(&mail.Message{
+			From:    m.cfg.MailFrom,
+			To:      mail.Address(s.Name, s.Email),
+			Subject: fmt.Sprintf("Your %s digest from the K-Pop Forum", s.Frequency),
+			Text:    text.String(),
+			HTML:    html.String(),
+		})
+		if err != nil {
+			return err
+		}
+		record.Status = models.DigestSent
+	}
+	return m.digestRepo.Record(record)
+}
@@ -0,0 +1,32 @@
+package usecase
+
+import "forum.bbilisbe/internal/models"
+
+// SaveDraft creates a draft from the form, or saves it over form.Draft, and
+// returns its id. A user keeps at most models.MaxDrafts drafts.
+func (m *postsUsecase) SaveDraft(form models.PostCreateForm, user int) (int, error) {
+	if form.Draft != 0 {
+		return form.Draft, m.postsRepo.DraftUpdate(form, user)
+	}
+
+	n, err := m.postsRepo.DraftCount(user)
+	if err != nil {
+		return 0, err
+	}
+	if n >= models.MaxDrafts {
+		return 0, models.ErrTooManyDrafts
+	}
+	return m.postsRepo.DraftInsert(form, user)
+}
+
+func (m *postsUsecase) GetDraft(id int, user int) (*models.Draft, error) {
+	return m.postsRepo.GetDraft(id, user)
+}
+
+func (m *postsUsecase) Drafts(user int) ([]*models.Draft, error) {
+	return m.postsRepo.Drafts(user)
+}
+
+func (m *postsUsecase) DeleteDraft(id int, user int) error {
+	return m.postsRepo.DeleteDraft(id, user)
+}
@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"time"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/filter"
+	"forum.bbilisbe/internal/models"
+)
+
+const (
+	duplicateSimilarity = 0.8
+	duplicateMinWords   = 5
+)
+
+type filterUsecase struct {
+	filterRepo models.FilterRepository
+	usersRepo  models.UserRepository
+	cfg        *config.Config
+}
+
+func NewFilterUsecase(f models.FilterRepository, u models.UserRepository, cfg *config.Config) models.FilterUsecases {
+	return &filterUsecase{
+		filterRepo: f,
+		usersRepo:  u,
+		cfg:        cfg,
+	}
+}
+
+// Check runs the content through the word list, the link limit and the
+// duplicate detection. The content may be rewritten by the word list.
+func (m *filterUsecase) Check(c *filter.Content) (filter.Result, error) {
+	words, err := m.filterRepo.Words()
+	if err != nil {
+		return filter.Result{}, err
+	}
+	list := make([]filter.Word, 0, len(words))
+	for _, w := range words {
+		list = append(list, filter.Word{Pattern: w.Pattern, Regex: w.Regex, Replacement: w.Replacement, Score: w.Score})
+	}
+	wordRule, err := filter.NewWordRule(list)
+	if err != nil {
+		return filter.Result{}, err
+	}
+
+	created, err := m.usersRepo.GetUserCreated(c.Author)
+	if err != nil {
+		return filter.Result{}, err
+	}
+	c.NewUser = time.Since(created) < m.cfg.HoldAge
+
+	pipeline := &filter.Pipeline{
+		Rules: []filter.Rule{
+			wordRule,
+			&filter.LinkRule{Max: m.cfg.MaxLinks, Score: m.cfg.FilterHold},
+			&filter.DuplicateRule{
+				History:    recentHistory{m.filterRepo, m.cfg.DuplicateWindow},
+				Similarity: duplicateSimilarity,
+				MinWords:   duplicateMinWords,
+				Score:      m.cfg.FilterHold,
+			},
+		},
+		HoldScore:   m.cfg.FilterHold,
+		RejectScore: m.cfg.FilterReject,
+	}
+	return pipeline.Run(c)
+}
+
+func (m *filterUsecase) Words() ([]*models.FilterWord, error) {
+	return m.filterRepo.Words()
+}
+
+func (m *filterUsecase) WordInsert(w models.FilterWord) error {
+	return m.filterRepo.WordInsert(w)
+}
+
+func (m *filterUsecase) WordDelete(id int) error {
+	return m.filterRepo.WordDelete(id)
+}
+
+// recentHistory adapts the repository to the filter.History interface.
+type recentHistory struct {
+	repo   models.FilterRepository
+	window time.Duration
+}
+
+func (h recentHistory) Recent() ([]string, error) {
+	return h.repo.Recent(h.window)
+}
@@ -0,0 +1,203 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"forum.bbilisbe/internal/images"
+	"forum.bbilisbe/internal/models"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// postImagePrefix starts the keys of the post images in the media store.
+	postImagePrefix = "posts/"
+	// thumbnailSize is the largest side of the thumbnails shown in the feed.
+	thumbnailSize = 200
+)
+
+// mediaKey() returns the key of the stored file at the URL, an empty string
+// for other URLs.
+func mediaKey(store models.MediaStore, url, prefix string) string {
+	key, ok := strings.CutPrefix(url, store.URL(""))
+	if !ok || !strings.HasPrefix(key, prefix) || len(key) == len(prefix) {
+		return ""
+	}
+	return key
+}
+
+// randomName() returns a new file name made of a random UUID.
+func randomName() (string, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(id.String(), "-", ""), nil
+}
+
+// CreateThumbnail makes the thumbnails of the images of the post: the one of
+// the post image shown in the feed and the ones of the gallery. It runs as a
+// background job queued when the post is created.
+func (m *postsUsecase) CreateThumbnail(id int) error {
+	post, err := m.postsRepo.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			// Deleted in the meantime.
+			return nil
+		}
+		return err
+	}
+	attachments, err := m.postsRepo.Attachments(id)
+	if err != nil {
+		return err
+	}
+
+	// Posts written before the attachments only have their image.
+	postImage := post.Image
+	for _, a := range attachments {
+		if !a.IsImage() {
+			continue
+		}
+		if a.Thumbnail == "" {
+			if a.Thumbnail, err = m.thumbnail(a.URL); err != nil {
+				return err
+			}
+			if err = m.postsRepo.AttachmentThumbnail(a.ID, a.Thumbnail); err != nil {
+				return err
+			}
+		}
+		if a.URL == postImage {
+			if err = m.postsRepo.ThumbnailInsert(id, a.Thumbnail); err != nil {
+				return err
+			}
+			postImage = ""
+		}
+	}
+	if postImage == "" {
+		return nil
+	}
+
+	thumb, err := m.thumbnail(postImage)
+	if err != nil || thumb == "" {
+		return err
+	}
+	return m.postsRepo.ThumbnailInsert(id, thumb)
+}
+
+// thumbnail() stores the thumbnail of the post image at the URL next to it
+// and returns its URL, an empty one for images outside of the media store.
+func (m *postsUsecase) thumbnail(url string) (string, error) {
+	key := mediaKey(m.media, url, postImagePrefix)
+	if key == "" {
+		return "", nil
+	}
+
+	f, err := m.media.Get(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	thumb, err := images.Thumbnail(data, thumbnailSize)
+	if err != nil {
+		return "", fmt.Errorf("thumbnail of %s: %w", url, err)
+	}
+
+	thumbKey := strings.TrimSuffix(key, path.Ext(key)) + "-thumb" + thumb.Ext
+	if err = m.media.Put(thumbKey, thumb.Type, bytes.NewReader(thumb.Data)); err != nil {
+		return "", err
+	}
+	return m.media.URL(thumbKey), nil
+}
+
+// CleanupImages deletes the stored post images and attachments older than age
+// which no post shows: uploads left behind by posts which were never created
+// and the files of deleted posts.
+func (m *postsUsecase) CleanupImages(age time.Duration) error {
+	var errs []error
+	for _, prefix := range []string{postImagePrefix, filePrefix} {
+		files, err := m.media.List(prefix)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, file := range files {
+			if time.Since(file.Modified) < age {
+				continue
+			}
+
+			used, err := m.postsRepo.MediaUsed(m.media.URL(file.Key))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !used {
+				if err = m.media.Delete(file.Key); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ImportImages moves the post images uploaded before the media store into it
+// from dir, where they were served at url, and points the posts to their new
+// address.
+func (m *postsUsecase) ImportImages(dir, url string) error {
+	return importFiles(dir, func(name, contentType string, content io.Reader) error {
+		key := postImagePrefix + name
+		if err := m.media.Put(key, contentType, content); err != nil {
+			return err
+		}
+		return m.postsRepo.ReplaceImage(url+name, m.media.URL(key))
+	})
+}
+
+// importFiles() passes the files in dir with the type of their extension to
+// put one after the other and deletes them once put succeeded. A missing dir
+// has nothing to import.
+func importFiles(dir string, put func(name, contentType string, content io.Reader) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := filepath.Join(dir, entry.Name())
+
+		f, err := os.Open(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = put(entry.Name(), mime.TypeByExtension(filepath.Ext(name)), f)
+		f.Close()
+		if err == nil {
+			err = os.Remove(name)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("importing %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"sort"
+	"strconv"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// InsertWithInvite signs the user up with an invite code and records who
+// invited them.
+func (m *userUsecase) InsertWithInvite(form models.UserSignupForm) error {
+	id, err := m.usersRepo.InviteSignup(form.Invite, form.Name, form.Email, form.Password)
+	if err != nil {
+		return err
+	}
+	m.emitSignup(id, form.Name)
+	return nil
+}
+
+// CanInvite reports whether the user may create invite codes: moderators and
+// users who reached the InviteTrust score.
+func (m *userUsecase) CanInvite(id int) bool {
+	if isModerator(m.cfg, m.usersRepo, id) {
+		return true
+	}
+	score, err := m.usersRepo.GetTrustScore(id)
+	if err != nil {
+		return false
+	}
+	return score >= m.cfg.InviteTrust
+}
+
+func (m *userUsecase) CreateInvite(createdBy int, maxUses int, ttl time.Duration) (*models.Invite, error) {
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	id, err := m.usersRepo.InviteInsert(code, createdBy, maxUses, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return m.usersRepo.GetInvite(id)
+}
+
+func (m *userUsecase) GetInvite(id int) (*models.Invite, error) {
+	return m.usersRepo.GetInvite(id)
+}
+
+func (m *userUsecase) GetUserInvites(user int) ([]*models.Invite, error) {
+	return m.usersRepo.GetUserInvites(user)
+}
+
+func (m *userUsecase) GetInvites() ([]*models.Invite, error) {
+	return m.usersRepo.GetInvites()
+}
+
+func (m *userUsecase) RevokeInvite(id int) error {
+	return m.usersRepo.RevokeInvite(id)
+}
+
+// GetInviteTree builds the tree of who invited whom. The roots are the users
+// who invited someone without being invited themselves.
+func (m *userUsecase) GetInviteTree() ([]*models.InviteNode, error) {
+	invited, err := m.usersRepo.GetInvitedUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[int]*models.InviteNode{}
+	node := func(id int) *models.InviteNode {
+		n, ok := nodes[id]
+		if !ok {
+			n = &models.InviteNode{UserID: id}
+			n.Name, _ = m.usersRepo.GetUserName(strconv.Itoa(id))
+			nodes[id] = n
+		}
+		return n
+	}
+
+	isInvited := map[int]bool{}
+	for _, u := range invited {
+		child := node(u.UserID)
+		child.Code = u.Code
+		parent := node(u.InvitedBy)
+		parent.Children = append(parent.Children, child)
+		isInvited[u.UserID] = true
+	}
+
+	roots := []*models.InviteNode{}
+	for id, n := range nodes {
+		if !isInvited[id] {
+			roots = append(roots, n)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].UserID < roots[j].UserID })
+	return roots, nil
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
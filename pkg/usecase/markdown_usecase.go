@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"errors"
+	"html/template"
+	"net/url"
+
+	"forum.bbilisbe/internal/markdown"
+	"forum.bbilisbe/internal/mention"
+	"forum.bbilisbe/internal/models"
+)
+
+// renderMarkdown() renders the Markdown of a post or a comment with the
+// mentions of the names linked to their profiles.
+func renderMarkdown(source string, names []string) string {
+	return markdown.Render(source, func(text string) string {
+		return mention.HTML(text, names, func(name string) string {
+			return "/u/" + url.PathEscape(name)
+		})
+	})
+}
+
+// rendered returns the HTML of the post, commentid 0, or of its comment from
+// the stored ones. The HTML missing or made by an older renderer is rendered
+// again and stored.
+func (m *postsUsecase) rendered(stored map[int]string, postid int, commentid int, source string, names []string) (template.HTML, error) {
+	if html, ok := stored[commentid]; ok {
+		return template.HTML(html), nil
+	}
+	html := renderMarkdown(source, names)
+	if err := m.postsRepo.RenderedInsert(postid, commentid, markdown.Version, html); err != nil {
+		return "", err
+	}
+	return template.HTML(html), nil
+}
+
+// Preview renders the Markdown like a post, for the create form. Only the
+// mentions of existing users are linked.
+func (m *postsUsecase) Preview(source string) (template.HTML, error) {
+	var names []string
+	for _, name := range mention.Names(source) {
+		_, err := m.usersRepo.GetUserIdByName(name)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				continue
+			}
+			return "", err
+		}
+		names = append(names, name)
+	}
+	return template.HTML(renderMarkdown(source, names)), nil
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"forum.bbilisbe/internal/models"
+)
+
+type notificationUsecase struct {
+	notificationRepo models.NotificationRepository
+}
+
+func NewNotificationUsecase(n models.NotificationRepository) models.NotificationUsecases {
+	return &notificationUsecase{
+		notificationRepo: n,
+	}
+}
+
+// Notify stores the notification unless the user caused it or turned its
+// type off.
+func (m *notificationUsecase) Notify(n models.Notification) error {
+	if n.UserID == n.ActorID {
+		return nil
+	}
+
+	disabled, err := m.notificationRepo.Disabled(n.UserID)
+	if err != nil {
+		return err
+	}
+	for _, typ := range disabled {
+		if typ == n.Type {
+			return nil
+		}
+	}
+	return m.notificationRepo.Insert(n)
+}
+
+func (m *notificationUsecase) Notifications(user int, limit int) ([]*models.Notification, error) {
+	return m.notificationRepo.List(user, limit)
+}
+
+func (m *notificationUsecase) Unread(user int) (int, error) {
+	return m.notificationRepo.Unread(user)
+}
+
+func (m *notificationUsecase) MarkRead(user int, id int) error {
+	return m.notificationRepo.MarkRead(user, id)
+}
+
+func (m *notificationUsecase) MarkAllRead(user int) error {
+	return m.notificationRepo.MarkAllRead(user)
+}
+
+// Preferences returns whether each type of models.NotificationTypes is turned
+// on for the user. Every type is on until the user turns it off.
+func (m *notificationUsecase) Preferences(user int) (map[string]bool, error) {
+	disabled, err := m.notificationRepo.Disabled(user)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := make(map[string]bool)
+	for _, t := range models.NotificationTypes {
+		prefs[t.Type] = true
+	}
+	for _, typ := range disabled {
+		prefs[typ] = false
+	}
+	return prefs, nil
+}
+
+func (m *notificationUsecase) SetPreferences(user int, prefs map[string]bool) error {
+	var disabled []string
+	for _, t := range models.NotificationTypes {
+		if !prefs[t.Type] {
+			disabled = append(disabled, t.Type)
+		}
+	}
+	return m.notificationRepo.SetDisabled(user, disabled)
+}
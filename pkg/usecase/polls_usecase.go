@@ -0,0 +1,61 @@
+package usecase
+
+import "forum.bbilisbe/internal/models"
+
+// Poll returns the poll of the post as the user sees it: with the options the
+// user chose and, unless the results are hidden from the user, the counts.
+func (m *postsUsecase) Poll(postID int, user int) (*models.Poll, error) {
+	poll, err := m.postsRepo.GetPoll(postID)
+	if err != nil {
+		return nil, err
+	}
+	poll.Voted = []int{}
+	if user != 0 {
+		poll.Voted, err = m.postsRepo.PollVotes(postID, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	poll.Results = !poll.HideResults || len(poll.Voted) > 0 || poll.Closed()
+	if !poll.Results {
+		poll.Voters = 0
+		for _, o := range poll.Options {
+			o.Votes = 0
+		}
+	}
+	return poll, nil
+}
+
+// Vote replaces the vote of the user in the poll of the post. It fails with
+// ErrInvalidVote unless the options are of the poll, and just one of them for
+// a single choice poll.
+func (m *postsUsecase) Vote(postID int, user int, options []int) (*models.Poll, error) {
+	poll, err := m.postsRepo.GetPoll(postID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.Closed() {
+		return nil, models.ErrPollClosed
+	}
+	if len(options) == 0 || (!poll.Multiple && len(options) > 1) {
+		return nil, models.ErrInvalidVote
+	}
+
+	valid := make(map[int]bool)
+	for _, o := range poll.Options {
+		valid[o.ID] = true
+	}
+	for _, id := range options {
+		if !valid[id] {
+			return nil, models.ErrInvalidVote
+		}
+		// Each option counts once.
+		valid[id] = false
+	}
+
+	if err = m.postsRepo.PollVote(postID, user, options); err != nil {
+		return nil, err
+	}
+	return m.Poll(postID, user)
+}
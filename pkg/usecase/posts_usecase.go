@@ -2,22 +2,46 @@ package usecase
 
 import (
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/filter"
+	"forum.bbilisbe/internal/live"
+	"forum.bbilisbe/internal/markdown"
+	"forum.bbilisbe/internal/mention"
 	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/webhook"
 )
 
 type postsUsecase struct {
 	postsRepo models.PostRepository
 	usersRepo models.UserRepository
+	filter    models.FilterUsecases
+	hooks     models.WebhookUsecases
+	notify    models.NotificationUsecases
+	jobs      models.JobQueue
+	media     models.MediaStore
+	live      *live.Hub
+	cfg       *config.Config
+	errorLog  *log.Logger
 }
 
-func NewPostUsecase(p models.PostRepository, u models.UserRepository) models.PostUsecases {
+func NewPostUsecase(p models.PostRepository, u models.UserRepository, f models.FilterUsecases, w models.WebhookUsecases, n models.NotificationUsecases, q models.JobQueue, store models.MediaStore, hub *live.Hub, cfg *config.Config, errorLog *log.Logger) models.PostUsecases {
 	return &postsUsecase{
 		postsRepo: p,
 		usersRepo: u,
+		filter:    f,
+		hooks:     w,
+		notify:    n,
+		jobs:      q,
+		media:     store,
+		live:      hub,
+		cfg:       cfg,
+		errorLog:  errorLog,
 	}
 }
 
@@ -34,8 +58,156 @@ func (m *postsUsecase) GetPostId(r *http.Request) (int, error) {
 	return id, nil
 }
 
+// Insert creates the post. Once it is stored, failures of the following steps
+// are logged and the post is still announced; an error returned together with
+// its id means only the announcement failed, 0 means nothing was created.
 func (m *postsUsecase) Insert(data models.PostCreateForm, author int) (int, error) {
-	return m.postsRepo.Insert(data, author)
+	content := &filter.Content{Author: author, Title: data.Title, Body: data.Content}
+	result, err := m.filter.Check(content)
+	if err != nil {
+		return 0, err
+	}
+	if result.Verdict == filter.Reject {
+		return 0, models.ErrRejectedContent
+	}
+	data.Title, data.Content = content.Title, content.Body
+
+	// The first image is the post image shown in the feed.
+	for _, a := range data.Attachments {
+		if data.ImageURL == "" && a.IsImage() {
+			data.ImageURL = a.URL
+		}
+	}
+
+	// Scheduled posts are announced when their time comes.
+	if data.PublishAt != nil && !data.PublishAt.After(time.Now()) {
+		data.PublishAt = nil
+	}
+	held, err := m.needsReview(author)
+	if err != nil {
+		return 0, err
+	}
+	held = held || result.Verdict == filter.Hold
+
+	id, err := m.postsRepo.Insert(data, author, held)
+	if err != nil {
+		return 0, err
+	}
+	// The post replaces the draft it was written from.
+	if data.Draft != 0 {
+		err = m.postsRepo.DeleteDraft(data.Draft, author)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			m.errorLog.Print(err)
+		}
+	}
+	if err = m.postsRepo.MentionInsert(id, 0, mention.Names(data.Content)); err != nil {
+		m.errorLog.Print(err)
+	}
+	// Without the stored HTML the post is rendered when it is viewed.
+	mentions, err := m.postsRepo.Mentions(id)
+	if err != nil {
+		m.errorLog.Print(err)
+	} else if _, err = m.rendered(nil, id, 0, data.Content, mentions[0]); err != nil {
+		m.errorLog.Print(err)
+	}
+	if data.ImageURL != "" {
+		if err = m.jobs.Enqueue(models.JobThumbnail, id); err != nil {
+			m.errorLog.Print(err)
+		}
+	}
+
+	if held || data.PublishAt != nil {
+		return id, nil
+	}
+	return id, m.published(id)
+}
+
+// published announces a published post on the home feed, notifies the
+// mentioned users and queues the post.created webhooks. The post is stored
+// already, so failures of these steps are logged. An error is only returned
+// when the post can't be loaded, before anything was announced.
+func (m *postsUsecase) published(id int) error {
+	post, err := m.postsRepo.Get(id)
+	if err != nil {
+		return err
+	}
+	author, _ := strconv.Atoi(post.Author)
+	if _, err = m.notifyMentions(id, 0, author); err != nil {
+		m.errorLog.Print(err)
+	}
+	post.Author, _ = m.usersRepo.GetUserName(post.Author)
+
+	if err = m.live.Publish(live.FeedTopic, "post", post); err != nil {
+		m.errorLog.Print(err)
+	}
+	if err = m.hooks.Emit(webhook.PostCreated, postEvent{Post: post, URL: postURL(m.cfg, id)}); err != nil {
+		m.errorLog.Print(err)
+	}
+	return nil
+}
+
+// needsReview reports whether posts of the author have to pass the moderation
+// queue: accounts younger than HoldAge or below HoldTrust are held.
+func (m *postsUsecase) needsReview(author int) (bool, error) {
+	if isModerator(m.cfg, m.usersRepo, author) {
+		return false, nil
+	}
+
+	created, err := m.usersRepo.GetUserCreated(author)
+	if err != nil {
+		return false, err
+	}
+	if time.Since(created) < m.cfg.HoldAge {
+		return true, nil
+	}
+
+	score, err := m.usersRepo.GetTrustScore(author)
+	if err != nil {
+		return false, err
+	}
+	return score < m.cfg.HoldTrust, nil
+}
+
+func (m *postsUsecase) Pending() (map[int]*models.Post, error) {
+	return m.postsRepo.Pending()
+}
+
+func (m *postsUsecase) Approve(id int) error {
+	err := m.postsRepo.Approve(id)
+	if err != nil {
+		return err
+	}
+	// Approved scheduled posts still wait for their time.
+	post, err := m.postsRepo.Get(id)
+	if err != nil {
+		return err
+	}
+	if post.PublishAt != nil {
+		return nil
+	}
+	return m.published(id)
+}
+
+// Reject deletes the post with its image and its attachments. Files which
+// can't be deleted now are left to CleanupImages.
+func (m *postsUsecase) Reject(id int) error {
+	post, err := m.postsRepo.Get(id)
+	if err != nil {
+		return err
+	}
+	attachments, err := m.postsRepo.Attachments(id)
+	if err != nil {
+		return err
+	}
+
+	if err = m.postsRepo.Delete(id); err != nil {
+		return err
+	}
+	if key := mediaKey(m.media, post.Image, postImagePrefix); key != "" {
+		m.media.Delete(key)
+	}
+	m.DeleteAttachments(attachments)
+	return nil
 }
 
 func (m *postsUsecase) CategoryInsert(postid int64, categories []string) error {
@@ -43,14 +215,72 @@ func (m *postsUsecase) CategoryInsert(postid int64, categories []string) error {
 }
 
 func (m *postsUsecase) Get(id int) (*models.Post, error) {
-	return m.postsRepo.Get(id)
+	post, err := m.postsRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := m.postsRepo.Mentions(id)
+	if err != nil {
+		return nil, err
+	}
+	post.Mentions = mentions[0]
+	stored, err := m.postsRepo.Rendered(id, markdown.Version)
+	if err != nil {
+		return nil, err
+	}
+	post.HTML, err = m.rendered(stored, id, 0, post.Content, post.Mentions)
+	if err != nil {
+		return nil, err
+	}
+	post.Attachments, err = m.postsRepo.Attachments(id)
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
 }
 
-
 func (m *postsUsecase) Latest() (map[int]*models.Post, error) {
 	return m.postsRepo.Latest()
 }
 
+// List returns a page of published posts and the number of posts matching the
+// filter.
+func (m *postsUsecase) List(f models.PostFilter) ([]*models.Post, int, error) {
+	posts, err := m.postsRepo.List(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := m.postsRepo.Count(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return posts, total, nil
+}
+
+// Render fills in the HTML of the posts of a list, which leaves it out.
+func (m *postsUsecase) Render(posts []*models.Post) error {
+	for _, post := range posts {
+		mentions, err := m.postsRepo.Mentions(post.ID)
+		if err != nil {
+			return err
+		}
+		post.Mentions = mentions[0]
+		stored, err := m.postsRepo.Rendered(post.ID, markdown.Version)
+		if err != nil {
+			return err
+		}
+		post.HTML, err = m.rendered(stored, post.ID, 0, post.Content, post.Mentions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *postsUsecase) GetComment(id int) (*models.PostComments, error) {
+	return m.postsRepo.GetComment(id)
+}
+
 func (m *postsUsecase) FilteredPosts(categories []string) (map[int]*models.Post, error) {
 	posts, err := m.postsRepo.FilteredPosts(categories)
 	if err != nil {
@@ -88,11 +318,24 @@ func containsAllCategories(tags []string, categories []string) bool {
 }
 
 func (m *postsUsecase) LikeInsert(likeData models.UserLikeData, likedBy int) error {
-	return m.postsRepo.LikeInsert(likeData, likedBy)
+	err := m.postsRepo.LikeInsert(likeData, likedBy)
+	if err != nil {
+		return err
+	}
+	if likeData.IsLiked {
+		if err = m.notifyLike(likeData.ID, 0, likedBy); err != nil {
+			return err
+		}
+	}
+	return m.votesChanged(likeData.ID)
 }
 
 func (m *postsUsecase) DislikeInsert(dislikeData models.UserDislikeData, likedBy int) error {
-	return m.postsRepo.DislikeInsert(dislikeData, likedBy)
+	err := m.postsRepo.DislikeInsert(dislikeData, likedBy)
+	if err != nil {
+		return err
+	}
+	return m.votesChanged(dislikeData.ID)
 }
 
 func (m *postsUsecase) IsLikedByUser(user int, postid int) bool {
@@ -103,16 +346,242 @@ func (m *postsUsecase) IsDislikedByUser(user int, postid int) bool {
 	return m.postsRepo.IsDislikedByUser(user, postid)
 }
 
-func (m *postsUsecase) CommentInsert(comment string, commentBy int, postId int) error {
-	return m.postsRepo.CommentInsert(comment, commentBy, postId)
+func (m *postsUsecase) CommentInsert(comment string, commentBy int, postId int) (int, error) {
+	content := &filter.Content{Author: commentBy, Body: comment}
+	result, err := m.filter.Check(content)
+	if err != nil {
+		return 0, err
+	}
+	if result.Verdict == filter.Reject {
+		return 0, models.ErrRejectedContent
+	}
+
+	id, err := m.postsRepo.CommentInsert(content.Body, commentBy, postId)
+	if err != nil {
+		return 0, err
+	}
+	// The comment exists from here on, see Insert.
+	if err = m.postsRepo.MentionInsert(postId, id, mention.Names(content.Body)); err != nil {
+		return id, err
+	}
+	mentions, err := m.postsRepo.Mentions(postId)
+	if err != nil {
+		return id, err
+	}
+	if _, err = m.rendered(nil, postId, id, content.Body, mentions[id]); err != nil {
+		return id, err
+	}
+	if result.Verdict == filter.Hold {
+		if err = m.postsRepo.HoldComment(id); err != nil {
+			return id, err
+		}
+		return id, nil
+	}
+	return id, m.commentPublished(id)
+}
+
+// commentPublished sends a published comment to the viewers of the post,
+// notifies the mentioned users, the author of the post and the other
+// commenters and queues the comment.created webhooks. Like published(), it
+// logs the failures of these steps.
+func (m *postsUsecase) commentPublished(id int) error {
+	comment, err := m.postsRepo.GetComment(id)
+	if err != nil {
+		return err
+	}
+	actor, _ := strconv.Atoi(comment.Author)
+	mentioned, err := m.notifyMentions(comment.PostID, comment.Id, actor)
+	if err != nil {
+		m.errorLog.Print(err)
+	}
+	if err = m.notifyComment(comment, mentioned); err != nil {
+		m.errorLog.Print(err)
+	}
+
+	mentions, err := m.postsRepo.Mentions(comment.PostID)
+	if err != nil {
+		m.errorLog.Print(err)
+	}
+	comment.Mentions = mentions[comment.Id]
+	stored, err := m.postsRepo.Rendered(comment.PostID, markdown.Version)
+	if err != nil {
+		m.errorLog.Print(err)
+	}
+	comment.HTML, err = m.rendered(stored, comment.PostID, comment.Id, comment.Comment, comment.Mentions)
+	if err != nil {
+		m.errorLog.Print(err)
+	}
+	comment.Author, _ = m.usersRepo.GetUserName(comment.Author)
+
+	if err = m.live.Publish(live.PostTopic(comment.PostID), "comment", comment); err != nil {
+		m.errorLog.Print(err)
+	}
+	if err = m.hooks.Emit(webhook.CommentCreated, commentEvent{Comment: comment, URL: postURL(m.cfg, comment.PostID)}); err != nil {
+		m.errorLog.Print(err)
+	}
+	return nil
+}
+
+// notifyMentions sends a mention notification to the users mentioned in the
+// post, or in its comment when commentID isn't 0, and returns them.
+func (m *postsUsecase) notifyMentions(postID int, commentID int, actor int) ([]int, error) {
+	mentioned, err := m.postsRepo.Mentioned(postID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range mentioned {
+		err = m.notify.Notify(models.Notification{
+			UserID:    user,
+			Type:      models.NotifyMention,
+			ActorID:   actor,
+			PostID:    postID,
+			CommentID: commentID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mentioned, nil
+}
+
+// notifyComment sends a comment notification to the author of the post and a
+// reply notification to the users who commented on it before. The mentioned
+// users already got a mention notification and are skipped.
+func (m *postsUsecase) notifyComment(comment *models.PostComments, mentioned []int) error {
+	post, err := m.postsRepo.Get(comment.PostID)
+	if err != nil {
+		return err
+	}
+	owner, _ := strconv.Atoi(post.Author)
+	actor, _ := strconv.Atoi(comment.Author)
+
+	skip := map[int]bool{}
+	for _, user := range mentioned {
+		skip[user] = true
+	}
+
+	if !skip[owner] {
+		err = m.notify.Notify(models.Notification{
+			UserID:    owner,
+			Type:      models.NotifyComment,
+			ActorID:   actor,
+			PostID:    comment.PostID,
+			CommentID: comment.Id,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	commenters, err := m.postsRepo.Commenters(comment.PostID)
+	if err != nil {
+		return err
+	}
+	for _, user := range commenters {
+		if user == owner || skip[user] {
+			continue
+		}
+		err = m.notify.Notify(models.Notification{
+			UserID:    user,
+			Type:      models.NotifyReply,
+			ActorID:   actor,
+			PostID:    comment.PostID,
+			CommentID: comment.Id,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *postsUsecase) PendingComments() ([]*models.PostComments, error) {
+	return m.postsRepo.PendingComments()
+}
+
+func (m *postsUsecase) ApproveComment(id int) error {
+	err := m.postsRepo.ApproveComment(id)
+	if err != nil {
+		return err
+	}
+	return m.commentPublished(id)
+}
+
+// Report records the report of the post by the user. Repeated reports of the
+// same post by a user are ignored.
+func (m *postsUsecase) Report(postid int, user int, reason string) error {
+	post, err := m.postsRepo.Get(postid)
+	if err != nil {
+		return err
+	}
+
+	added, err := m.postsRepo.ReportInsert(postid, user, reason)
+	if err != nil || !added {
+		return err
+	}
+
+	post.Author, _ = m.usersRepo.GetUserName(post.Author)
+	reporter, _ := m.usersRepo.GetUserName(strconv.Itoa(user))
+
+	// The report is stored, so a failed webhook is only logged.
+	err = m.hooks.Emit(webhook.PostReported, reportEvent{
+		Post:     post,
+		Reporter: reporter,
+		Reason:   reason,
+		URL:      postURL(m.cfg, postid),
+	})
+	if err != nil {
+		m.errorLog.Print(err)
+	}
+	return nil
+}
+
+func (m *postsUsecase) Reports() ([]*models.Report, error) {
+	return m.postsRepo.Reports()
+}
+
+func (m *postsUsecase) DismissReports(postid int) error {
+	return m.postsRepo.DeleteReports(postid)
+}
+
+func (m *postsUsecase) RejectComment(id int) error {
+	return m.postsRepo.DeleteComment(id)
 }
 
 func (m *postsUsecase) GetComments(postId int, user int) ([]*models.PostComments, error) {
-	return m.postsRepo.GetComments(postId, user)
+	comments, err := m.postsRepo.GetComments(postId, user)
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := m.postsRepo.Mentions(postId)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := m.postsRepo.Rendered(postId, markdown.Version)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		comment.Mentions = mentions[comment.Id]
+		comment.HTML, err = m.rendered(stored, postId, comment.Id, comment.Comment, comment.Mentions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return comments, nil
 }
 
 func (m *postsUsecase) CommentLikeInsert(likeData models.CommentLikeData, likedBy int) error {
-	return m.postsRepo.CommentLikeInsert(likeData, likedBy)
+	err := m.postsRepo.CommentLikeInsert(likeData, likedBy)
+	if err != nil {
+		return err
+	}
+	if likeData.IsLiked {
+		if err = m.notifyLike(0, likeData.ID, likedBy); err != nil {
+			return err
+		}
+	}
+	return m.commentVotesChanged(likeData.ID)
 }
 
 func (m *postsUsecase) IsCommentLikedByUser(user int, commentid int) bool {
@@ -120,7 +589,11 @@ func (m *postsUsecase) IsCommentLikedByUser(user int, commentid int) bool {
 }
 
 func (m *postsUsecase) CommentDislikeInsert(dislikeData models.CommentDislikeData, dislikedBy int) error {
-	return m.postsRepo.CommentDislikeInsert(dislikeData, dislikedBy)
+	err := m.postsRepo.CommentDislikeInsert(dislikeData, dislikedBy)
+	if err != nil {
+		return err
+	}
+	return m.commentVotesChanged(dislikeData.ID)
 }
 
 func (m *postsUsecase) IsCommentDislikedByUser(user int, commentid int) bool {
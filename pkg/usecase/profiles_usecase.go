@@ -0,0 +1,25 @@
+package usecase
+
+import (
+	"forum.bbilisbe/internal/models"
+)
+
+func (m *userUsecase) GetProfile(name string) (*models.Profile, error) {
+	return m.usersRepo.GetProfile(name)
+}
+
+func (m *userUsecase) GetProfilePosts(user, limit int) ([]*models.Post, error) {
+	return m.usersRepo.GetProfilePosts(user, limit)
+}
+
+func (m *userUsecase) GetProfileComments(user, limit int) ([]*models.ProfileComment, error) {
+	return m.usersRepo.GetProfileComments(user, limit)
+}
+
+func (m *userUsecase) GetProfileLikes(user, limit int) ([]*models.Post, error) {
+	return m.usersRepo.GetProfileLikes(user, limit)
+}
+
+func (m *userUsecase) UpdateProfile(user int, form models.ProfileForm) error {
+	return m.usersRepo.UpdateProfile(user, form)
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"forum.bbilisbe/internal/models"
+)
+
+func (m *postsUsecase) Scheduled(author int) ([]*models.Post, error) {
+	return m.postsRepo.Scheduled(author)
+}
+
+// scheduledPost loads the post of the author waiting to be published. Posts
+// of others and the published ones are reported as models.ErrNoRecord.
+func (m *postsUsecase) scheduledPost(id int, author int) (*models.Post, error) {
+	post, err := m.postsRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if post.Author != strconv.Itoa(author) || post.PublishAt == nil {
+		return nil, models.ErrNoRecord
+	}
+	return post, nil
+}
+
+// Reschedule moves the scheduled post of the author to another time.
+func (m *postsUsecase) Reschedule(id int, author int, at time.Time) error {
+	if _, err := m.scheduledPost(id, author); err != nil {
+		return err
+	}
+	return m.postsRepo.Schedule(id, at)
+}
+
+// PublishNow publishes the scheduled post of the author without waiting.
+func (m *postsUsecase) PublishNow(id int, author int) error {
+	post, err := m.scheduledPost(id, author)
+	if err != nil {
+		return err
+	}
+	return m.publishScheduled(post.ID)
+}
+
+// PublishScheduled publishes the scheduled posts whose time has come. It runs
+// from the job queue every half minute; the posts left when the context ends
+// are published on the next run.
+func (m *postsUsecase) PublishScheduled(ctx context.Context) error {
+	ids, err := m.postsRepo.DueScheduled()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, id := range ids {
+		if err = ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if err = m.publishScheduled(id); err != nil && !errors.Is(err, models.ErrNoRecord) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publishScheduled removes the schedule of the post and, unless it is still
+// held for moderation, announces it like a new post. The post is only
+// announced by the caller whose Unschedule removed the schedule, so a post
+// published at once while the job runs is announced once. Held posts are
+// announced once approved.
+func (m *postsUsecase) publishScheduled(id int) error {
+	if err := m.postsRepo.Unschedule(id); err != nil {
+		return err
+	}
+	post, err := m.postsRepo.Get(id)
+	if err != nil {
+		return err
+	}
+	if post.Pending {
+		return nil
+	}
+	return m.published(id)
+}
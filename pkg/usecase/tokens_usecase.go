@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"forum.bbilisbe/internal/models"
+)
+
+// apiTokenPrefix marks the personal API tokens so they are easy to recognise,
+// e.g. by secret scanners.
+const apiTokenPrefix = "fpat_"
+
+// CreateAPIToken generates a token for the user and returns it. Only the hash
+// of the token is stored, so it can't be shown again.
+func (m *userUsecase) CreateAPIToken(user int, form models.APITokenForm) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	err := m.usersRepo.APITokenInsert(user, form.Name, hashAPIToken(token), token[:len(apiTokenPrefix)+6], form.Scopes)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (m *userUsecase) GetAPITokens(user int) ([]*models.APIToken, error) {
+	return m.usersRepo.GetAPITokens(user)
+}
+
+func (m *userUsecase) RevokeAPIToken(user int, id int) error {
+	return m.usersRepo.DeleteAPIToken(user, id)
+}
+
+func (m *userUsecase) AuthenticateAPIToken(token string) (*models.APIToken, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, models.ErrInvalidToken
+	}
+
+	t, err := m.usersRepo.GetAPITokenByHash(hashAPIToken(token))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, models.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	err = m.usersRepo.TouchAPIToken(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,26 +1,41 @@
 package usecase
 
 import (
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"forum.bbilisbe/internal/config"
 	"forum.bbilisbe/internal/cookies"
 	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/webhook"
 )
 
 type userUsecase struct {
 	postsRepo models.PostRepository
 	usersRepo models.UserRepository
+	hooks     models.WebhookUsecases
+	media     models.MediaStore
+	cfg       *config.Config
+	errorLog  *log.Logger
 }
 
-func NewUserUsecase(p models.PostRepository, u models.UserRepository) models.UserUsecases {
+func NewUserUsecase(p models.PostRepository, u models.UserRepository, w models.WebhookUsecases, store models.MediaStore, cfg *config.Config, errorLog *log.Logger) models.UserUsecases {
 	return &userUsecase{
 		postsRepo: p,
 		usersRepo: u,
+		hooks:     w,
+		media:     store,
+		cfg:       cfg,
+		errorLog:  errorLog,
 	}
 }
 
 func (m *userUsecase) IsLogged(r *http.Request) bool {
+	if _, ok := r.Context().Value(models.APITokenContextKey).(*models.APIToken); ok {
+		return true
+	}
 	cookie, errC := cookies.GetCookie(r)
 
 	var data bool
@@ -32,8 +47,34 @@ func (m *userUsecase) IsLogged(r *http.Request) bool {
 	return data
 }
 
+// Insert signs the user up. In invite only mode users can only sign up with
+// InsertWithInvite.
 func (m *userUsecase) Insert(username, email, password string) error {
-	return m.usersRepo.Insert(username, email, password)
+	if m.cfg.InviteOnly {
+		return models.ErrInviteRequired
+	}
+	err := m.usersRepo.Insert(username, email, password)
+	if err != nil {
+		return err
+	}
+
+	// The user is signed up, so failures past this point are only logged.
+	id, err := m.usersRepo.GetUserInfo(email, username)
+	if err != nil {
+		m.errorLog.Print(err)
+		return nil
+	}
+	m.emitSignup(id, username)
+	return nil
+}
+
+// emitSignup queues the user.signed_up webhooks. The signup is stored by
+// then, so a failure is logged rather than returned.
+func (m *userUsecase) emitSignup(id int, name string) {
+	err := m.hooks.Emit(webhook.UserSignedUp, userEvent{ID: id, Name: name})
+	if err != nil {
+		m.errorLog.Print(err)
+	}
 }
 
 func (m *userUsecase) Authenticate(email, password string) (int, error) {
@@ -52,6 +93,10 @@ func (m *userUsecase) RemoveToken(token string) error {
 	return m.usersRepo.RemoveToken(token)
 }
 
+func (m *userUsecase) RemoveExpiredTokens() error {
+	return m.usersRepo.RemoveExpiredTokens()
+}
+
 func (m *userUsecase) GetToken(token string) (string, error) {
 	return m.usersRepo.GetToken(token)
 }
@@ -62,7 +107,12 @@ func (m *userUsecase) IsExpired(token string) bool {
 
 }
 
+// GetUserId returns the user of the session cookie or of the API token the
+// request was authenticated with.
 func (m *userUsecase) GetUserId(r *http.Request) (int, error) {
+	if token, ok := r.Context().Value(models.APITokenContextKey).(*models.APIToken); ok {
+		return token.UserID, nil
+	}
 	return m.usersRepo.GetUserId(r)
 }
 func (m *userUsecase) GetUserName(id string) (string, error) {
@@ -80,3 +130,42 @@ func (m *userUsecase) GetUserPosts(author int) (map[int]*models.Post, error) {
 func (m *userUsecase) GetUserLikes(user int) (map[int]*models.Post, error) {
 	return m.usersRepo.GetUserLikes(user)
 }
+
+func (m *userUsecase) GetUser(id int) (*models.User, error) {
+	return m.usersRepo.GetUser(id)
+}
+
+func (m *userUsecase) GetUserIdByName(name string) (int, error) {
+	return m.usersRepo.GetUserIdByName(name)
+}
+
+func (m *userUsecase) SearchUsernames(prefix string, limit int) ([]string, error) {
+	return m.usersRepo.SearchUsernames(prefix, limit)
+}
+
+func (m *userUsecase) IsModerator(id int) bool {
+	return isModerator(m.cfg, m.usersRepo, id)
+}
+
+func (m *userUsecase) IsAdmin(id int) bool {
+	return hasRole(m.cfg.Admins, m.usersRepo, id)
+}
+
+// isModerator reports whether the user may review held content. Admins are
+// moderators as well.
+func isModerator(cfg *config.Config, usersRepo models.UserRepository, id int) bool {
+	return hasRole(cfg.Moderators, usersRepo, id) || hasRole(cfg.Admins, usersRepo, id)
+}
+
+func hasRole(names []string, usersRepo models.UserRepository, id int) bool {
+	name, err := usersRepo.GetUserName(strconv.Itoa(id))
+	if err != nil {
+		return false
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"strconv"
+
+	"forum.bbilisbe/internal/live"
+	"forum.bbilisbe/internal/models"
+)
+
+// SetLike likes or unlikes the post on behalf of the user. Unlike LikeInsert,
+// which stores the count sent by the page, the count is changed in the
+// database along with the vote, and returned as it is afterwards.
+func (m *postsUsecase) SetLike(postID int, user int, liked bool) (*models.UserLikeData, error) {
+	if _, err := m.postsRepo.Get(postID); err != nil {
+		return nil, err
+	}
+	changed, err := m.postsRepo.SetLike(postID, user, liked)
+	if err != nil {
+		return nil, err
+	}
+	post, err := m.postsRepo.Get(postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		if liked {
+			if err = m.notifyLike(postID, 0, user); err != nil {
+				m.errorLog.Print(err)
+			}
+		}
+		if err = m.votesChanged(postID); err != nil {
+			m.errorLog.Print(err)
+		}
+	}
+	return &models.UserLikeData{ID: postID, Likes: post.Likes, IsLiked: liked}, nil
+}
+
+func (m *postsUsecase) SetDislike(postID int, user int, disliked bool) (*models.UserDislikeData, error) {
+	if _, err := m.postsRepo.Get(postID); err != nil {
+		return nil, err
+	}
+	changed, err := m.postsRepo.SetDislike(postID, user, disliked)
+	if err != nil {
+		return nil, err
+	}
+	post, err := m.postsRepo.Get(postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		if err = m.votesChanged(postID); err != nil {
+			m.errorLog.Print(err)
+		}
+	}
+	return &models.UserDislikeData{ID: postID, Dislikes: post.Dislikes, IsDisliked: disliked}, nil
+}
+
+func (m *postsUsecase) SetCommentLike(commentID int, user int, liked bool) (*models.CommentLikeData, error) {
+	if _, err := m.postsRepo.GetComment(commentID); err != nil {
+		return nil, err
+	}
+	changed, err := m.postsRepo.SetCommentLike(commentID, user, liked)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := m.postsRepo.GetComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		if liked {
+			if err = m.notifyLike(0, commentID, user); err != nil {
+				m.errorLog.Print(err)
+			}
+		}
+		if err = m.commentVotesChanged(commentID); err != nil {
+			m.errorLog.Print(err)
+		}
+	}
+	return &models.CommentLikeData{ID: commentID, PostId: comment.PostID, Likes: comment.Likes, IsLiked: liked}, nil
+}
+
+func (m *postsUsecase) SetCommentDislike(commentID int, user int, disliked bool) (*models.CommentDislikeData, error) {
+	if _, err := m.postsRepo.GetComment(commentID); err != nil {
+		return nil, err
+	}
+	changed, err := m.postsRepo.SetCommentDislike(commentID, user, disliked)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := m.postsRepo.GetComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		if err = m.commentVotesChanged(commentID); err != nil {
+			m.errorLog.Print(err)
+		}
+	}
+	return &models.CommentDislikeData{ID: commentID, PostId: comment.PostID, Dislikes: comment.Dislikes, IsDisliked: disliked}, nil
+}
+
+// postVotes is the live event sent when the votes of a post change.
+type postVotes struct {
+	ID       int `json:"postID"`
+	Likes    int `json:"likeCount"`
+	Dislikes int `json:"dislikeCount"`
+}
+
+// commentVotes is the live event sent when the votes of a comment change.
+type commentVotes struct {
+	ID       int `json:"commentID"`
+	PostID   int `json:"postID"`
+	Likes    int `json:"commentLikeCount"`
+	Dislikes int `json:"commentDislikeCount"`
+}
+
+// votesChanged sends the new counts of the post to its viewers and, once the
+// post is published, to the home feed.
+func (m *postsUsecase) votesChanged(postID int) error {
+	post, err := m.postsRepo.Get(postID)
+	if err != nil {
+		return err
+	}
+	votes := postVotes{ID: post.ID, Likes: post.Likes, Dislikes: post.Dislikes}
+
+	err = m.live.Publish(live.PostTopic(postID), "votes", votes)
+	if err != nil || post.Hidden() {
+		return err
+	}
+	return m.live.Publish(live.FeedTopic, "votes", votes)
+}
+
+func (m *postsUsecase) commentVotesChanged(commentID int) error {
+	comment, err := m.postsRepo.GetComment(commentID)
+	if err != nil {
+		return err
+	}
+	return m.live.Publish(live.PostTopic(comment.PostID), "commentVotes", commentVotes{
+		ID:       comment.Id,
+		PostID:   comment.PostID,
+		Likes:    comment.Likes,
+		Dislikes: comment.Dislikes,
+	})
+}
+
+// notifyLike sends a vote notification to the author of the liked post, or of
+// the liked comment when commentID isn't 0, whose post is looked up.
+func (m *postsUsecase) notifyLike(postID int, commentID int, user int) error {
+	var author string
+	if commentID != 0 {
+		comment, err := m.postsRepo.GetComment(commentID)
+		if err != nil {
+			return err
+		}
+		postID, author = comment.PostID, comment.Author
+	} else {
+		post, err := m.postsRepo.Get(postID)
+		if err != nil {
+			return err
+		}
+		author = post.Author
+	}
+	owner, _ := strconv.Atoi(author)
+
+	return m.notify.Notify(models.Notification{
+		UserID:    owner,
+		Type:      models.NotifyVote,
+		ActorID:   user,
+		PostID:    postID,
+		CommentID: commentID,
+	})
+}
@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/webhook"
+)
+
+const (
+	webhookTimeout     = 10 * time.Second
+	webhookBatch       = 20
+	webhookMaxAttempts = 10
+	webhookMaxError    = 500
+)
+
+type webhookUsecase struct {
+	webhookRepo models.WebhookRepository
+	sender      *webhook.Sender
+	cfg         *config.Config
+}
+
+func NewWebhookUsecase(w models.WebhookRepository, cfg *config.Config) models.WebhookUsecases {
+	return &webhookUsecase{
+		webhookRepo: w,
+		sender:      &webhook.Sender{Client: &http.Client{Timeout: webhookTimeout}},
+		cfg:         cfg,
+	}
+}
+
+func (m *webhookUsecase) Webhooks() ([]*models.Webhook, error) {
+	return m.webhookRepo.List()
+}
+
+// WebhookInsert adds the webhook, generating a secret when none is given.
+func (m *webhookUsecase) WebhookInsert(form models.WebhookForm) error {
+	if form.Secret == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		form.Secret = hex.EncodeToString(b)
+	}
+	return m.webhookRepo.Insert(form.URL, form.Secret, form.Events)
+}
+
+func (m *webhookUsecase) WebhookDelete(id int) error {
+	return m.webhookRepo.Delete(id)
+}
+
+func (m *webhookUsecase) Deliveries(limit int) ([]*models.WebhookDelivery, error) {
+	return m.webhookRepo.Deliveries(limit)
+}
+
+// Emit queues the event for the webhooks subscribed to it. The data becomes
+// the data field of the payload.
+func (m *webhookUsecase) Emit(event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return m.webhookRepo.Enqueue(event, payload)
+}
+
+// Deliver sends the due deliveries. Failed attempts are retried with an
+// exponential backoff until webhookMaxAttempts is reached. When the context
+// ends the remaining deliveries are left for the next call, the interrupted
+// one without counting the attempt.
+func (m *webhookUsecase) Deliver(ctx context.Context) error {
+	due, err := m.webhookRepo.Due(webhookBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range due {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		code, err := m.sender.Send(ctx, d.URL, d.Secret, webhook.Payload{
+			ID:      d.ID,
+			Event:   d.Event,
+			Created: d.Created,
+			Data:    d.Payload,
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			err = m.webhookRepo.Delivered(d.ID, code)
+		} else if d.Attempts+1 >= webhookMaxAttempts {
+			err = m.webhookRepo.Failed(d.ID, code, truncate(err.Error(), webhookMaxError))
+		} else {
+			err = m.webhookRepo.Retry(d.ID, code, truncate(err.Error(), webhookMaxError), webhook.Backoff(d.Attempts+1))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
+
+// The data of the webhook events.
+
+type postEvent struct {
+	Post *models.Post `json:"post"`
+	URL  string       `json:"url,omitempty"`
+}
+
+type commentEvent struct {
+	Comment *models.PostComments `json:"comment"`
+	URL     string               `json:"url,omitempty"`
+}
+
+type reportEvent struct {
+	Post     *models.Post `json:"post"`
+	Reporter string       `json:"reporter"`
+	Reason   string       `json:"reason"`
+	URL      string       `json:"url,omitempty"`
+}
+
+type userEvent struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// postURL returns the address of the post when the public address of the
+// forum is configured.
+func postURL(cfg *config.Config, id int) string {
+	if cfg.BaseURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/post/view/%d", cfg.BaseURL, id)
+}
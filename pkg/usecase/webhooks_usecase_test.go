@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum.bbilisbe/internal/config"
+	"forum.bbilisbe/internal/models"
+	"forum.bbilisbe/internal/webhook"
+)
+
+// memoryWebhooks keeps the deliveries of the tests, due ones are those
+// pending without a later next attempt.
+type memoryWebhooks struct {
+	deliveries []*models.WebhookDelivery
+}
+
+func (m *memoryWebhooks) Insert(string, string, []string) error { return nil }
+func (m *memoryWebhooks) Delete(int) error                      { return nil }
+func (m *memoryWebhooks) List() ([]*models.Webhook, error)      { return nil, nil }
+func (m *memoryWebhooks) Enqueue(string, []byte) error          { return nil }
+func (m *memoryWebhooks) Deliveries(int) ([]*models.WebhookDelivery, error) {
+	return m.deliveries, nil
+}
+
+func (m *memoryWebhooks) Due(limit int) ([]*models.WebhookDelivery, error) {
+	var due []*models.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == models.DeliveryPending && (d.NextAttempt == nil || !d.NextAttempt.After(time.Now())) {
+			c := *d
+			due = append(due, &c)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryWebhooks) get(id int) *models.WebhookDelivery {
+	for _, d := range m.deliveries {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (m *memoryWebhooks) Delivered(id int, code int) error {
+	d := m.get(id)
+	now := time.Now()
+	d.Status, d.ResponseCode, d.Delivered = models.DeliveryDelivered, code, &now
+	d.Attempts++
+	return nil
+}
+
+func (m *memoryWebhooks) Retry(id int, code int, message string, wait time.Duration) error {
+	d := m.get(id)
+	next := time.Now().Add(wait)
+	d.ResponseCode, d.Error, d.NextAttempt = code, message, &next
+	d.Attempts++
+	return nil
+}
+
+func (m *memoryWebhooks) Failed(id int, code int, message string) error {
+	d := m.get(id)
+	d.Status, d.ResponseCode, d.Error = models.DeliveryFailed, code, message
+	d.Attempts++
+	return nil
+}
+
+func TestDeliverRetries(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		if !webhook.Verify("secret", body, r.Header.Get(webhook.SignatureHeader)) {
+			t.Error("bad signature")
+		}
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	repo := &memoryWebhooks{deliveries: []*models.WebhookDelivery{{
+		ID:      1,
+		URL:     server.URL,
+		Secret:  "secret",
+		Event:   webhook.PostCreated,
+		Payload: []byte(`{}`),
+		Status:  models.DeliveryPending,
+		Created: time.Now(),
+	}}}
+	uc := NewWebhookUsecase(repo, &config.Config{})
+	d := repo.deliveries[0]
+
+	if err := uc.Deliver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != models.DeliveryPending || d.ResponseCode != http.StatusInternalServerError || d.NextAttempt == nil {
+		t.Fatalf("after a 500: status %s, code %d, next attempt %v", d.Status, d.ResponseCode, d.NextAttempt)
+	}
+	if wait := time.Until(*d.NextAttempt); wait < 29*time.Second || wait > webhook.Backoff(1) {
+		t.Errorf("retried in %v, want %v", wait, webhook.Backoff(1))
+	}
+
+	// Not due before the backoff passed.
+	if err := uc.Deliver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Fatalf("%d requests before the retry is due", requests)
+	}
+
+	past := time.Now().Add(-time.Second)
+	d.NextAttempt = &past
+	if err := uc.Deliver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 || d.Status != models.DeliveryDelivered || d.ResponseCode != http.StatusOK || d.Attempts != 2 {
+		t.Errorf("after the retry: %d requests, status %s, code %d, %d attempts", requests, d.Status, d.ResponseCode, d.Attempts)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	repo := &memoryWebhooks{deliveries: []*models.WebhookDelivery{{
+		ID:       1,
+		URL:      server.URL,
+		Event:    webhook.PostCreated,
+		Payload:  []byte(`{}`),
+		Status:   models.DeliveryPending,
+		Attempts: webhookMaxAttempts - 1,
+	}}}
+	if err := NewWebhookUsecase(repo, &config.Config{}).Deliver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := repo.deliveries[0]; d.Status != models.DeliveryFailed || d.Error == "" {
+		t.Errorf("after the last attempt: status %s, error %q", d.Status, d.Error)
+	}
+}
@@ -0,0 +1,73 @@
+// Choosing the square of the picture kept as the avatar: the selection is
+// dragged over the preview and resized with the slider, the hidden fields get
+// its position and size in pixels of the picture.
+const avatarInput = document.getElementById("avatarInput");
+const cropArea = document.getElementById("avatarCrop");
+const preview = document.getElementById("avatarPreview");
+const selection = document.getElementById("avatarSelection");
+const zoom = document.getElementById("avatarZoom");
+
+let crop = { x: 0, y: 0, size: 0 };
+
+// scale returns the displayed pixels per pixel of the picture.
+function scale() {
+    return preview.clientWidth / preview.naturalWidth;
+}
+
+function showCrop() {
+    const s = scale();
+    selection.style.left = crop.x * s + "px";
+    selection.style.top = crop.y * s + "px";
+    selection.style.width = crop.size * s + "px";
+    selection.style.height = crop.size * s + "px";
+
+    document.getElementById("cropX").value = Math.round(crop.x);
+    document.getElementById("cropY").value = Math.round(crop.y);
+    document.getElementById("cropSize").value = Math.round(crop.size);
+}
+
+// moveCrop keeps the selection inside the picture.
+function moveCrop(x, y) {
+    crop.x = Math.min(Math.max(x, 0), preview.naturalWidth - crop.size);
+    crop.y = Math.min(Math.max(y, 0), preview.naturalHeight - crop.size);
+    showCrop();
+}
+
+avatarInput.addEventListener("change", () => {
+    const file = avatarInput.files[0];
+    if (!file) {
+        cropArea.hidden = true;
+        return;
+    }
+    preview.src = URL.createObjectURL(file);
+});
+
+preview.addEventListener("load", () => {
+    cropArea.hidden = false;
+    zoom.value = 100;
+    crop.size = Math.min(preview.naturalWidth, preview.naturalHeight);
+    moveCrop((preview.naturalWidth - crop.size) / 2, (preview.naturalHeight - crop.size) / 2);
+});
+
+zoom.addEventListener("input", () => {
+    const center = { x: crop.x + crop.size / 2, y: crop.y + crop.size / 2 };
+    crop.size = Math.min(preview.naturalWidth, preview.naturalHeight) * zoom.value / 100;
+    moveCrop(center.x - crop.size / 2, center.y - crop.size / 2);
+});
+
+selection.addEventListener("pointerdown", (event) => {
+    event.preventDefault();
+    selection.setPointerCapture(event.pointerId);
+    const start = { x: event.clientX, y: event.clientY, cropX: crop.x, cropY: crop.y };
+
+    const move = (event) => {
+        const s = scale();
+        moveCrop(start.cropX + (event.clientX - start.x) / s, start.cropY + (event.clientY - start.y) / s);
+    };
+    const end = () => {
+        selection.removeEventListener("pointermove", move);
+        selection.removeEventListener("pointerup", end);
+    };
+    selection.addEventListener("pointermove", move);
+    selection.addEventListener("pointerup", end);
+});
@@ -0,0 +1,46 @@
+// Copy buttons of the code blocks in rendered Markdown. The server wraps
+// every line in a span for the line numbers, which are drawn by the CSS and
+// not part of the copied text.
+function addCopyButtons(root) {
+    root.querySelectorAll("div.markdown pre").forEach((pre) => {
+        if (pre.querySelector("button.copy")) {
+            return;
+        }
+        const code = pre.querySelector("code");
+        const button = document.createElement("button");
+        button.type = "button";
+        button.className = "copy";
+        button.textContent = "Copy";
+
+        function done(text) {
+            button.textContent = text;
+            setTimeout(() => {
+                button.textContent = "Copy";
+            }, 1500);
+        }
+
+        button.addEventListener("click", () => {
+            const text = code.textContent;
+            if (navigator.clipboard) {
+                navigator.clipboard.writeText(text)
+                    .then(() => done("Copied"))
+                    .catch(() => select(code));
+                return;
+            }
+            select(code);
+        });
+        pre.append(button);
+    });
+}
+
+// select selects the code so it can be copied by hand when the clipboard
+// isn't available, e.g. on pages served without HTTPS.
+function select(code) {
+    const range = document.createRange();
+    range.selectNodeContents(code);
+    const selection = window.getSelection();
+    selection.removeAllRanges();
+    selection.addRange(range);
+}
+
+addCopyButtons(document);
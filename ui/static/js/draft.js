@@ -0,0 +1,62 @@
+// Autosave of the post being written. Every half minute the form marked with
+// data-draft is saved as a draft through the JSON API when it has changed, so
+// the post isn't lost when the session expires before it is published.
+const autosaveInterval = 30 * 1000;
+
+document.querySelectorAll("form[data-draft]").forEach((form) => {
+    const id = form.elements["draft"];
+    const status = form.querySelector(".draftStatus");
+
+    let changed = false;
+    let saving = false;
+
+    form.addEventListener("input", () => {
+        changed = true;
+    });
+
+    // Publishing or saving the form replaces the autosave.
+    form.addEventListener("submit", () => {
+        changed = false;
+        saving = true;
+    });
+
+    function save() {
+        const title = form.elements["title"].value;
+        const content = form.elements["content"].value;
+        if (!changed || saving || (title.trim() === "" && content.trim() === "")) {
+            return;
+        }
+        changed = false;
+        saving = true;
+
+        const categories = Array.from(form.querySelectorAll("input[name=category]:checked"), (box) => box.value);
+        fetch(id.value ? "/api/v1/drafts/" + id.value : "/api/v1/drafts", {
+            method: id.value ? "PUT" : "POST",
+            body: JSON.stringify({title: title, content: content, categories: categories}),
+            headers: {
+                "Content-Type": "application/json"
+            }
+        })
+        .then(response => response.json().then(data => response.ok ? data : Promise.reject({status: response.status, data: data})))
+        .then(draft => {
+            id.value = draft.id;
+            status.textContent = "Draft saved at " + new Date(draft.updated).toLocaleTimeString();
+        })
+        .catch(error => {
+            changed = true;
+            if (error.status === 401) {
+                status.textContent = "Not saved: your session has expired, log in again in another tab to keep saving.";
+            } else if (error.data && error.data.error) {
+                const fields = Object.values(error.data.error.fields || {});
+                status.textContent = "Not saved: " + (fields.length ? fields[0] : error.data.error.message);
+            } else {
+                console.error("Failed autosave", error);
+            }
+        })
+        .finally(() => {
+            saving = false;
+        });
+    }
+
+    setInterval(save, autosaveInterval);
+});
@@ -0,0 +1,17 @@
+// Live updates of the home page: the like counts of the listed posts and a
+// notice when new posts are published.
+if (window.EventSource) {
+    const events = new EventSource("/events");
+
+    events.addEventListener("votes", (event) => {
+        const votes = JSON.parse(event.data);
+        const row = document.getElementById("post-" + votes.postID);
+        if (row) {
+            row.querySelector(".likeCount").textContent = votes.likeCount;
+        }
+    });
+
+    events.addEventListener("post", (event) => {
+        document.getElementById("newPosts").hidden = false;
+    });
+}
@@ -0,0 +1,88 @@
+// Autocompletion of @mentions in the fields marked with data-mentions. The
+// usernames starting with the typed prefix come from the JSON API.
+const mentionPrefix = /(?:^|[^\p{L}\p{N}_.@])@([\p{L}\p{N}_.-]+)$/u;
+
+document.querySelectorAll("[data-mentions]").forEach((field) => {
+    const list = document.createElement("ul");
+    list.className = "mentionSuggestions";
+    list.hidden = true;
+    field.after(list);
+
+    let timer = null;
+    let selected = -1;
+
+    function close() {
+        list.hidden = true;
+        list.replaceChildren();
+        selected = -1;
+    }
+
+    function complete(name) {
+        const caret = field.selectionStart;
+        const before = field.value.slice(0, caret).replace(/@[\p{L}\p{N}_.-]+$/u, "@" + name + " ");
+        field.value = before + field.value.slice(caret);
+        field.setSelectionRange(before.length, before.length);
+        field.focus();
+        close();
+    }
+
+    function highlight(index) {
+        const items = list.querySelectorAll("li");
+        items.forEach((item, i) => item.classList.toggle("selected", i === index));
+        selected = index;
+    }
+
+    function suggest(prefix) {
+        fetch("/api/v1/users?prefix=" + encodeURIComponent(prefix))
+        .then(response => response.ok ? response.json() : [])
+        .then(names => {
+            list.replaceChildren();
+            selected = -1;
+            names.forEach((name) => {
+                const item = document.createElement("li");
+                item.textContent = name;
+                // mousedown fires before the field loses the focus.
+                item.addEventListener("mousedown", (event) => {
+                    event.preventDefault();
+                    complete(name);
+                });
+                list.append(item);
+            });
+            list.hidden = names.length === 0;
+        })
+        .catch(error => {
+            console.error(error);
+        });
+    }
+
+    field.addEventListener("input", () => {
+        clearTimeout(timer);
+        const match = field.value.slice(0, field.selectionStart).match(mentionPrefix);
+        if (!match) {
+            close();
+            return;
+        }
+        timer = setTimeout(() => suggest(match[1]), 150);
+    });
+
+    field.addEventListener("keydown", (event) => {
+        const items = list.querySelectorAll("li");
+        if (list.hidden || items.length === 0) {
+            return;
+        }
+        if (event.key === "ArrowDown") {
+            event.preventDefault();
+            highlight((selected + 1) % items.length);
+        } else if (event.key === "ArrowUp") {
+            event.preventDefault();
+            highlight((selected - 1 + items.length) % items.length);
+        } else if ((event.key === "Enter" || event.key === "Tab") && selected >= 0) {
+            event.preventDefault();
+            complete(items[selected].textContent);
+        } else if (event.key === "Escape") {
+            close();
+        }
+    });
+
+    field.addEventListener("blur", close);
+});
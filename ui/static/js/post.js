@@ -1,61 +1,50 @@
-
 let likeCnt = +document.getElementById("likeCount").textContent;
 let dislikeCnt = +document.getElementById("dislikeCount").textContent;
 
-
-const commentLikeButtons = document.querySelectorAll(".commentLikeButton")
-const commentDislikeButtons = document.querySelectorAll(".commentDislikeButton")
-const commentLikeCntElement = document.querySelectorAll(".commentLikeCount");
-const commentDislikeCntElement = document.querySelectorAll(".commentDislikeCount");
-const commentLikeCnt = Array.from(commentLikeCntElement).map(element => +element.innerText);
-const commentDislikeCnt = Array.from(commentDislikeCntElement).map(element => +element.innerText);
-
 const postID = +document.getElementById("postID").innerHTML;
+const commentsSection = document.getElementById("comments");
 
 
 document.addEventListener("DOMContentLoaded", function () {
-    
+
     let isLiked = document.getElementById("isLiked").innerHTML;
 	const likeButton = document.getElementById("likeButton");
 	isLiked = (isLiked?.toLowerCase?.() === 'true');
-    
-	likeButton.addEventListener("click",(event) => {
-
-        if (!isLiked) {
-            likeCnt++;
-        } else if (isLiked) {
-            likeCnt--;
-        }
-        isLiked = !isLiked
 
-        let body = { likeCount: likeCnt, postID: postID, isLiked: isLiked }
-        let url = "/post/like"
-        submitLike(body, url, "like")
+	likeButton.addEventListener("click",(event) => {
+        let body = { isLiked: !isLiked }
+        let url = "/api/v1/posts/" + postID + "/like"
+        submitLike(body, url, "like", (data) => {
+            isLiked = data.isLiked;
+            likeCnt = data.likeCount;
+            document.getElementById("likeIcon").src = isLiked ? "/static/img/liked.png" : "/static/img/like.png";
+            document.getElementById("likeCount").textContent = likeCnt;
+        })
     })
 });
 
 document.addEventListener("DOMContentLoaded", function () {
-    
+
     let isDisliked = document.getElementById("isDisliked").innerHTML;
 	const dislikeButton = document.getElementById("dislikeButton");
 	isDisliked = (isDisliked?.toLowerCase?.() === 'true');
-    
-	dislikeButton.addEventListener("click",(event) => {
-
-        if (!isDisliked) {
-            dislikeCnt++;
-        } else if (isDisliked) {
-            dislikeCnt--;
-        }
-        isDisliked = !isDisliked
 
-        let body = { dislikeCount: dislikeCnt, postID: postID, isDisliked: isDisliked }
-        let url = "/post/dislike"
-        submitLike(body, url, "dislike")
+	dislikeButton.addEventListener("click",(event) => {
+        let body = { isDisliked: !isDisliked }
+        let url = "/api/v1/posts/" + postID + "/dislike"
+        submitLike(body, url, "dislike", (data) => {
+            isDisliked = data.isDisliked;
+            dislikeCnt = data.dislikeCount;
+            document.getElementById("dislikeIcon").src = isDisliked ? "/static/img/disliked.png" : "/static/img/dislike.png";
+            document.getElementById("dislikeCount").textContent = dislikeCnt;
+        })
     })
 });
 
-function submitLike(body, url, msg) {
+// submitLike sends the vote to the API and runs done with the vote and the
+// counts it answers with once the vote is stored. The new counts of the other
+// viewers arrive through the event stream.
+function submitLike(body, url, msg, done) {
     fetch(url, {
         method: "POST",
         body: JSON.stringify(body),
@@ -65,10 +54,9 @@ function submitLike(body, url, msg) {
     })
     .then(response => {
         if (response.ok) {
-            location.href = location.href;
-            console.log("Succesfull post "+msg)
+            return response.json().then(done);
         } else {
-            console.error("Failed post "+msg);
+            console.error("Failed "+msg);
         }
     })
     .catch(error => {
@@ -76,60 +64,194 @@ function submitLike(body, url, msg) {
     });
 }
 
-commentLikeButtons.forEach((button, index) => {
-    let commentID = +button.getAttribute("comment-id");
-    let isCommentLiked = button.getAttribute("comment-liked");
-    isCommentLiked = (isCommentLiked?.toLowerCase?.() === 'true');
-    
-    button.addEventListener("click", () => {
-        if (!isCommentLiked) {
-            commentLikeCnt[index]++;
-        } else if (isCommentLiked) {
-            commentLikeCnt[index]--;
-        }
-        isCommentLiked = !isCommentLiked;
-        let body = {commentLikeCount: commentLikeCnt[index], commentID: commentID, postID: postID, isCommentLiked: isCommentLiked}
-        let url = "/post/commentLike"
-        submitCommentLike(body, url, "like");
+// bindCommentButtons attaches the like and dislike handlers to the comment
+// buttons below root.
+function bindCommentButtons(root) {
+    root.querySelectorAll(".commentLikeButton").forEach((button) => {
+        let commentID = +button.getAttribute("comment-id");
+        let isCommentLiked = button.getAttribute("comment-liked");
+        isCommentLiked = (isCommentLiked?.toLowerCase?.() === 'true');
+        const countElement = button.querySelector(".commentLikeCount");
+
+        button.addEventListener("click", () => {
+            let body = {isCommentLiked: !isCommentLiked}
+            let url = "/api/v1/comments/" + commentID + "/like"
+            submitLike(body, url, "comment like", (data) => {
+                isCommentLiked = data.isCommentLiked;
+                button.querySelector(".commentLikeIcon").src = isCommentLiked ? "/static/img/thumbUpClicked.png" : "/static/img/thumbUpUnclicked.png";
+                countElement.textContent = data.commentLikeCount;
+            });
+        })
     })
-})
-
-commentDislikeButtons.forEach((button, index) => {
-    let commentID = +button.getAttribute("comment-id");
-    let isCommentDisliked = button.getAttribute("comment-disliked");
-    isCommentDisliked = (isCommentDisliked?.toLowerCase?.() === 'true');
-    
-    button.addEventListener("click", () => {
-        if (!isCommentDisliked) {
-            commentDislikeCnt[index]++;
-        } else if (isCommentDisliked) {
-            commentDislikeCnt[index]--;
-        }
-        isCommentDisliked = !isCommentDisliked;
 
-        let body = {commentDislikeCount: commentDislikeCnt[index], commentID: commentID, postID: postID, isCommentDisliked: isCommentDisliked}
-        let url = "/post/commentDislike"
-        submitCommentLike(body, url, "dislike");
+    root.querySelectorAll(".commentDislikeButton").forEach((button) => {
+        let commentID = +button.getAttribute("comment-id");
+        let isCommentDisliked = button.getAttribute("comment-disliked");
+        isCommentDisliked = (isCommentDisliked?.toLowerCase?.() === 'true');
+        const countElement = button.querySelector(".commentDislikeCount");
+
+        button.addEventListener("click", () => {
+            let body = {isCommentDisliked: !isCommentDisliked}
+            let url = "/api/v1/comments/" + commentID + "/dislike"
+            submitLike(body, url, "comment dislike", (data) => {
+                isCommentDisliked = data.isCommentDisliked;
+                button.querySelector(".commentDislikeIcon").src = isCommentDisliked ? "/static/img/thumbUpClicked.png" : "/static/img/thumbUpUnclicked.png";
+                countElement.textContent = data.commentDislikeCount;
+            });
+        })
     })
-})
+}
 
-function submitCommentLike(body, url, msg) {
-    fetch(url, {
-        method: "POST",
-        body: JSON.stringify(body),
-        headers: {
-            "Content-Type": "application/json"
+bindCommentButtons(document);
+
+// commentElement builds the markup of a comment received from the event
+// stream, the same as the page renders it.
+function commentElement(comment) {
+    const div = document.createElement("div");
+    div.className = "metadata";
+    div.id = "comment-" + comment.commentID;
+
+    const avatar = document.createElement("img");
+    avatar.className = "avatar";
+    avatar.src = "/avatar/" + encodeURIComponent(comment.author);
+    avatar.alt = "";
+
+    const author = document.createElement("a");
+    author.href = "/u/" + encodeURIComponent(comment.author);
+    author.textContent = comment.author;
+
+    // The HTML is rendered from the Markdown of the comment by the server,
+    // which only lets through the allowed markup.
+    const content = document.createElement("div");
+    content.className = "markdown comment";
+    content.innerHTML = comment.html || "";
+
+    div.append(avatar, author, ": ", content, " ");
+
+    const span = document.createElement("span");
+    span.append(
+        voteButton("commentLikeButton", "comment-liked", "commentLikeIcon", "Like", "commentLikeCount", comment.commentID, comment.commentLikeCount),
+        " ",
+        voteButton("commentDislikeButton", "comment-disliked", "commentDislikeIcon", "Dislike", "commentDislikeCount", comment.commentID, comment.commentDislikeCount),
+    );
+    div.append(span);
+    return div;
+}
+
+function voteButton(buttonClass, stateAttribute, iconClass, alt, countClass, commentID, count) {
+    const button = document.createElement("button");
+    if (commentsSection.dataset.logged === "true") {
+        button.className = buttonClass;
+        button.setAttribute("comment-id", commentID);
+        button.setAttribute(stateAttribute, "false");
+    }
+
+    const icon = document.createElement("img");
+    icon.className = iconClass;
+    icon.src = "/static/img/thumbUpUnclicked.png";
+    icon.alt = alt;
+    icon.width = 30;
+    icon.height = 30;
+
+    const countElement = document.createElement("span");
+    countElement.className = countClass;
+    countElement.textContent = count;
+
+    button.append(icon, countElement);
+    return button;
+}
+
+// Voting in the poll of the post. The answer carries the poll with the
+// results, which replace the ones shown.
+const pollForm = document.querySelector("form.poll");
+if (pollForm) {
+    const pollStatus = pollForm.querySelector(".pollStatus");
+
+    pollForm.addEventListener("submit", (event) => {
+        event.preventDefault();
+        const options = Array.from(pollForm.querySelectorAll("input[name=option]:checked"), (input) => +input.value);
+        if (options.length === 0) {
+            pollStatus.textContent = "Choose an option first.";
+            return;
         }
-    })
-    .then(response => {
-        if (response.ok) {
-            location.href = location.href;
-            console.log("Succesfull comment "+msg);
-        } else {
-            console.error("Failed comment "+msg);
+
+        fetch("/api/v1/posts/" + postID + "/poll", {
+            method: "POST",
+            body: JSON.stringify({options: options}),
+            headers: {
+                "Content-Type": "application/json"
+            }
+        })
+        .then(response => response.json().then(data => response.ok ? data : Promise.reject({status: response.status, data: data})))
+        .then(poll => {
+            showPoll(poll);
+            pollStatus.textContent = "Your vote is counted.";
+        })
+        .catch(error => {
+            if (error.data && error.data.error) {
+                const fields = Object.values(error.data.error.fields || {});
+                pollStatus.textContent = fields.length ? fields[0] : error.data.error.message;
+            } else {
+                console.error("Failed vote", error);
+            }
+        });
+    });
+}
+
+// showPoll updates the bars and the counts of the poll.
+function showPoll(poll) {
+    poll.options.forEach((option) => {
+        const item = pollForm.querySelector("li[data-option='" + option.id + "']");
+        if (!item) {
+            return;
         }
-    })
-    .catch(error => {
-        console.error(error);
+        const progress = item.querySelector("progress");
+        progress.max = poll.voters;
+        progress.value = option.votes;
+        item.querySelector(".votes").textContent = option.votes;
+        item.querySelector(".percent").textContent = poll.voters ? Math.floor(option.votes * 100 / poll.voters) : 0;
+        item.querySelector(".result").hidden = !poll.results;
+    });
+
+    const voters = pollForm.querySelector(".voters");
+    voters.textContent = poll.voters + (poll.voters === 1 ? " voter." : " voters.");
+    voters.hidden = !poll.results;
+    pollForm.querySelector(".hiddenResults").hidden = poll.results;
+    if (poll.voted.length) {
+        pollForm.querySelector("input[type=submit]").value = "Change vote";
+    }
+}
+
+// Live updates of the votes and the comments of the post.
+if (window.EventSource) {
+    const events = new EventSource("/post/events/" + postID);
+
+    events.addEventListener("votes", (event) => {
+        const votes = JSON.parse(event.data);
+        likeCnt = votes.likeCount;
+        dislikeCnt = votes.dislikeCount;
+        document.getElementById("likeCount").textContent = likeCnt;
+        document.getElementById("dislikeCount").textContent = dislikeCnt;
+    });
+
+    events.addEventListener("commentVotes", (event) => {
+        const votes = JSON.parse(event.data);
+        const comment = document.getElementById("comment-" + votes.commentID);
+        if (comment) {
+            comment.querySelector(".commentLikeCount").textContent = votes.commentLikeCount;
+            comment.querySelector(".commentDislikeCount").textContent = votes.commentDislikeCount;
+        }
+    });
+
+    events.addEventListener("comment", (event) => {
+        const comment = JSON.parse(event.data);
+        if (document.getElementById("comment-" + comment.commentID)) {
+            return;
+        }
+        const element = commentElement(comment);
+        commentsSection.append(element);
+        commentsSection.hidden = false;
+        bindCommentButtons(element);
+        addCopyButtons(element);
     });
 }
@@ -0,0 +1,44 @@
+// Preview of the Markdown written in a field. The buttons marked with
+// data-preview name the field; the HTML rendered by the JSON API is shown in
+// the element following the button, as the post will look once published.
+document.querySelectorAll("button[data-preview]").forEach((button) => {
+    const field = button.form.elements[button.dataset.preview];
+    const preview = button.nextElementSibling;
+
+    function hide() {
+        preview.hidden = true;
+        preview.replaceChildren();
+        button.textContent = "Preview";
+    }
+
+    button.addEventListener("click", () => {
+        if (!preview.hidden) {
+            hide();
+            return;
+        }
+        fetch("/api/v1/preview", {
+            method: "POST",
+            body: JSON.stringify({content: field.value}),
+            headers: {
+                "Content-Type": "application/json"
+            }
+        })
+        .then(response => response.ok ? response.json() : Promise.reject(response.status))
+        .then(data => {
+            // The server only lets through the allowed markup.
+            preview.innerHTML = data.html;
+            addCopyButtons(preview);
+            preview.hidden = false;
+            button.textContent = "Hide preview";
+        })
+        .catch(error => {
+            console.error("Failed preview", error);
+        });
+    });
+
+    field.addEventListener("input", () => {
+        if (!preview.hidden) {
+            hide();
+        }
+    });
+});
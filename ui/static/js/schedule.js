@@ -0,0 +1,20 @@
+// Times of the datetime-local inputs marked with data-utc. The server sends
+// and expects them in UTC: they are shown in the user's time zone, and the
+// form sends the offset of that time zone in its tz field, or in the field
+// named by data-utc.
+document.querySelectorAll("input[type=datetime-local][data-utc]").forEach((input) => {
+    if (input.value) {
+        const utc = new Date(input.value + ":00Z");
+        const local = new Date(utc.getTime() - utc.getTimezoneOffset() * 60 * 1000);
+        input.value = local.toISOString().slice(0, 16);
+    }
+    const tz = input.form.elements[input.dataset.utc || "tz"];
+
+    // The offset of the chosen day, which differs from today's across a
+    // daylight saving time change.
+    input.form.addEventListener("submit", () => {
+        if (input.value) {
+            tz.value = new Date(input.value).getTimezoneOffset();
+        }
+    });
+});